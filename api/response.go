@@ -19,18 +19,198 @@
 
 package api
 
+import "time"
+
 // ErrorResponse defines the response returned by the server in case of errors
 type ErrorResponse struct {
 	// Code is a string that identifies the error
 	Code    string `json:"code"`
 	// Details gives more information about the error
 	Details string `json:"details"`
+	// Column indicates the position, starting at 1, of the error in the query when the query is invalid
+	Column int `json:"column,omitempty"`
 }
 
 // LogResponse defines the response returned by the server when the file can be processed successfully
 type LogResponse struct {
 	// File indicates the source of the events
 	File   string   `json:"file"`
+	// Files lists the files that were read, from the most recent to the oldest. It contains the rotated files that were
+	// read when the file did not contain enough events.
+	Files  []string `json:"files"`
 	// Events contain the events that are extracted from the file after processing
 	Events []string `json:"events"`
+	// NextCursor is set when the limit of events is reached, it can be passed as the cursor parameter of the same
+	// request to get the older events
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MergedLogResponse defines the response returned by the server when several files are read at once
+type MergedLogResponse struct {
+	// Files lists the files that were read, including the rotated files
+	Files []string `json:"files"`
+	// Events contains the events of all the files from the most recent to the oldest one
+	Events []LogEvent `json:"events"`
+}
+
+// StructuredLogResponse defines the response returned by the server when the events are parsed into fields
+type StructuredLogResponse struct {
+	// File indicates the source of the events, it is empty when several files are read at once
+	File string `json:"file,omitempty"`
+	// Files lists the files that were read, from the most recent to the oldest
+	Files []string `json:"files"`
+	// Events contain the events that are extracted from the file after processing
+	Events []StructuredEvent `json:"events"`
+	// NextCursor is set when the limit of events is reached, it can be passed as the cursor parameter of the same
+	// request to get the older events
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// StructuredEvent defines an event parsed into fields. Events are streamed as newline delimited JSON with this format
+// when they are parsed.
+type StructuredEvent struct {
+	// Fields contains the fields extracted from the event, it is empty when the event cannot be parsed
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Raw contains the event as is when it cannot be parsed
+	Raw string `json:"raw,omitempty"`
+	// ParseError indicates that the event cannot be parsed
+	ParseError bool `json:"parse_error,omitempty"`
+	// File indicates the file of the event when several files are read at once
+	File string `json:"file,omitempty"`
+}
+
+// LogEvent defines an event streamed by the server when a file is followed. Events are streamed as newline delimited JSON.
+// It also defines the events returned when several files are read at once.
+type LogEvent struct {
+	// Event contains the event extracted from the file after processing
+	Event string `json:"event"`
+	// File indicates the file of the event when several files are read at once
+	File string `json:"file,omitempty"`
+	// Highlights locates the matches of the filters in the event when the highlighting is requested
+	Highlights []Span `json:"highlights,omitempty"`
+}
+
+// HighlightedLogResponse defines the response returned by the server when the matches of the filters are highlighted
+type HighlightedLogResponse struct {
+	// File indicates the source of the events
+	File string `json:"file"`
+	// Files lists the files that were read, from the most recent to the oldest
+	Files []string `json:"files"`
+	// Events contain the events that are extracted from the file after processing with the location of the matches
+	Events []LogEvent `json:"events"`
+	// NextCursor is set when the limit of events is reached, it can be passed as the cursor parameter of the same
+	// request to get the older events
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Span locates a match in an event. The end offsets are exclusive. The offsets in UTF-16 code units can be used with
+// the strings of JavaScript.
+type Span struct {
+	// Start is the offset in bytes of the first byte of the match
+	Start int `json:"start"`
+	// End is the offset in bytes of the byte that follows the match
+	End int `json:"end"`
+	// StartUTF16 is the offset in UTF-16 code units of the first character of the match
+	StartUTF16 int `json:"start_utf16"`
+	// EndUTF16 is the offset in UTF-16 code units of the character that follows the match
+	EndUTF16 int `json:"end_utf16"`
+}
+
+// ContextLogResponse defines the response returned by the server when the matching events are returned with their
+// context
+type ContextLogResponse struct {
+	// File indicates the source of the events
+	File string `json:"file"`
+	// Files lists the files that were read, from the most recent to the oldest
+	Files []string `json:"files"`
+	// Events contains the matching events and their context from the most recent to the oldest
+	Events []ContextEvent `json:"events"`
+	// NextCursor is set when the limit of matching events is reached, it can be passed as the cursor parameter of the
+	// same request to get the older events
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ContextEvent defines an event returned with the events that surround it. Events are streamed as newline delimited
+// JSON with this format when the context is requested.
+type ContextEvent struct {
+	// Event contains the event when the events are not parsed
+	Event string `json:"event,omitempty"`
+	// StructuredEvent contains the fields of the event when the events are parsed
+	*StructuredEvent
+	// Group identifies the contiguous events that contain one or several matching events and their context, the groups
+	// are numbered from 0
+	Group int `json:"group"`
+	// Match indicates whether the event matches the filters, the other events are the context of the matching events
+	Match bool `json:"match"`
+	// Highlights locates the matches of the filters in a matching event when the highlighting is requested
+	Highlights []Span `json:"highlights,omitempty"`
+}
+
+// LogSummary defines the last record streamed by the server when the events are returned as newline delimited JSON
+type LogSummary struct {
+	// Summary describes the events that were streamed
+	Summary StreamSummary `json:"summary"`
+}
+
+// StreamSummary describes the events that were streamed
+type StreamSummary struct {
+	// File indicates the source of the events
+	File string `json:"file"`
+	// Files lists the files that were read, from the most recent to the oldest
+	Files []string `json:"files"`
+	// Count is the number of events that were streamed
+	Count int `json:"count"`
+	// NextCursor is set when the limit of events is reached, it can be passed as the cursor parameter of the same
+	// request to get the older events
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Error is set if the processing of the file failed after some events were streamed, the stream is then incomplete
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+// StatsResponse defines the response returned by the server when the events of a file are counted
+type StatsResponse struct {
+	// File indicates the source of the events
+	File string `json:"file"`
+	// Files lists the files that were read, from the most recent to the oldest
+	Files []string `json:"files"`
+	// Total is the number of events that pass the filters
+	Total int `json:"total"`
+	// Untimestamped is the number of events that pass the filters but whose timestamp cannot be parsed, they are not
+	// counted in any bucket
+	Untimestamped int `json:"untimestamped"`
+	// Buckets contains the number of events by period of time in chronological order, the periods without event are
+	// omitted
+	Buckets []StatsBucket `json:"buckets"`
+	// Scanned is the number of events read, including the events that do not pass the filters
+	Scanned uint `json:"scanned"`
+	// Truncated indicates that the older events were not read since the maximum number of events read was reached
+	Truncated bool `json:"truncated"`
+}
+
+// StatsBucket counts the events of a period of time
+type StatsBucket struct {
+	// Start is the start of the period, which lasts the requested interval
+	Start time.Time `json:"start"`
+	// Count is the number of events of the period
+	Count int `json:"count"`
+}
+
+// FilesResponse defines the response returned by the server when the log files are listed
+type FilesResponse struct {
+	// Files lists the files that can be read
+	Files []FileInfo `json:"files"`
+}
+
+// FileInfo describes a log file
+type FileInfo struct {
+	// Name is the name of the file, which can be passed as the file parameter of a log request
+	Name string `json:"name"`
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+	// ModTime is the time of the last modification of the file
+	ModTime time.Time `json:"mod_time"`
+	// Compression is the compression format of the file, one of none, gzip, zstd or bzip2
+	Compression string `json:"compression"`
+	// Text indicates whether the file, once decompressed, is a text file
+	Text bool `json:"text"`
 }
@@ -20,10 +20,17 @@
 package http
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"time"
 
+	"github.com/dvergnes/log-collector/processor"
+
 	"github.com/spf13/afero"
 	"gopkg.in/yaml.v2"
 )
@@ -32,6 +39,22 @@ const (
 	defaultLogFolder  = "/var/log/"
 	defaultBufferSize = 4096
 	defaultMaxEvents  = 10_000
+
+	defaultMaxScannedEvents = 1_000_000
+
+	defaultMaxDecompressedSize    = processor.DefaultMaxDecompressedSize
+	defaultDecompressionCacheSize = 4 << 30
+
+	defaultFollowPollInterval = time.Second
+	defaultMaxPatternLength   = 1024
+	defaultMaxMergedFiles     = 32
+)
+
+var (
+	// grokPatternName matches the names of the patterns that can be referenced by a grok expression
+	grokPatternName = regexp.MustCompile(`^\w+$`)
+	// grokParserName matches the names of the grok parsers
+	grokParserName = regexp.MustCompile(`^[\w-]+$`)
 )
 
 // Config contains the configuration for the HTTP server
@@ -46,6 +69,74 @@ type Config struct {
 	BufferSize int `yaml:"buffer_size"`
 	// MaxEvents defines the maximum number of events returned. That means the limit applies after filter is applied.
 	MaxEvents uint `yaml:"max_events"`
+	// MaxScannedEvents defines the maximum number of events read to compute the statistics of a file, whether they pass
+	// the filters or not
+	MaxScannedEvents uint `yaml:"max_scanned_events"`
+	// MaxDecompressedSize defines the maximum size in bytes of a compressed file once decompressed
+	MaxDecompressedSize int64 `yaml:"max_decompressed_size"`
+	// DecompressionCacheSize defines the maximum total size in bytes of the decompressed files kept to serve the next
+	// requests on the same compressed files. It cannot be lower than MaxDecompressedSize.
+	DecompressionCacheSize int64 `yaml:"decompression_cache_size"`
+	// TempFolder defines the folder of the OS where the compressed files are decompressed, it must be located outside
+	// of the log folder. By default, the temporary folder of the OS.
+	TempFolder string `yaml:"temp_folder"`
+	// FollowPollInterval defines how often a followed file is checked for new events
+	FollowPollInterval time.Duration `yaml:"follow_poll_interval"`
+	// MultilineStart defines a regular expression matching the first line of an event. When it is set, the lines that
+	// do not match it are grouped with the previous line e.g. the lines of a stack trace. Otherwise, each line is an event.
+	MultilineStart string `yaml:"multiline_start"`
+	// MaxPatternLength defines the maximum length of the patterns used to filter the events
+	MaxPatternLength uint `yaml:"max_pattern_length"`
+	// MaxMergedFiles defines the maximum number of files that can be read at once
+	MaxMergedFiles uint `yaml:"max_merged_files"`
+	// FollowSymlinks defines whether the symbolic links located in the log folder are followed. A symbolic link is never
+	// followed outside the log folder. By default, the symbolic links are not followed.
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+	// TimestampFormats defines how to extract the timestamp of the events, the formats are tried in order. By default,
+	// the timestamps of the Apache access logs, RFC 3339 timestamps and timestamps like 2006-01-02 15:04:05 are parsed.
+	TimestampFormats []TimestampFormat `yaml:"timestamp_formats"`
+	// UnparseableTimestamp defines whether the events whose timestamp cannot be parsed are included, excluded or inherit
+	// the timestamp of the previous event in the file when a time range is requested. By default, they inherit it.
+	UnparseableTimestamp processor.UnparseableTimestampPolicy `yaml:"unparseable_timestamp"`
+	// Parsers defines the parser of the events of the files when the parser parameter is not set. The first rule whose
+	// pattern matches the file parameter applies. By default, the events are not parsed.
+	Parsers []ParserRule `yaml:"parsers"`
+	// Sources defines named sources of log files, which are selected with the source parameter. A source is declared
+	// either as the path of its folder e.g. app: /opt/app/logs or as a Source. Without the source parameter, the files
+	// are read in LogFolder.
+	Sources map[string]Source `yaml:"sources"`
+	// GrokPatterns defines named patterns that the grok parsers can reference in addition to the standard patterns e.g.
+	// ORDER_ID: ORD-%{POSINT}. A pattern overrides the standard pattern of the same name.
+	GrokPatterns map[string]string `yaml:"grok_patterns"`
+	// GrokParsers defines parsers selected by name like the built-in parsers. Each parser is a grok expression that
+	// captures the fields e.g. app: "%{TIMESTAMP_ISO8601:timestamp:timestamp} %{LOGLEVEL:level} %{GREEDYDATA:message}"
+	GrokParsers map[string]string `yaml:"grok_parsers"`
+
+	multilineStart     *regexp.Regexp
+	timestampParser    processor.TimestampParser
+	sources            map[string]*Config
+	grokParsers        map[string]processor.EventParser
+	decompressionCache *processor.DecompressionCache
+	// csvRecords is set when the events are CSV records, whose quoted fields can contain new lines
+	csvRecords bool
+}
+
+// ParserRule selects the parser of the files whose file parameter matches a glob pattern
+type ParserRule struct {
+	// Files defines the glob pattern matched by the file parameter e.g. syslog*
+	Files string `yaml:"files"`
+	// Parser defines the parser of the events, one of raw, combined, json, logfmt, syslog, rfc3164, rfc5424 or a grok
+	// parser
+	Parser string `yaml:"parser"`
+}
+
+// TimestampFormat defines how to extract the timestamp of an event
+type TimestampFormat struct {
+	// Pattern defines a regular expression that locates the timestamp in the event. The timestamp is the first capturing
+	// group if the pattern has one, the entire match otherwise.
+	Pattern string `yaml:"pattern"`
+	// Layout defines the layout of the timestamp as defined by the time package e.g. 02/Jan/2006:15:04:05 -0700
+	Layout string `yaml:"layout"`
 }
 
 func (c *Config) setDefaults() {
@@ -58,24 +149,178 @@ func (c *Config) setDefaults() {
 	if c.MaxEvents == 0 {
 		c.MaxEvents = defaultMaxEvents
 	}
+	if c.MaxScannedEvents == 0 {
+		c.MaxScannedEvents = defaultMaxScannedEvents
+	}
+	if c.MaxDecompressedSize == 0 {
+		c.MaxDecompressedSize = defaultMaxDecompressedSize
+	}
+	if c.DecompressionCacheSize == 0 {
+		c.DecompressionCacheSize = defaultDecompressionCacheSize
+	}
+	if c.TempFolder == "" {
+		c.TempFolder = os.TempDir()
+	}
 	if c.ShutdownTimeout == 0 {
 		c.ShutdownTimeout = 30 * time.Second
 	}
+	if c.FollowPollInterval == 0 {
+		c.FollowPollInterval = defaultFollowPollInterval
+	}
+	if c.MaxPatternLength == 0 {
+		c.MaxPatternLength = defaultMaxPatternLength
+	}
+	if c.MaxMergedFiles == 0 {
+		c.MaxMergedFiles = defaultMaxMergedFiles
+	}
+	if c.UnparseableTimestamp == "" {
+		c.UnparseableTimestamp = processor.InheritTimestamp
+	}
 }
 
 func (c *Config) validate(fs afero.Fs) error {
 	if c.ShutdownTimeout <= 0 {
 		return errors.New("shutdown timeout must be strictly positive")
 	}
+	if c.FollowPollInterval <= 0 {
+		return errors.New("follow poll interval must be strictly positive")
+	}
+	if c.MaxDecompressedSize < 0 {
+		return errors.New("max decompressed size must be strictly positive")
+	}
+	if c.DecompressionCacheSize < c.MaxDecompressedSize {
+		return errors.New("decompression cache size must be greater than or equal to max decompressed size")
+	}
+
+	if err := c.compileGrokParsers(); err != nil {
+		return err
+	}
+	if err := c.compile(); err != nil {
+		return err
+	}
+	if err := checkLogFolder(fs, c.LogFolder); err != nil {
+		return err
+	}
+	// the decompressed files are written in a folder of the OS that cannot be read as log files
+	if isInFolder(c.LogFolder, c.TempFolder) {
+		return errors.New("temp folder must be located outside of the log folder")
+	}
+	tempFs := afero.NewOsFs()
+	if ok, err := afero.IsDir(tempFs, c.TempFolder); err != nil || !ok {
+		return errors.New("temp folder declared in configuration is not a directory")
+	}
+	// the sources share the cache of their parent
+	c.decompressionCache = processor.NewDecompressionCache(tempFs, c.TempFolder, c.MaxDecompressedSize,
+		c.DecompressionCacheSize)
+
+	names := make([]string, 0, len(c.Sources))
+	for name := range c.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.sources = make(map[string]*Config, len(names))
+	for _, name := range names {
+		conf, err := c.Sources[name].config(fs, c)
+		if err != nil {
+			return fmt.Errorf("source %s is not valid %w", name, err)
+		}
+		if isInFolder(conf.LogFolder, c.TempFolder) {
+			return fmt.Errorf("source %s is not valid temp folder must be located outside of its folder", name)
+		}
+		c.sources[name] = conf
+	}
+	return nil
+}
+
+// compile compiles the regular expressions of the configuration and verifies the settings of the events
+func (c *Config) compile() error {
+	c.multilineStart = nil
+	if c.MultilineStart != "" {
+		multilineStart, err := regexp.Compile(c.MultilineStart)
+		if err != nil {
+			return fmt.Errorf("multiline start is not a valid regular expression %w", err)
+		}
+		c.multilineStart = multilineStart
+	}
+
+	switch c.UnparseableTimestamp {
+	case processor.IncludeUnparseable, processor.ExcludeUnparseable, processor.InheritTimestamp:
+	default:
+		return errors.New("unparseable timestamp must be one of include, exclude or inherit")
+	}
+	c.timestampParser = nil
+	for i, format := range c.TimestampFormats {
+		pattern, err := regexp.Compile(format.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern of timestamp format %d is not a valid regular expression %w", i, err)
+		}
+		if format.Layout == "" {
+			return fmt.Errorf("layout of timestamp format %d must not be empty", i)
+		}
+		c.timestampParser = append(c.timestampParser, processor.TimestampFormat{
+			Pattern: pattern,
+			Layout:  format.Layout,
+		})
+	}
+	for i, rule := range c.Parsers {
+		if _, err := filepath.Match(rule.Files, ""); err != nil || len(rule.Files) == 0 {
+			return fmt.Errorf("files of parser rule %d is not a valid glob pattern", i)
+		}
+		if _, err := parseEventParser(rule.Parser, c); err != nil || len(rule.Parser) == 0 {
+			return fmt.Errorf("parser of parser rule %d must be one of %s", i, c.parserNames())
+		}
+	}
+	return nil
+}
+
+// compileGrokParsers verifies the grok patterns and compiles the grok parsers
+func (c *Config) compileGrokParsers() error {
+	for _, name := range sortedKeys(c.GrokPatterns) {
+		if !grokPatternName.MatchString(name) {
+			return fmt.Errorf("grok pattern %s must only contain letters, digits and underscores", name)
+		}
+		if _, err := processor.NewGrokParser("%{"+name+"}", c.GrokPatterns); err != nil {
+			return fmt.Errorf("grok pattern %s is not valid %w", name, err)
+		}
+	}
+	c.grokParsers = make(map[string]processor.EventParser, len(c.GrokParsers))
+	for _, name := range sortedKeys(c.GrokParsers) {
+		for _, builtin := range builtinParsers {
+			if name == builtin {
+				return fmt.Errorf("grok parser %s must not have the name of a built-in parser", name)
+			}
+		}
+		if !grokParserName.MatchString(name) {
+			return fmt.Errorf("grok parser %s must only contain letters, digits, underscores and hyphens", name)
+		}
+		parser, err := processor.NewGrokParser(c.GrokParsers[name], c.GrokPatterns)
+		if err != nil {
+			return fmt.Errorf("grok parser %s is not valid %w", name, err)
+		}
+		c.grokParsers[name] = parser
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-	ok, err := afero.Exists(fs, c.LogFolder)
+// checkLogFolder verifies that the given log folder is an existing directory
+func checkLogFolder(fs afero.Fs, logFolder string) error {
+	ok, err := afero.Exists(fs, logFolder)
 	if err != nil {
 		return fmt.Errorf("failed to verify log folder presence %w", err)
 	}
 	if !ok {
 		return errors.New("log folder declared in configuration does not exist")
 	}
-	ok, err = afero.IsDir(fs, c.LogFolder)
+	ok, err = afero.IsDir(fs, logFolder)
 	if err != nil {
 		return fmt.Errorf("failed to verify that log folder is a directory %w", err)
 	}
@@ -85,6 +330,56 @@ func (c *Config) validate(fs afero.Fs) error {
 	return nil
 }
 
+// isInFolder returns whether the given path is the given folder, which may contain wildcards, or is located in it
+func isInFolder(folder string, path string) bool {
+	folder = filepath.Clean(folder)
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if ok, _ := filepath.Match(folder, dir); ok {
+			return true
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// parserOf returns the name of the parser of the given file according to the parser rules, an empty string if no rule
+// applies
+func (c *Config) parserOf(file string) string {
+	for _, rule := range c.Parsers {
+		if ok, _ := filepath.Match(rule.Files, file); ok {
+			return rule.Parser
+		}
+	}
+	return ""
+}
+
+// splitter returns the function that breaks the content of the files into events
+func (c *Config) splitter() bufio.SplitFunc {
+	if c.csvRecords {
+		return processor.ReverseScanCSVRecords
+	}
+	if c.multilineStart == nil {
+		return processor.ReverseScanLines
+	}
+	return processor.ReverseScanMultiLines(c.multilineStart)
+}
+
+// withCSVRecords returns a copy of the configuration that breaks the content of the files into CSV records
+func (c *Config) withCSVRecords() *Config {
+	conf := *c
+	conf.csvRecords = true
+	return &conf
+}
+
+// timestamps returns the parser that extracts the timestamp of the events
+func (c *Config) timestamps() processor.TimestampParser {
+	if c.timestampParser == nil {
+		return processor.DefaultTimestampFormats
+	}
+	return c.timestampParser
+}
+
 // LoadConfig loads the Config from the given bytes array, it sets defaults and verify that the config is valid.
 // It returns an error if the config cannot be read or if it is invalid
 func LoadConfig(data []byte, fs afero.Fs) (*Config, error) {
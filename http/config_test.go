@@ -20,9 +20,11 @@
 package http_test
 
 import (
+	"os"
 	"time"
 
 	"github.com/dvergnes/log-collector/http"
+	"github.com/dvergnes/log-collector/processor"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -52,8 +54,15 @@ var _ = Describe("Config", func() {
 				Expect(err).ShouldNot(HaveOccurred())
 				Expect(conf.BufferSize).Should(BeEquivalentTo(4096))
 				Expect(conf.MaxEvents).Should(BeEquivalentTo(10_000))
+				Expect(conf.MaxScannedEvents).Should(BeEquivalentTo(1_000_000))
+				Expect(conf.MaxDecompressedSize).Should(BeEquivalentTo(1 << 30))
+				Expect(conf.DecompressionCacheSize).Should(BeEquivalentTo(4 << 30))
+				Expect(conf.TempFolder).Should(Equal(os.TempDir()))
 				Expect(conf.ShutdownTimeout).Should(Equal(30 * time.Second))
 				Expect(conf.LogFolder).Should(Equal("/var/log/"))
+				Expect(conf.FollowPollInterval).Should(Equal(time.Second))
+				Expect(conf.MaxPatternLength).Should(BeEquivalentTo(1024))
+				Expect(conf.UnparseableTimestamp).Should(Equal(processor.InheritTimestamp))
 			})
 		})
 
@@ -75,6 +84,176 @@ var _ = Describe("Config", func() {
 				Expect(err).Should(MatchError("shutdown timeout must be strictly positive"))
 			})
 		})
+
+		When("multiline start is invalid", func() {
+			JustBeforeEach(func() {
+				conf, err = http.LoadConfig([]byte(`multiline_start: "[a-z"`), fs)
+			})
+			It("should return an error", func() {
+				Expect(err).Should(MatchError(ContainSubstring("multiline start is not a valid regular expression")))
+			})
+		})
+
+		When("follow poll interval is invalid", func() {
+			JustBeforeEach(func() {
+				conf, err = http.LoadConfig([]byte(`follow_poll_interval: -1`), fs)
+			})
+			It("should return an error", func() {
+				Expect(err).Should(MatchError("follow poll interval must be strictly positive"))
+			})
+		})
+
+		When("decompression cache size is lower than max decompressed size", func() {
+			JustBeforeEach(func() {
+				conf, err = http.LoadConfig([]byte("max_decompressed_size: 2048\ndecompression_cache_size: 1024"), fs)
+			})
+			It("should return an error", func() {
+				Expect(err).Should(MatchError("decompression cache size must be greater than or equal to max decompressed size"))
+			})
+		})
+
+		When("temp folder is invalid", func() {
+			DescribeTable("it should return an error", func(data []byte, msg string) {
+				_, err := http.LoadConfig(data, fs)
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("temp folder is in the log folder", []byte("temp_folder: /var/log/tmp"), "temp folder must be located outside of the log folder"),
+				Entry("temp folder does not exist", []byte("temp_folder: /I_dont_exist"), "temp folder declared in configuration is not a directory"),
+				Entry("temp folder is in the folder of a source", []byte("temp_folder: /tmp\nsources: {app: /*}"), "source app is not valid temp folder must be located outside of its folder"),
+			)
+		})
+
+		When("timestamp config is invalid", func() {
+			DescribeTable("it should return an error", func(data []byte, msg string) {
+				_, err := http.LoadConfig(data, fs)
+				Expect(err).Should(MatchError(ContainSubstring(msg)))
+			},
+				Entry("policy is unknown", []byte("unparseable_timestamp: ignore"), "unparseable timestamp must be one of include, exclude or inherit"),
+				Entry("pattern is invalid", []byte("timestamp_formats: [{pattern: '[0-9', layout: '2006'}]"), "pattern of timestamp format 0 is not a valid regular expression"),
+				Entry("layout is missing", []byte("timestamp_formats: [{pattern: '[0-9]{4}'}]"), "layout of timestamp format 0 must not be empty"),
+			)
+		})
+
+		When("parser rules are invalid", func() {
+			DescribeTable("it should return an error", func(data []byte, msg string) {
+				_, err := http.LoadConfig(data, fs)
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("pattern is invalid", []byte("parsers: [{files: '[a-', parser: syslog}]"), "files of parser rule 0 is not a valid glob pattern"),
+				Entry("pattern is missing", []byte("parsers: [{parser: syslog}]"), "files of parser rule 0 is not a valid glob pattern"),
+				Entry("parser is unknown", []byte("parsers: [{files: '*', parser: xml}]"), "parser of parser rule 0 must be one of raw, combined, json, logfmt, csv, tsv, syslog, rfc3164 or rfc5424"),
+				Entry("source rule is invalid", []byte("sources: {app: {folder: /var/log, parsers: [{files: '*'}]}}"), "source app is not valid parser of parser rule 0 must be one of raw, combined, json, logfmt, csv, tsv, syslog, rfc3164 or rfc5424"),
+			)
+		})
+
+		When("grok parsers are declared", func() {
+			BeforeEach(func() {
+				conf, err = http.LoadConfig([]byte(`
+grok_patterns:
+  ORDER_ID: ORD-%{POSINT}
+grok_parsers:
+  orders: "%{TIMESTAMP_ISO8601:timestamp:timestamp} %{LOGLEVEL:level} %{ORDER_ID:order} %{NUMBER:amount:float}"
+parsers:
+  - files: orders*
+    parser: orders
+`), fs)
+			})
+
+			It("should compile them", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				parser, err := http.ParseEventParser("orders", conf)
+				Expect(err).ShouldNot(HaveOccurred())
+				fields, err := parser.Parse("2022-03-01T10:00:00Z INFO ORD-42 19.99")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(fields).Should(HaveKeyWithValue("order", "ORD-42"))
+				Expect(fields).Should(HaveKeyWithValue("amount", 19.99))
+			})
+
+			It("should list them in the error of an unknown parser", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err := http.ParseEventParser("xml", conf)
+				Expect(err).Should(MatchError("parser must be one of raw, combined, json, logfmt, csv, tsv, syslog, rfc3164, rfc5424 or orders"))
+			})
+		})
+
+		When("grok config is invalid", func() {
+			DescribeTable("it should return an error", func(data []byte, msg string) {
+				_, err := http.LoadConfig(data, fs)
+				Expect(err).Should(MatchError(ContainSubstring(msg)))
+			},
+				Entry("pattern name is invalid", []byte("grok_patterns: {'ORDER-ID': 'ORD'}"), "grok pattern ORDER-ID must only contain letters, digits and underscores"),
+				Entry("pattern is invalid", []byte("grok_patterns: {ORDER_ID: 'ORD-[0-'}"), "grok pattern ORDER_ID is not valid failed to compile grok expression"),
+				Entry("pattern is recursive", []byte("grok_patterns: {A: '%{B}', B: '%{A}'}"), "grok pattern A is not valid pattern A references itself"),
+				Entry("parser name is taken", []byte("grok_parsers: {json: '%{GREEDYDATA:message}'}"), "grok parser json must not have the name of a built-in parser"),
+				Entry("parser name is invalid", []byte("grok_parsers: {'my app': '%{GREEDYDATA:message}'}"), "grok parser my app must only contain letters, digits, underscores and hyphens"),
+				Entry("parser references an unknown pattern", []byte("grok_parsers: {app: '%{ORDER_ID:order}'}"), "grok parser app is not valid pattern ORDER_ID is not defined"),
+				Entry("parser expression is empty", []byte("grok_parsers: {app: ''}"), "grok parser app is not valid grok expression must not be empty"),
+			)
+		})
+
+		When("sources are declared", func() {
+			BeforeEach(func() {
+				Expect(fs.MkdirAll("/opt/app/logs", 0755)).Should(Succeed())
+				conf, err = http.LoadConfig([]byte(`
+max_events: 100
+multiline_start: "^\\d{4}-"
+sources:
+  system: /var/log
+  app:
+    folder: /opt/app/logs
+    max_events: 10
+    follow_symlinks: true
+  services: /srv/*/logs
+`), fs)
+			})
+
+			It("should inherit the settings that are not overridden", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				system, err := conf.Source("system")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(system.LogFolder).Should(Equal("/var/log"))
+				Expect(system.MaxEvents).Should(BeEquivalentTo(100))
+				Expect(system.MultilineStart).Should(Equal(`^\d{4}-`))
+				Expect(system.FollowSymlinks).Should(BeFalse())
+
+				app, err := conf.Source("app")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(app.LogFolder).Should(Equal("/opt/app/logs"))
+				Expect(app.MaxEvents).Should(BeEquivalentTo(10))
+				Expect(app.BufferSize).Should(BeEquivalentTo(4096))
+				Expect(app.FollowSymlinks).Should(BeTrue())
+			})
+
+			It("should accept a folder with wildcards that matches no folder yet", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				services, err := conf.Source("services")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(services.LogFolder).Should(Equal("/srv/*/logs"))
+			})
+
+			It("should return the config itself when no source is given", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(conf.Source("")).Should(BeIdenticalTo(conf))
+			})
+
+			It("should reject an unknown source", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err := conf.Source("db")
+				Expect(err).Should(MatchError("source db is not declared"))
+			})
+		})
+
+		When("a source is invalid", func() {
+			DescribeTable("it should return an error", func(data []byte, msg string) {
+				_, err := http.LoadConfig(data, fs)
+				Expect(err).Should(MatchError(ContainSubstring(msg)))
+			},
+				Entry("folder is missing", []byte("sources: {app: {max_events: 10}}"), "source app is not valid folder must not be empty"),
+				Entry("folder does not exist", []byte("sources: {app: /foo/bar}"), "source app is not valid log folder declared in configuration does not exist"),
+				Entry("folder is an invalid glob pattern", []byte("sources: {app: '/srv/[a-/logs'}"), "source app is not valid folder is not a valid glob pattern"),
+				Entry("multiline start is invalid", []byte("sources: {app: {folder: /var/log, multiline_start: '[a-z'}}"), "source app is not valid multiline start is not a valid regular expression"),
+			)
+		})
 	})
 
 })
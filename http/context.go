@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+)
+
+// contextSize defines the number of events returned before and after each matching event
+type contextSize struct {
+	before int
+	after  int
+}
+
+func (c contextSize) isZero() bool {
+	return c.before == 0 && c.after == 0
+}
+
+// parseContextSize parses the before and after parameters
+func parseContextSize(query url.Values) (contextSize, error) {
+	var size contextSize
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"before", &size.before}, {"after", &size.after}} {
+		value := query.Get(param.name)
+		if len(value) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > processor.MaxContextEvents {
+			return contextSize{}, httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("%s must be an integer between 0 and %d", param.name, processor.MaxContextEvents),
+				httpStatus: http.StatusBadRequest,
+			}
+		}
+		*param.value = n
+	}
+	return size, nil
+}
+
+// contextProcessor records the group of the events returned with their context and whether they match
+type contextProcessor struct {
+	*processor.ContextEventProcessor
+
+	groups  []int
+	matches []bool
+	// highlighter is nil if the matches are not highlighted
+	highlighter processor.Highlighter
+}
+
+// Next implements EventProcessor contract
+func (c *contextProcessor) Next() (string, error) {
+	event, err := c.ContextEventProcessor.Next()
+	if err == nil {
+		c.groups = append(c.groups, c.Group())
+		c.matches = append(c.matches, c.Match())
+	}
+	return event, err
+}
+
+// contextEvent returns the i-th event returned, which is parsed if parser is not nil
+func (c *contextProcessor) contextEvent(parser processor.EventParser, event string, i int) api.ContextEvent {
+	e := api.ContextEvent{Group: c.groups[i], Match: c.matches[i]}
+	if e.Match {
+		e.Highlights = highlights(c.highlighter, event)
+	}
+	if parser != nil {
+		structured := structuredEvent(parser, event, "")
+		e.StructuredEvent = &structured
+	} else {
+		e.Event = event
+	}
+	return e
+}
+
+// contextEvents returns the events returned, which are parsed if parser is not nil
+func (c *contextProcessor) contextEvents(parser processor.EventParser, events []string) []api.ContextEvent {
+	result := make([]api.ContextEvent, 0, len(events))
+	for i, event := range events {
+		result = append(result, c.contextEvent(parser, event, i))
+	}
+	return result
+}
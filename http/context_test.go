@@ -0,0 +1,58 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"net/url"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Context", func() {
+
+	Describe("parseContextSize", func() {
+		parse := func(query string) error {
+			values, err := url.ParseQuery(query)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = http.ParseContextSize(values)
+			return err
+		}
+
+		DescribeTable("should accept the valid sizes", func(query string) {
+			Expect(parse(query)).Should(Succeed())
+		},
+			Entry("no context", ""),
+			Entry("before only", "before=3"),
+			Entry("after only", "after=100"),
+			Entry("both", "before=0&after=2"),
+		)
+
+		DescribeTable("should reject the invalid sizes", func(query string, msg string) {
+			Expect(parse(query)).Should(MatchError(msg))
+		},
+			Entry("before is not a number", "before=two", "before must be an integer between 0 and 100"),
+			Entry("before is negative", "before=-1", "before must be an integer between 0 and 100"),
+			Entry("after is too large", "before=1&after=101", "after must be an integer between 0 and 100"),
+		)
+	})
+})
@@ -21,9 +21,13 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -44,8 +48,9 @@ func validateFileParameter(file string) error {
 			httpStatus: http.StatusBadRequest,
 		}
 	}
-	basename := filepath.Base(file)
-	if basename != file {
+	// the file may be located in a sub folder of the log folder but must not refer to a parent folder
+	if filepath.IsAbs(file) || filepath.Clean(file) != file || file == ".." || strings.HasPrefix(file, "../") ||
+		strings.ContainsRune(file, 0) {
 		return httpError{
 			code: invalidParameter,
 			details: "file name must not contain any relative or absolute path reference",
@@ -84,10 +89,69 @@ func parseLimit(max uint, limit string) (uint, error) {
 	return uint(l), nil
 }
 
+func parseBool(param string, value string) (bool, error) {
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("%s is not a valid boolean", param),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return b, nil
+}
+
+// parseFormat returns the format of the response, either json or ndjson. The format query parameter takes precedence
+// over the Accept header, in which ndjson is selected when its quality is not zero and not lower than the one of json.
+func parseFormat(query url.Values, header http.Header) (string, error) {
+	format := query.Get("format")
+	switch format {
+	case jsonFormat, ndjsonFormat:
+		return format, nil
+	case "":
+	default:
+		return "", httpError{
+			code:       invalidParameter,
+			details:    "format must be one of json or ndjson",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	// the quality of each format is the highest quality of the media ranges that match it
+	var ndjsonQuality, jsonQuality float64
+	for _, accept := range header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case ndjsonContentType:
+				ndjsonQuality = math.Max(ndjsonQuality, quality)
+			case "application/json", "application/*", "*/*":
+				jsonQuality = math.Max(jsonQuality, quality)
+			}
+		}
+	}
+	if ndjsonQuality > 0 && ndjsonQuality >= jsonQuality {
+		return ndjsonFormat, nil
+	}
+	return jsonFormat, nil
+}
+
 type httpError struct {
 	code       string
 	details    string
 	httpStatus int
+	column     int
 }
 
 func (err httpError) Error() string {
@@ -126,47 +190,146 @@ func checkFile(fs afero.Fs, path string) error {
 	return nil
 }
 
-func logHandler(fs afero.Fs, config *Config, parentLogger *zap.Logger) func(http.ResponseWriter, *http.Request, httprouter.Params) {
+// openFile opens the given file in the folder of the source, positioned at the cursor if it is not nil. The file must
+// be processable and, when follow is true, it must be a text file. When csv is not nil, the header of the file is read.
+// It returns the reader, the folder that contains the file and the path of the file.
+func openFile(fs afero.Fs, config *Config, name string, cursor *cursor, follow bool, csv *processor.CSVParser,
+	logger *zap.Logger) (*processor.RotatedTailReader, string, string, error) {
+	folder, file, err := sourceFolder(config.LogFolder, name)
+	if err != nil {
+		return nil, "", "", err
+	}
+	path, err := resolvePath(fs, folder, file, config.FollowSymlinks)
+	if err != nil {
+		return nil, "", "", err
+	}
+	if err := checkFile(fs, path); err != nil {
+		logger.Error("failed to verify that file can be processed", zap.Error(err))
+		return nil, "", "", err
+	}
+	if follow {
+		if err := checkFollow(fs, path); err != nil {
+			logger.Error("failed to verify that file can be followed", zap.Error(err))
+			return nil, "", "", err
+		}
+	}
+
+	var reader *processor.RotatedTailReader
+	if cursor != nil {
+		reader, err = openCursor(fs, config, folder, cursor)
+	} else {
+		reader, err = processor.NewRotatedTailReader(fs, path, config.decompressionCache)
+	}
+	if err != nil {
+		logger.Error("failed to open reader", zap.Error(err))
+		return nil, "", "", err
+	}
+	if csv != nil {
+		if err := csv.ReadHeader(reader, config.BufferSize); err != nil {
+			logger.Error("failed to read header", zap.Error(err))
+			reader.Close()
+			return nil, "", "", err
+		}
+	}
+	return reader, folder, path, nil
+}
+
+// checkFollow verifies that the file is not compressed since only the events appended to a text file can be followed
+func checkFollow(fs afero.Fs, path string) error {
+	compression, err := processor.DetectCompression(fs, path)
+	if err != nil {
+		return internalErr
+	}
+	if compression != processor.NoCompression {
+		return httpError{
+			code:       invalidParameter,
+			httpStatus: http.StatusBadRequest,
+			details:    fmt.Sprintf("file %s is compressed and cannot be followed", path),
+		}
+	}
+	return nil
+}
+
+func logHandler(fs afero.Fs, config *Config, shutdown <-chan struct{}, parentLogger *zap.Logger) func(http.ResponseWriter, *http.Request, httprouter.Params) {
 	logger := parentLogger.Named("log-handler")
 	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
 		query := request.URL.Query()
-		name := query.Get("file")
-		err := validateFileParameter(name)
+		r, err := parseLogRequest(query, request.Header, config)
 		if err != nil {
 			handleError(w, err, logger)
 			return
 		}
-
-		limit, err := parseLimit(config.MaxEvents, query.Get("limit"))
-		if err != nil {
+		if err := r.validate(); err != nil {
 			handleError(w, err, logger)
 			return
 		}
-		if limit == 0 {
-			limit = config.MaxEvents
+		filter := r.eventFilter()
+
+		if r.isMerge() {
+			mergeFiles(request.Context(), w, fs, r.conf, r.files, r.timeRange, filter, r.limit, r.format, r.parser,
+				r.highlighter, logger)
+			return
 		}
 
-		path := filepath.Join(config.LogFolder, name)
-		if err := checkFile(fs, path); err != nil {
-			logger.Error("failed to verify that file can be processed", zap.Error(err))
+		name := r.files[0]
+		filterHash := filterHash(query)
+		cursor, err := parseCursor(r.cursor, name, filterHash)
+		if err != nil {
 			handleError(w, err, logger)
 			return
 		}
 
-		reader, err := processor.NewTailReader(fs, path)
+		reader, folder, path, err := openFile(fs, r.conf, name, cursor, r.follow, r.csv, logger)
 		if err != nil {
-			logger.Error("failed to open reader", zap.Error(err))
 			handleError(w, err, logger)
 			return
 		}
 		defer reader.Close()
 
-		filter := query.Get("filter")
 		logger.Sugar().Infow("processing file",
 			"file", path,
-			"filter", filter,
-			"limit", limit)
-		p := createProcessor(reader, config, filter, limit)
+			"source", query.Get("source"),
+			"filter", query.Get("filter"),
+			"filter_mode", query.Get("filter_mode"),
+			"regex", query.Get("regex"),
+			"q", query.Get("q"),
+			"ignore_case", query.Get("ignore_case"),
+			"whole_word", query.Get("whole_word"),
+			"since", r.timeRange.since,
+			"until", r.timeRange.until,
+			"limit", r.limit,
+			"follow", r.follow,
+			"format", r.format,
+			"parser", query.Get("parser"),
+			"where", query["where"],
+			"fields", query.Get("fields"),
+			"before", r.contextSize.before,
+			"after", r.contextSize.after,
+			"highlight", r.highlight,
+			"cursor", cursor != nil)
+		p, err := createProcessor(reader, r.conf, r.timeRange, filter, r.contextSize, r.limit, cursor != nil)
+		if err != nil {
+			logger.Error("failed to create processor", zap.Error(err))
+			handleError(w, err, logger)
+			return
+		}
+		if p.context != nil {
+			p.context.highlighter = r.highlighter
+		}
+		next := func(count int) (string, error) {
+			// the limit applies to the matching events when the context is requested
+			if p.context != nil {
+				count = int(p.context.Matches())
+			}
+			return nextCursor(p, reader, folder, name, filterHash, count, r.limit)
+		}
+		if r.format == ndjsonFormat && !r.follow {
+			stream := newEventStream(w, r.parser)
+			stream.context = p.context
+			stream.highlighter = r.highlighter
+			streamFile(request.Context(), stream, path, p, reader.Files, nil, next, logger)
+			return
+		}
 
 		events, err := processFile(request.Context(), p)
 		if err != nil {
@@ -174,26 +337,97 @@ func logHandler(fs afero.Fs, config *Config, parentLogger *zap.Logger) func(http
 			handleError(w, err, logger)
 			return
 		}
-		writeResponse(w, api.LogResponse{
-			File:   path,
-			Events: events,
+		if r.follow {
+			followFile(request.Context(), w, fs, r.conf, path, reader.Size(), filter, r.parser, r.highlighter,
+				events, shutdown, logger)
+			return
+		}
+		nextCursor, err := next(len(events))
+		if err != nil {
+			logger.Error("failed to create cursor", zap.Error(err))
+			handleError(w, err, logger)
+			return
+		}
+		if p.context != nil {
+			writeJSONResponse(w, api.ContextLogResponse{
+				File:       path,
+				Files:      reader.Files(),
+				Events:     p.context.contextEvents(r.parser, events),
+				NextCursor: nextCursor,
+			}, logger)
+			return
+		}
+		if r.parser != nil {
+			writeJSONResponse(w, api.StructuredLogResponse{
+				File:       path,
+				Files:      reader.Files(),
+				Events:     structuredEvents(r.parser, events, nil),
+				NextCursor: nextCursor,
+			}, logger)
+			return
+		}
+		if r.highlighter != nil {
+			writeJSONResponse(w, api.HighlightedLogResponse{
+				File:       path,
+				Files:      reader.Files(),
+				Events:     highlightedEvents(r.highlighter, events),
+				NextCursor: nextCursor,
+			}, logger)
+			return
+		}
+		writeJSONResponse(w, api.LogResponse{
+			File:       path,
+			Files:      reader.Files(),
+			Events:     events,
+			NextCursor: nextCursor,
 		}, logger)
 
 	}
 }
 
 func handleError(w http.ResponseWriter, err error, logger *zap.Logger) {
+	writeErrorResponse(w, toHTTPError(err).httpStatus, errorResponse(err), logger)
+}
+
+// errorResponse converts the error into the response returned to the client, the details of an unexpected error are
+// not disclosed
+func errorResponse(err error) api.ErrorResponse {
+	httpErr := toHTTPError(err)
+	return api.ErrorResponse{
+		Code:    httpErr.code,
+		Details: httpErr.details,
+		Column:  httpErr.column,
+	}
+}
+
+// toHTTPError converts the error into an httpError, an unexpected error is converted into internalErr
+func toHTTPError(err error) httpError {
 	if httpErr, ok := err.(httpError); ok {
-		writeErrorResponse(w, httpErr.httpStatus, api.ErrorResponse{
-			Code:    httpErr.code,
-			Details: httpErr.details,
-		}, logger)
-	} else {
-		writeErrorResponse(w, http.StatusInternalServerError, api.ErrorResponse{
-			Code:    internalError,
-			Details: internalErrorDetails,
-		}, logger)
+		return httpErr
+	}
+	if errors.Is(err, processor.DecompressedSizeErr) {
+		return httpError{
+			code:       invalidParameter,
+			httpStatus: http.StatusBadRequest,
+			details:    "file is too large once decompressed",
+		}
+	}
+	if errors.Is(err, processor.ReplacedFileErr) {
+		return httpError{
+			code:       invalidParameter,
+			httpStatus: http.StatusConflict,
+			details:    "file has been replaced while being opened",
+		}
+	}
+	if errors.Is(err, processor.PendingEventsErr) {
+		return httpError{
+			code:       invalidParameter,
+			httpStatus: http.StatusBadRequest,
+			details: "more than 1000 consecutive events have no timestamp, " +
+				"set unparseable_timestamp to include or exclude to select a time range",
+		}
 	}
+	return internalErr
 }
 
 func processFile(ctx context.Context, p processor.EventProcessor) ([]string, error) {
@@ -221,14 +455,182 @@ func processFile(ctx context.Context, p processor.EventProcessor) ([]string, err
 	return acc, nil
 }
 
-func createProcessor(reader processor.TailReader, config *Config, filter string, limit uint) processor.EventProcessor {
-	p := processor.EventProcessor(processor.NewEventBreaker(reader, processor.ReverseScanLines, config.BufferSize))
-	if len(filter) != 0 {
-		predicate := func(s string) bool {
-			return strings.Contains(s, filter)
+// streamFile writes the events as newline delimited JSON as soon as they are processed, then a summary record. The
+// events are flushed periodically so that the client receives them while the file is processed. If the processing fails,
+// the error is reported in the summary record since the response status has already been sent. When several files are
+// read at once, eventFile returns the file of the last event returned by the processor.
+func streamFile(ctx context.Context, stream *eventStream, path string, p processor.EventProcessor,
+	files func() []string, eventFile func() string, next func(count int) (string, error), logger *zap.Logger) {
+	summary := api.StreamSummary{File: path}
+	stop := stream.flushEvery(streamFlushInterval)
+	defer stop()
+	for {
+		if ctx.Err() != nil {
+			logger.Info("client canceled request")
+			return
+		}
+		event, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			logger.Error("failed to process file", zap.Error(err))
+			errResp := errorResponse(err)
+			summary.Error = &errResp
+			break
+		}
+		file := ""
+		if eventFile != nil {
+			file = eventFile()
+		}
+		if err := stream.write(event, file); err != nil {
+			logger.Error("failed to write event", zap.Error(err))
+			return
+		}
+		summary.Count++
+	}
+	if summary.Error == nil {
+		cursor, err := next(summary.Count)
+		if err != nil {
+			logger.Error("failed to create cursor", zap.Error(err))
+			errResp := errorResponse(err)
+			summary.Error = &errResp
+		}
+		summary.NextCursor = cursor
+	}
+	summary.Files = files()
+	if err := stream.writeSummary(summary); err != nil {
+		logger.Error("failed to write summary", zap.Error(err))
+		return
+	}
+	stream.flush()
+}
+
+// followFile streams the given events in chronological order, then the events appended to the file after the offset
+// until the client disconnects or the server shuts down. The events are parsed if parser is not nil and the matches are
+// highlighted if highlighter is not nil.
+func followFile(ctx context.Context, w http.ResponseWriter, fs afero.Fs, config *Config, path string, offset int64,
+	filter processor.EventFilter, parser processor.EventParser, highlighter processor.Highlighter, events []string,
+	shutdown <-chan struct{}, logger *zap.Logger) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	follower, err := processor.NewFollower(fs, path, offset, config.BufferSize, config.multilineStart,
+		config.FollowPollInterval)
+	if err != nil {
+		logger.Error("failed to open follower", zap.Error(err))
+		handleError(w, err, logger)
+		return
+	}
+	defer follower.Close()
+
+	stream := newEventStream(w, parser)
+	stream.highlighter = highlighter
+	// the most recent events are written from the oldest to the newest so that the stream reads like tail -f
+	for i := len(events) - 1; i >= 0; i-- {
+		if err := stream.write(events[i], ""); err != nil {
+			logger.Error("failed to write event", zap.Error(err))
+			return
+		}
+	}
+	stream.flush()
+
+	for {
+		event, err := follower.Next(ctx)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			logger.Error("failed to follow file", zap.Error(err))
+			return
+		}
+		if filter != nil && !filter(event) {
+			continue
+		}
+		if err := stream.write(event, ""); err != nil {
+			logger.Error("failed to write event", zap.Error(err))
+			return
+		}
+		stream.flush()
+	}
+}
+
+// pipeline chains the processors applied to the events of a file
+type pipeline struct {
+	processor.EventProcessor
+
+	breaker *processor.EventBreaker
+	// timeRange is nil if no time range is requested
+	timeRange processor.OffsetEventProcessor
+	// context is nil if the context of the matching events is not requested
+	context *contextProcessor
+}
+
+// rewind positions the reader right after the last event returned by the pipeline, so that the events read ahead are
+// read again. The filter and the limit do not read ahead, the last event they return is the last event returned by the
+// processor they decorate.
+func (p *pipeline) rewind() error {
+	offset := p.breaker.Offset()
+	if p.timeRange != nil {
+		offset = p.timeRange.Offset()
+	}
+	if p.context != nil {
+		offset = p.context.Offset()
+	}
+	return p.breaker.Rewind(offset)
+}
+
+// createProcessor creates the pipeline of processors. When the reader is resumed from a cursor, it is already
+// positioned before until. When a context is requested, the limit applies to the matching events.
+func createProcessor(reader processor.TailReader, config *Config, timeRange timeRange, filter processor.EventFilter,
+	contextSize contextSize, limit uint, resumed bool) (*pipeline, error) {
+	pl, err := createFileProcessor(reader, config, timeRange, resumed)
+	if err != nil {
+		return nil, err
+	}
+	if !contextSize.isZero() {
+		pl.context = &contextProcessor{
+			ContextEventProcessor: processor.NewContextEventProcessor(pl.EventProcessor, filter, contextSize.before,
+				contextSize.after, limit),
 		}
-		p = processor.WithFilter(p, predicate)
+		pl.EventProcessor = pl.context
+		return pl, nil
+	}
+	if filter != nil {
+		pl.EventProcessor = processor.WithFilter(pl.EventProcessor, filter)
+	}
+	pl.EventProcessor = processor.WithLimit(pl.EventProcessor, limit)
+	return pl, nil
+}
+
+// createFileProcessor creates the pipeline of processors that breaks the content of a file into events and applies the
+// time range
+func createFileProcessor(reader processor.TailReader, config *Config, timeRange timeRange, resumed bool) (*pipeline,
+	error) {
+	pl := &pipeline{
+		breaker: processor.NewEventBreaker(reader, config.splitter(), config.BufferSize),
+	}
+	// the events after until are skipped by a bisection instead of being read one by one
+	if !timeRange.until.IsZero() && !resumed {
+		if err := pl.breaker.SeekToTime(config.timestamps(), timeRange.until); err != nil {
+			return nil, err
+		}
+	}
+	p := processor.EventProcessor(pl.breaker)
+	// the time range applies before the filter so that the events without timestamp can inherit the timestamp of any
+	// previous event
+	if !timeRange.isZero() {
+		pl.timeRange = processor.WithTimeRange(p, config.timestamps(), timeRange.since, timeRange.until,
+			config.UnparseableTimestamp)
+		p = pl.timeRange
 	}
-	p = processor.WithLimit(p, limit)
-	return p
+	pl.EventProcessor = p
+	return pl, nil
 }
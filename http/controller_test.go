@@ -20,20 +20,29 @@
 package http_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	gohttp "net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dvergnes/log-collector/api"
 	"github.com/dvergnes/log-collector/http"
+	"github.com/dvergnes/log-collector/mocks"
+	"github.com/dvergnes/log-collector/processor"
 
 	"github.com/julienschmidt/httprouter"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/afero"
+	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 )
 
@@ -43,9 +52,13 @@ var _ = Describe("Controller", func() {
 
 	Describe("validateFileParameter", func() {
 		When("file name is valid", func() {
-			It("should not return an error", func() {
-				Expect(http.ValidateFileParameter("foo.log")).Should(Succeed())
-			})
+			DescribeTable("should not return an error", func(file string) {
+				Expect(http.ValidateFileParameter(file)).Should(Succeed())
+			},
+				Entry("file is in the log folder", "foo.log"),
+				Entry("file is in a sub folder", "nginx/access.log"),
+				Entry("file name starts with dots", "..foo.log"),
+			)
 		})
 
 		When("file name is invalid", func() {
@@ -56,6 +69,11 @@ var _ = Describe("Controller", func() {
 			},
 				Entry("empty file name", "", "file name must not be empty"),
 				Entry("file is a relative path", "../../etc/master.passwd", "file name must not contain any relative or absolute path reference"),
+				Entry("file is an absolute path", "/etc/master.passwd", "file name must not contain any relative or absolute path reference"),
+				Entry("file traverses a parent folder", "nginx/../../etc/master.passwd", "file name must not contain any relative or absolute path reference"),
+				Entry("file refers to the current folder", "./foo.log", "file name must not contain any relative or absolute path reference"),
+				Entry("file has an empty element", "nginx//access.log", "file name must not contain any relative or absolute path reference"),
+				Entry("file contains a NUL byte", "foo.log\x00.txt", "file name must not contain any relative or absolute path reference"),
 			)
 		})
 
@@ -94,6 +112,46 @@ var _ = Describe("Controller", func() {
 
 	})
 
+	Describe("parseBool", func() {
+		DescribeTable("should parse the boolean parameter", func(value string, expected bool) {
+			b, err := http.ParseBool("follow", value)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(b).Should(Equal(expected))
+		},
+			Entry("value is empty", "", false),
+			Entry("value is true", "true", true),
+			Entry("value is false", "false", false),
+		)
+
+		When("value is invalid", func() {
+			It("should return an error", func() {
+				_, err := http.ParseBool("follow", "maybe")
+				Expect(err).Should(MatchError("follow is not a valid boolean"))
+			})
+		})
+	})
+
+	Describe("parseFormat", func() {
+		DescribeTable("should return the format of the response", func(query string, accept string, expected string) {
+			req := httptest.NewRequest("GET", "http://localhost:8888/log?"+query, nil)
+			if accept != "" {
+				req.Header.Set("Accept", accept)
+			}
+			format, err := http.ParseFormat(req.URL.Query(), req.Header)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(format).Should(Equal(expected))
+		},
+			Entry("json is the default", "", "", "json"),
+			Entry("format is ndjson", "format=ndjson", "", "ndjson"),
+			Entry("client accepts ndjson", "", "application/x-ndjson", "ndjson"),
+			Entry("client accepts ndjson among other types", "", "application/json;q=0.9, application/x-ndjson", "ndjson"),
+			Entry("client prefers json", "", "application/json, application/x-ndjson;q=0.9", "json"),
+			Entry("client refuses ndjson", "", "application/x-ndjson;q=0", "json"),
+			Entry("client accepts any type", "", "*/*", "json"),
+			Entry("format takes precedence over accept header", "format=json", "application/x-ndjson", "json"),
+		)
+	})
+
 	Describe("checkFile", func() {
 		var fs afero.Fs
 		BeforeEach(func() {
@@ -122,17 +180,22 @@ var _ = Describe("Controller", func() {
 
 	Describe("logHandler", func() {
 		var (
-			fs afero.Fs
-			h  httprouter.Handle
+			fs       afero.Fs
+			h        httprouter.Handle
+			shutdown chan struct{}
 		)
 		BeforeEach(func() {
 			fs = afero.NewMemMapFs()
 			Expect(fs.MkdirAll(logFolder, 0755)).Should(Succeed())
+			shutdown = make(chan struct{})
 			h = http.LogHandler(fs, &http.Config{
-				BufferSize: 1024,
-				LogFolder:  logFolder,
-				MaxEvents:  2,
-			}, zap.NewNop())
+				BufferSize:           1024,
+				LogFolder:            logFolder,
+				MaxEvents:            2,
+				FollowPollInterval:   time.Millisecond,
+				MaxPatternLength:     20,
+				UnparseableTimestamp: processor.InheritTimestamp,
+			}, shutdown, zap.NewNop())
 			afero.WriteFile(fs, logFolder+"/foo.log", []byte(
 				`128.84.140.215 - 0000001 [05/Oct/2020:10:32:51 -0800] "HEAD /web_assets/flash/runner/Leaderboard1_v04.swf HTTP/1.1" 200 - "http://sourceforge.net/forum/forum.php?forum_id=544686" "Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1; Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1) ; .NET CLR 1.1.4322; InfoPath.2)" "128.84.140.215.6087629394390023"
 190.134.145.226 - 0000002 [05/Oct/2020:10:32:51 -0800] "GET /web_assets/flash/runner/Leaderboard1_v04.swf HTTP/1.1" 200 185077 "http://sourceforge.net/project/showfiles.php?group_id=32993&package_id=25487&release_id=273294" "Mozilla/5.0 (Windows; U; Windows NT 5.0; en-US; rv:1.8.1.11) Gecko/20071127 Firefox/2.0.0.11" "190.134.145.226.6087629394390021"
@@ -142,6 +205,9 @@ var _ = Describe("Controller", func() {
 `), 0755)
 		})
 		When("parameters are invalid", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/foo.log.gz", []byte{0x1f, 0x8b}, 0755)).Should(Succeed())
+			})
 			DescribeTable("should return an error response", func(params []string, msg string) {
 				query := strings.Join(params, "&")
 				req := httptest.NewRequest("GET", "http://localhost:8888/log?"+query, nil)
@@ -161,10 +227,41 @@ var _ = Describe("Controller", func() {
 				Entry("file is empty", nil, "file name must not be empty"),
 				Entry("limit is invalid", []string{"file=foo.log", "limit=-1"}, "limit must be strictly positive"),
 				Entry("file is a directory", []string{"file=.", "limit=1"}, "file /var/log is a directory"),
+				Entry("follow is invalid", []string{"file=foo.log", "follow=maybe"}, "follow is not a valid boolean"),
+				Entry("regex is invalid", []string{"file=foo.log", "regex=[a-"}, "regex is not a valid regular expression"),
+				Entry("followed file is compressed", []string{"file=foo.log.gz", "follow=true"}, "file /var/log/foo.log.gz is compressed and cannot be followed"),
+				Entry("format is invalid", []string{"file=foo.log", "format=xml"}, "format must be one of json or ndjson"),
+				Entry("since is invalid", []string{"file=foo.log", "since=yesterday"}, "since is neither a valid RFC 3339 timestamp nor a valid duration"),
+				Entry("until is used with follow", []string{"file=foo.log", "until=5m", "follow=true"}, "until cannot be used with follow since the followed events are always the most recent ones"),
+				Entry("before is invalid", []string{"file=foo.log", "filter=GET", "before=-1"}, "before must be an integer between 0 and 100"),
+				Entry("after is too large", []string{"file=foo.log", "filter=GET", "after=101"}, "after must be an integer between 0 and 100"),
+				Entry("context is used without filter", []string{"file=foo.log", "after=2"}, "before and after cannot be used without filter"),
+				Entry("context is used with follow", []string{"file=foo.log", "filter=GET", "before=1", "follow=true"}, "before and after cannot be used with follow or when several files are read"),
+				Entry("highlight is invalid", []string{"file=foo.log", "filter=GET", "highlight=maybe"}, "highlight is not a valid boolean"),
+				Entry("highlight is used without filter", []string{"file=foo.log", "highlight=true"}, "highlight cannot be used without filter, regex or q"),
+				Entry("highlight is used with parser", []string{"file=foo.log", "filter=GET", "parser=json", "highlight=true"}, "highlight cannot be used with parser"),
 			)
 
 		})
 
+		When("query is invalid", func() {
+			It("should return an error response with the column of the error", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=foo.log&q=GET%20OR%20(HEAD", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusBadRequest))
+				body, _ := io.ReadAll(resp.Body)
+				err := api.ErrorResponse{}
+				Expect(json.Unmarshal(body, &err)).Should(Succeed())
+				Expect(err.Code).Should(Equal("invalid.query"))
+				Expect(err.Details).Should(Equal("missing closing parenthesis at column 13"))
+				Expect(err.Column).Should(Equal(13))
+			})
+		})
+
 		When("parameters are valid", func() {
 			DescribeTable("should return the events", func(params []string, events []string) {
 				query := strings.Join(params, "&")
@@ -180,6 +277,7 @@ var _ = Describe("Controller", func() {
 				Expect(json.Unmarshal(body, &lr)).Should(Succeed())
 				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
 				Expect(lr.File).Should(Equal("/var/log/foo.log"))
+				Expect(lr.Files).Should(Equal([]string{"/var/log/foo.log"}))
 				Expect(lr.Events).Should(HaveLen(len(events)))
 				Expect(lr.Events).Should(ContainElements(events))
 			},
@@ -189,9 +287,763 @@ var _ = Describe("Controller", func() {
 				}),
 				Entry("filter is applied", []string{"limit=2", "filter=HEAD"}, []string{
 				`128.84.140.215 - 0000001 [05/Oct/2020:10:32:51 -0800] "HEAD /web_assets/flash/runner/Leaderboard1_v04.swf HTTP/1.1" 200 - "http://sourceforge.net/forum/forum.php?forum_id=544686" "Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1; Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1) ; .NET CLR 1.1.4322; InfoPath.2)" "128.84.140.215.6087629394390023"`}),
+				Entry("regex is applied", []string{"limit=2", "regex=%22%203%5Cd%5Cd%20"}, []string{
+				`240.54.187.93 - 0000005 [05/Oct/2020:10:32:52 -0800] "GET /web_assets/flash/runner/Imagine_Leaderboard.swf HTTP/1.1" 302 - "http://www.acme.com/" "Mozilla/4.0 (compatible; MSIE 7.0; Windows NT 6.0; SLCC1; .NET CLR 2.0.50727; Media Center PC 5.0; .NET CLR 3.0.04506)" "240.54.187.93.6087629394390025"`}),
+				Entry("time range is applied", []string{"since=2020-10-05T18:32:51Z", "until=2020-10-05T18:32:51Z", "filter=HEAD"}, []string{
+				`128.84.140.215 - 0000001 [05/Oct/2020:10:32:51 -0800] "HEAD /web_assets/flash/runner/Leaderboard1_v04.swf HTTP/1.1" 200 - "http://sourceforge.net/forum/forum.php?forum_id=544686" "Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1; Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1; SV1) ; .NET CLR 1.1.4322; InfoPath.2)" "128.84.140.215.6087629394390023"`}),
+				Entry("time range excludes all events", []string{"since=2020-10-05T18:32:53Z"}, []string{}),
+			)
+		})
+
+		When("file is in a sub folder", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/nginx/access.log", []byte("event_1\nevent_2\n"), 0755)).Should(Succeed())
+			})
+
+			It("should return the events", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=nginx/access.log", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &lr)).Should(Succeed())
+				Expect(lr.File).Should(Equal("/var/log/nginx/access.log"))
+				Expect(lr.Events).Should(Equal([]string{"event_2", "event_1"}))
+			})
+		})
+
+		When("file is in a source", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, "/opt/app/logs/api.log", []byte("app_1\napp_2\n"), 0755)).Should(Succeed())
+				Expect(afero.WriteFile(fs, "/srv/shop/logs/api.log", []byte("shop_1\nshop_2\n"), 0755)).Should(Succeed())
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+max_events: 2
+sources:
+  app:
+    folder: /opt/app/logs
+    max_events: 1
+  services: /srv/*/logs
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+			})
+
+			DescribeTable("should return the events of the source", func(query string, file string, events []string) {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?"+query, nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &lr)).Should(Succeed())
+				Expect(lr.File).Should(Equal(file))
+				Expect(lr.Events).Should(Equal(events))
+			},
+				Entry("source applies its settings", "source=app&file=api.log", "/opt/app/logs/api.log", []string{"app_2"}),
+				Entry("source folder has a wildcard", "source=services&file=shop/api.log", "/srv/shop/logs/api.log", []string{"shop_2", "shop_1"}),
+			)
+
+			DescribeTable("should return an error response", func(query string, msg string) {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?"+query, nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusBadRequest))
+				err := api.ErrorResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &err)).Should(Succeed())
+				Expect(err.Code).Should(Equal("invalid.parameter"))
+				Expect(err.Details).Should(Equal(msg))
+			},
+				Entry("source is unknown", "source=db&file=api.log", "source db is not declared"),
+				Entry("file does not match the wildcard", "source=services&file=api.log", "file api.log does not start with the folders matched by the wildcards of the source"),
+			)
+
+			It("should not accept the cursor of another source", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?source=app&file=api.log", nil)
+				w := httptest.NewRecorder()
+				h(w, req, httprouter.Params{})
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &lr)).Should(Succeed())
+				Expect(lr.NextCursor).ShouldNot(BeEmpty())
+
+				req = httptest.NewRequest("GET", "http://localhost:8888/log?file=api.log&cursor="+lr.NextCursor, nil)
+				w = httptest.NewRecorder()
+				h(w, req, httprouter.Params{})
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusBadRequest))
+
+				req = httptest.NewRequest("GET", "http://localhost:8888/log?source=app&file=api.log&cursor="+lr.NextCursor, nil)
+				w = httptest.NewRecorder()
+				h(w, req, httprouter.Params{})
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(json.Unmarshal(w.Body.Bytes(), &lr)).Should(Succeed())
+				Expect(lr.Events).Should(Equal([]string{"app_1"}))
+			})
+		})
+
+		When("events span over several lines", func() {
+			BeforeEach(func() {
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+max_events: 2
+multiline_start: "^\\d{4}-"
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+				Expect(afero.WriteFile(fs, logFolder+"/bar.log", []byte("2022-01-01 first\n2022-01-02 panic\ngoroutine 1\n"), 0755)).Should(Succeed())
+			})
+
+			It("should group the lines of an event", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=bar.log", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				body, _ := io.ReadAll(resp.Body)
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(body, &lr)).Should(Succeed())
+				Expect(lr.Events).Should(Equal([]string{"2022-01-02 panic\ngoroutine 1", "2022-01-01 first"}))
+			})
+		})
+
+		When("file is compressed", func() {
+			BeforeEach(func() {
+				b := bytes.Buffer{}
+				gz := gzip.NewWriter(&b)
+				_, err := gz.Write([]byte("event_1\nevent_2\nevent_3\n"))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(gz.Close()).Should(Succeed())
+				Expect(afero.WriteFile(fs, logFolder+"/bar.log.3.gz", b.Bytes(), 0755)).Should(Succeed())
+			})
+
+			It("should return the decompressed events", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=bar.log.3.gz", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				body, _ := io.ReadAll(resp.Body)
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(body, &lr)).Should(Succeed())
+				Expect(lr.Events).Should(Equal([]string{"event_3", "event_2"}))
+			})
+
+			When("file is too large once decompressed", func() {
+				BeforeEach(func() {
+					conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+max_decompressed_size: 10
+`), fs)
+					Expect(err).ShouldNot(HaveOccurred())
+					h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+				})
+
+				It("should return a bad request", func() {
+					req := httptest.NewRequest("GET", "http://localhost:8888/log?file=bar.log.3.gz", nil)
+					w := httptest.NewRecorder()
+
+					h(w, req, httprouter.Params{})
+
+					resp := w.Result()
+					Expect(resp.StatusCode).Should(Equal(gohttp.StatusBadRequest))
+					body, _ := io.ReadAll(resp.Body)
+					er := api.ErrorResponse{}
+					Expect(json.Unmarshal(body, &er)).Should(Succeed())
+					Expect(er.Details).Should(Equal("file is too large once decompressed"))
+				})
+			})
+		})
+
+		When("file does not contain enough events", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/bar.log", []byte("event_3\n"), 0755)).Should(Succeed())
+				Expect(afero.WriteFile(fs, logFolder+"/bar.log.1", []byte("event_1\nevent_2\n"), 0755)).Should(Succeed())
+			})
+
+			It("should read the rotated files", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=bar.log", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				body, _ := io.ReadAll(resp.Body)
+				lr := api.LogResponse{}
+				Expect(json.Unmarshal(body, &lr)).Should(Succeed())
+				Expect(lr.File).Should(Equal("/var/log/bar.log"))
+				Expect(lr.Files).Should(Equal([]string{"/var/log/bar.log", "/var/log/bar.log.1"}))
+				Expect(lr.Events).Should(Equal([]string{"event_3", "event_2"}))
+			})
+		})
+
+		When("file is followed", func() {
+			It("should stream the most recent events then the appended events until the server shuts down", func() {
+				server := httptest.NewServer(gohttp.HandlerFunc(func(w gohttp.ResponseWriter, r *gohttp.Request) {
+					h(w, r, httprouter.Params{})
+				}))
+				defer server.Close()
+				stopped := false
+				stop := func() {
+					if !stopped {
+						stopped = true
+						close(shutdown)
+					}
+				}
+				// the server waits for the stream to end before closing
+				defer stop()
+
+				resp, err := gohttp.Get(server.URL + "/log?file=foo.log&follow=true&filter=-")
+				Expect(err).ShouldNot(HaveOccurred())
+				defer resp.Body.Close()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(resp.Header.Get("Content-Type")).Should(Equal("application/x-ndjson"))
+				decoder := json.NewDecoder(resp.Body)
+				next := func() string {
+					e := api.LogEvent{}
+					Expect(decoder.Decode(&e)).Should(Succeed())
+					return e.Event
+				}
+				Expect(next()).Should(HavePrefix("42.123.97.195 - 0000004"))
+				Expect(next()).Should(HavePrefix("240.54.187.93 - 0000005"))
+
+				f, err := fs.OpenFile(logFolder+"/foo.log", os.O_APPEND|os.O_WRONLY, 0644)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = f.WriteString("appended - 1\nfiltered out\nappended - 2\n")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(f.Close()).Should(Succeed())
+				Expect(next()).Should(Equal("appended - 1"))
+				Expect(next()).Should(Equal("appended - 2"))
+
+				stop()
+				Expect(decoder.More()).Should(BeFalse())
+			})
+		})
+
+		When("events are paginated", func() {
+			get := func(query string) (int, api.LogResponse, api.ErrorResponse) {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=foo.log&"+query, nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				lr, errResp := api.LogResponse{}, api.ErrorResponse{}
+				if resp.StatusCode == gohttp.StatusOK {
+					Expect(json.Unmarshal(w.Body.Bytes(), &lr)).Should(Succeed())
+				} else {
+					Expect(json.Unmarshal(w.Body.Bytes(), &errResp)).Should(Succeed())
+				}
+				return resp.StatusCode, lr, errResp
+			}
+
+			It("should walk the file backward page by page", func() {
+				var ids []string
+				query := "limit=2"
+				for {
+					status, lr, _ := get(query)
+					Expect(status).Should(Equal(gohttp.StatusOK))
+					for _, e := range lr.Events {
+						ids = append(ids, strings.Fields(e)[2])
+					}
+					if lr.NextCursor == "" {
+						break
+					}
+					query = "limit=2&cursor=" + lr.NextCursor
+				}
+				Expect(ids).Should(Equal([]string{"0000005", "0000004", "0000003", "0000002", "0000001"}))
+			})
+
+			It("should resume after the events read ahead by the time range", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/foo.log", []byte(
+					"2020-10-05 10:00:01 a\n2020-10-05 10:00:02 b\nat b\n2020-10-05 10:00:03 c\nat c\n"), 0755)).Should(Succeed())
+				var events []string
+				query := "limit=1&since=2020-10-05T10:00:00Z"
+				for {
+					_, lr, _ := get(query)
+					events = append(events, lr.Events...)
+					if lr.NextCursor == "" {
+						break
+					}
+					query = "limit=1&since=2020-10-05T10:00:00Z&cursor=" + lr.NextCursor
+				}
+				Expect(events).Should(Equal([]string{
+					"at c", "2020-10-05 10:00:03 c", "at b", "2020-10-05 10:00:02 b", "2020-10-05 10:00:01 a",
+				}))
+			})
+
+			It("should ignore the events appended since the cursor was created", func() {
+				_, lr, _ := get("")
+				f, err := fs.OpenFile(logFolder+"/foo.log", os.O_APPEND|os.O_WRONLY, 0644)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = f.WriteString("appended\n")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(f.Close()).Should(Succeed())
+
+				_, lr, _ = get("cursor=" + lr.NextCursor)
+				Expect(lr.Events).Should(HaveLen(2))
+				Expect(lr.Events[0]).Should(HavePrefix("159.226.247.165 - 0000003"))
+			})
+
+			DescribeTable("should reject the cursor", func(change func(), query string, status int, code string, details string) {
+				_, lr, _ := get("filter=GET")
+				Expect(lr.NextCursor).ShouldNot(BeEmpty())
+				change()
+				actualStatus, _, errResp := get(query + "&cursor=" + lr.NextCursor)
+				Expect(actualStatus).Should(Equal(status))
+				Expect(errResp.Code).Should(Equal(code))
+				Expect(errResp.Details).Should(Equal(details))
+			},
+				Entry("filter has changed", func() {}, "filter=HEAD", gohttp.StatusBadRequest, "invalid.parameter",
+					"cursor was created with other filter parameters"),
+				Entry("file is followed", func() {}, "filter=GET&follow=true", gohttp.StatusBadRequest, "invalid.parameter",
+					"cursor cannot be used with follow"),
+				Entry("file has been truncated", func() {
+					Expect(afero.WriteFile(fs, logFolder+"/foo.log", []byte("GET\n"), 0755)).Should(Succeed())
+				}, "filter=GET", gohttp.StatusConflict, "cursor.expired",
+					"file /var/log/foo.log has been rotated or truncated since the cursor was created"),
+				Entry("file has been rotated", func() {
+					Expect(fs.Rename(logFolder+"/foo.log", logFolder+"/foo.log.1")).Should(Succeed())
+					Expect(afero.WriteFile(fs, logFolder+"/foo.log", []byte(strings.Repeat("GET /\n", 500)), 0755)).Should(Succeed())
+				}, "filter=GET", gohttp.StatusConflict, "cursor.expired",
+					"file /var/log/foo.log has been rotated or truncated since the cursor was created"),
 			)
 		})
 
+		When("events are streamed", func() {
+			readStream := func(req *gohttp.Request) ([]string, api.StreamSummary) {
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(resp.Header.Get("Content-Type")).Should(Equal("application/x-ndjson"))
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				var events []string
+				for _, line := range lines[:len(lines)-1] {
+					e := api.LogEvent{}
+					Expect(json.Unmarshal([]byte(line), &e)).Should(Succeed())
+					events = append(events, e.Event)
+				}
+				summary := api.LogSummary{}
+				Expect(json.Unmarshal([]byte(lines[len(lines)-1]), &summary)).Should(Succeed())
+				return events, summary.Summary
+			}
+
+			It("should write the events then a summary when format is ndjson", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=foo.log&format=ndjson", nil)
+				events, summary := readStream(req)
+				Expect(events).Should(HaveLen(2))
+				Expect(events[0]).Should(HavePrefix("240.54.187.93 - 0000005"))
+				Expect(events[1]).Should(HavePrefix("42.123.97.195 - 0000004"))
+				Expect(summary.File).Should(Equal("/var/log/foo.log"))
+				Expect(summary.Files).Should(Equal([]string{"/var/log/foo.log"}))
+				Expect(summary.Count).Should(Equal(2))
+				Expect(summary.NextCursor).ShouldNot(BeEmpty())
+				Expect(summary.Error).Should(BeNil())
+			})
+
+			It("should write the events when the client accepts ndjson", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=foo.log&filter=HEAD", nil)
+				req.Header.Set("Accept", "application/json;q=0.5, application/x-ndjson")
+				events, summary := readStream(req)
+				Expect(events).Should(HaveLen(1))
+				Expect(events[0]).Should(HavePrefix("128.84.140.215 - 0000001"))
+				Expect(summary.Count).Should(Equal(1))
+				Expect(summary.NextCursor).Should(BeEmpty())
+			})
+		})
+
+		When("events are parsed", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/access.log", []byte(
+					`::1 - - [05/Oct/2020:10:32:51 +0000] "GET / HTTP/1.1" 200 12 "-" "curl/7.68.0"
+oops
+`), 0755)).Should(Succeed())
+			})
+
+			It("should return the fields of the events", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=access.log&parser=combined", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				sr := api.StructuredLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &sr)).Should(Succeed())
+				Expect(sr.File).Should(Equal("/var/log/access.log"))
+				Expect(sr.Events).Should(HaveLen(2))
+				Expect(sr.Events[0]).Should(Equal(api.StructuredEvent{Raw: "oops", ParseError: true}))
+				Expect(sr.Events[1].ParseError).Should(BeFalse())
+				Expect(sr.Events[1].Fields).Should(HaveKeyWithValue("method", "GET"))
+				Expect(sr.Events[1].Fields).Should(HaveKeyWithValue("status", BeEquivalentTo(200)))
+				Expect(sr.Events[1].Fields).Should(HaveKeyWithValue("timestamp", "2020-10-05T10:32:51Z"))
+			})
+
+			It("should stream the fields of the events", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=access.log&parser=combined&format=ndjson&limit=1", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				Expect(lines).Should(HaveLen(2))
+				Expect(lines[0]).Should(MatchJSON(`{"raw": "oops", "parse_error": true}`))
+			})
+
+			It("should filter the JSON events on their fields before the limit", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/app.json", []byte(`{"level": "error", "http": {"status": 500}, "msg": "a"}
+{"level": "error", "http": {"status": 503}, "msg": "b"}
+{"level": "error", "http": {"status": 404}, "msg": "c"}
+{"level": "info", "http": {"status": 502}, "msg": "d"}
+`), 0755)).Should(Succeed())
+				req := httptest.NewRequest("GET",
+					"http://localhost:8888/log?file=app.json&parser=json&where=level:error&where=http.status%3E%3D500&fields=msg&limit=1", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				sr := api.StructuredLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &sr)).Should(Succeed())
+				Expect(sr.Events).Should(Equal([]api.StructuredEvent{{Fields: map[string]interface{}{"msg": "b"}}}))
+				Expect(sr.NextCursor).ShouldNot(BeEmpty())
+			})
+
+			It("should parse the syslog files selected by the parser rules", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/syslog", []byte(`<38>Jan  2 07:00:00 host sshd[1]: Accepted publickey
+<36>Jan  2 07:00:01 host sshd[1]: Invalid user admin
+<35>Jan  2 07:00:02 host cron[2]: Cannot run job
+`), 0755)).Should(Succeed())
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+parsers:
+  - files: syslog*
+    parser: syslog
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+				req := httptest.NewRequest("GET",
+					"http://localhost:8888/log?file=syslog&where=severity%3C%3Dwarning&where=app%3Dsshd&fields=severity,message", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(w.Body.String()).Should(MatchJSON(`{"file": "/var/log/syslog", "files": ["/var/log/syslog"], "events": [
+					{"fields": {"severity": "warning", "message": "Invalid user admin"}}]}`))
+			})
+
+			It("should parse the events with the grok parsers of the configuration", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/orders.log", []byte(`2022-03-01T10:00:00Z INFO ORD-41 paid 250.5
+2022-03-01T10:00:01Z INFO ORD-42 paid 19.99
+2022-03-01T10:00:02Z WARN ORD-43 refused 120
+`), 0755)).Should(Succeed())
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+grok_patterns:
+  ORDER_ID: ORD-%{POSINT}
+grok_parsers:
+  orders: "%{TIMESTAMP_ISO8601:timestamp:timestamp} %{LOGLEVEL:level} %{ORDER_ID:order} %{WORD:status} %{NUMBER:amount:float}"
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+				req := httptest.NewRequest("GET",
+					"http://localhost:8888/log?file=orders.log&parser=orders&where=status%3Dpaid&where=amount%3E100&fields=order,amount,timestamp", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(w.Body.String()).Should(MatchJSON(`{"file": "/var/log/orders.log", "files": ["/var/log/orders.log"], "events": [
+					{"fields": {"order": "ORD-41", "amount": 250.5, "timestamp": "2022-03-01T10:00:00Z"}}]}`))
+			})
+
+			It("should parse the CSV records with the header of the file", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/audit.csv", []byte(`time,user,action,comment
+2022-01-01T10:00:00Z,alice,login,
+2022-01-01T10:05:00Z,bob,delete,"removed the ""draft"" report
+on request of alice"
+2022-01-01T10:10:00Z,alice,logout,
+`), 0755)).Should(Succeed())
+				req := httptest.NewRequest("GET",
+					"http://localhost:8888/log?file=audit.csv&parser=csv&where=user%3Dbob&fields=action,comment", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(w.Body.String()).Should(MatchJSON(`{"file": "/var/log/audit.csv", "files": ["/var/log/audit.csv"], "events": [
+					{"fields": {"action": "delete", "comment": "removed the \"draft\" report\non request of alice"}}]}`))
+			})
+
+			It("should not return the header of the CSV file", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/audit.tsv", []byte("user\taction\nalice\tlogin\n"), 0755)).Should(Succeed())
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=audit.tsv&parser=tsv", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				Expect(w.Body.String()).Should(MatchJSON(`{"file": "/var/log/audit.tsv", "files": ["/var/log/audit.tsv"], "events": [
+					{"fields": {"user": "alice", "action": "login"}}]}`))
+			})
+
+			It("should reject the CSV parser when several files are read", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=access.log&file=foo.log&parser=csv", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusBadRequest))
+				Expect(w.Body.String()).Should(ContainSubstring("csv and tsv parsers cannot be used with follow or when several files are read"))
+			})
+
+			It("should reject an unknown parser", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=access.log&parser=xml", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusBadRequest))
+			})
+		})
+
+		When("context is requested", func() {
+			BeforeEach(func() {
+				var lines []string
+				for i := 1; i <= 10; i++ {
+					level := "info"
+					if i == 3 || i == 5 || i == 9 {
+						level = "error"
+					}
+					lines = append(lines, fmt.Sprintf("n=%d level=%s", i, level))
+				}
+				Expect(afero.WriteFile(fs, logFolder+"/app.log", []byte(strings.Join(lines, "\n")+"\n"), 0755)).Should(Succeed())
+			})
+
+			get := func(query string) api.ContextLogResponse {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app.log&"+query, nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				cr := api.ContextLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &cr)).Should(Succeed())
+				return cr
+			}
+
+			It("should group the matching events with their context and page through the matches", func() {
+				cr := get("filter=error&before=1&after=1")
+				Expect(cr.Events).Should(Equal([]api.ContextEvent{
+					{Event: "n=10 level=info", Group: 0},
+					{Event: "n=9 level=error", Group: 0, Match: true},
+					{Event: "n=8 level=info", Group: 0},
+					{Event: "n=6 level=info", Group: 1},
+					{Event: "n=5 level=error", Group: 1, Match: true},
+					{Event: "n=4 level=info", Group: 1},
+				}))
+				Expect(cr.NextCursor).ShouldNot(BeEmpty())
+
+				cr = get("filter=error&before=1&after=1&cursor=" + cr.NextCursor)
+				Expect(cr.Events).Should(Equal([]api.ContextEvent{
+					{Event: "n=3 level=error", Group: 0, Match: true},
+					{Event: "n=2 level=info", Group: 0},
+				}))
+				Expect(cr.NextCursor).Should(BeEmpty())
+			})
+
+			It("should merge the overlapping contexts", func() {
+				cr := get("filter=error&before=2&after=2&limit=2")
+				Expect(cr.Events).Should(HaveLen(8))
+				for i, e := range cr.Events {
+					Expect(e.Group).Should(Equal(0))
+					Expect(e.Event).Should(HavePrefix(fmt.Sprintf("n=%d ", 10-i)))
+				}
+			})
+
+			It("should stream the parsed events with their context", func() {
+				req := httptest.NewRequest("GET",
+					"http://localhost:8888/log?file=app.log&parser=logfmt&where=level%3Derror&after=1&limit=1&fields=n&format=ndjson", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				Expect(lines).Should(HaveLen(3))
+				Expect(lines[0]).Should(MatchJSON(`{"fields": {"n": "10"}, "group": 0, "match": false}`))
+				Expect(lines[1]).Should(MatchJSON(`{"fields": {"n": "9"}, "group": 0, "match": true}`))
+				summary := api.LogSummary{}
+				Expect(json.Unmarshal([]byte(lines[2]), &summary)).Should(Succeed())
+				Expect(summary.Summary.Count).Should(Equal(2))
+				Expect(summary.Summary.NextCursor).ShouldNot(BeEmpty())
+			})
+
+			It("should highlight the matching events only", func() {
+				cr := get("filter=error&after=1&limit=1&highlight=true")
+				Expect(cr.Events).Should(Equal([]api.ContextEvent{
+					{Event: "n=10 level=info", Group: 0},
+					{Event: "n=9 level=error", Group: 0, Match: true, Highlights: []api.Span{{Start: 10, End: 15, StartUTF16: 10, EndUTF16: 15}}},
+				}))
+			})
+		})
+
+		When("matches are highlighted", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/app.log", []byte("l'été est chaud\nÉTÉ 🔥 été\nhiver\n"), 0755)).Should(Succeed())
+			})
+
+			It("should return the offsets of the matches in bytes and in UTF-16 code units", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app.log&filter=%C3%A9t%C3%A9&ignore_case=true&highlight=true", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				hr := api.HighlightedLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &hr)).Should(Succeed())
+				Expect(hr.Events).Should(Equal([]api.LogEvent{
+					{Event: "ÉTÉ 🔥 été", Highlights: []api.Span{
+						{Start: 0, End: 5, StartUTF16: 0, EndUTF16: 3},
+						{Start: 11, End: 16, StartUTF16: 7, EndUTF16: 10},
+					}},
+					{Event: "l'été est chaud", Highlights: []api.Span{{Start: 2, End: 7, StartUTF16: 2, EndUTF16: 5}}},
+				}))
+			})
+
+			It("should stream the highlighted events", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app.log&q=chaud%20OR%20hiver&highlight=true&format=ndjson", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				Expect(lines).Should(HaveLen(3))
+				Expect(lines[0]).Should(MatchJSON(`{"event": "hiver", "highlights": [{"start": 0, "end": 5, "start_utf16": 0, "end_utf16": 5}]}`))
+				Expect(lines[1]).Should(MatchJSON(`{"event": "l'été est chaud", "highlights": [{"start": 12, "end": 17, "start_utf16": 10, "end_utf16": 15}]}`))
+			})
+
+			It("should not highlight the events by default", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app.log&filter=hiver", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Body.String()).Should(MatchJSON(`{"file": "/var/log/app.log", "files": ["/var/log/app.log"], "events": ["hiver"]}`))
+			})
+		})
+
+		When("several files are read", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, logFolder+"/app/a.log", []byte(
+					"2020-10-05 10:00:01 INFO a1\n2020-10-05 10:00:03 ERROR a2\n2020-10-05 10:00:05 INFO a3\n"), 0755)).Should(Succeed())
+				Expect(afero.WriteFile(fs, logFolder+"/app/b.log", []byte(
+					"2020-10-05 10:00:02 ERROR b1\n2020-10-05 10:00:04 INFO b2\n"), 0755)).Should(Succeed())
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+max_events: 3
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.LogHandler(fs, conf, shutdown, zap.NewNop())
+			})
+
+			merge := func(query string) []api.LogEvent {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?"+query, nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				resp := w.Result()
+				Expect(resp.StatusCode).Should(Equal(gohttp.StatusOK))
+				mr := api.MergedLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &mr)).Should(Succeed())
+				Expect(mr.Files).Should(Equal([]string{"/var/log/app/a.log", "/var/log/app/b.log"}))
+				return mr.Events
+			}
+
+			It("should merge the events of the files by timestamp", func() {
+				Expect(merge("file=app/a.log&file=app/b.log")).Should(Equal([]api.LogEvent{
+					{Event: "2020-10-05 10:00:05 INFO a3", File: "/var/log/app/a.log"},
+					{Event: "2020-10-05 10:00:04 INFO b2", File: "/var/log/app/b.log"},
+					{Event: "2020-10-05 10:00:03 ERROR a2", File: "/var/log/app/a.log"},
+				}))
+			})
+
+			It("should apply the filter and the limit to the merged events", func() {
+				Expect(merge("file=app/*.log&filter=ERROR&limit=2&until=2020-10-05T10:00:04Z")).Should(Equal([]api.LogEvent{
+					{Event: "2020-10-05 10:00:03 ERROR a2", File: "/var/log/app/a.log"},
+					{Event: "2020-10-05 10:00:02 ERROR b1", File: "/var/log/app/b.log"},
+				}))
+			})
+
+			It("should highlight the merged events", func() {
+				Expect(merge("file=app/*.log&filter=ERROR&limit=1&highlight=true")).Should(Equal([]api.LogEvent{
+					{Event: "2020-10-05 10:00:03 ERROR a2", File: "/var/log/app/a.log", Highlights: []api.Span{{Start: 20, End: 25, StartUTF16: 20, EndUTF16: 25}}},
+				}))
+			})
+
+			It("should keep the continuation lines of an event with it", func() {
+				Expect(afero.WriteFile(fs, logFolder+"/api/c.log", []byte(
+					"2020-10-05 10:00:03 ERROR c1\n\tat main.go:12\n2020-10-05 10:00:05 INFO c2\n"), 0755)).Should(Succeed())
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=api/c.log&file=app/b.log", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusOK))
+				mr := api.MergedLogResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &mr)).Should(Succeed())
+				Expect(mr.Events).Should(Equal([]api.LogEvent{
+					{Event: "2020-10-05 10:00:05 INFO c2", File: "/var/log/api/c.log"},
+					{Event: "2020-10-05 10:00:04 INFO b2", File: "/var/log/app/b.log"},
+					{Event: "\tat main.go:12", File: "/var/log/api/c.log"},
+				}))
+			})
+
+			It("should stream the events with their file", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app/*.log&format=ndjson&limit=2", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+				Expect(lines).Should(HaveLen(3))
+				e := api.LogEvent{}
+				Expect(json.Unmarshal([]byte(lines[1]), &e)).Should(Succeed())
+				Expect(e).Should(Equal(api.LogEvent{Event: "2020-10-05 10:00:04 INFO b2", File: "/var/log/app/b.log"}))
+				summary := api.LogSummary{}
+				Expect(json.Unmarshal([]byte(lines[2]), &summary)).Should(Succeed())
+				Expect(summary.Summary.Count).Should(Equal(2))
+				Expect(summary.Summary.Files).Should(Equal([]string{"/var/log/app/a.log", "/var/log/app/b.log"}))
+			})
+
+			It("should not follow the files", func() {
+				req := httptest.NewRequest("GET", "http://localhost:8888/log?file=app/a.log&file=app/b.log&follow=true", nil)
+				w := httptest.NewRecorder()
+
+				h(w, req, httprouter.Params{})
+
+				Expect(w.Result().StatusCode).Should(Equal(gohttp.StatusBadRequest))
+				err := api.ErrorResponse{}
+				Expect(json.Unmarshal(w.Body.Bytes(), &err)).Should(Succeed())
+				Expect(err.Details).Should(Equal("follow and cursor cannot be used when several files are read"))
+			})
+		})
+
 		When("request is canceled", func() {
 
 			It("should stop processing and return an error", func() {
@@ -215,4 +1067,41 @@ var _ = Describe("Controller", func() {
 		})
 	})
 
+	Describe("streamFile", func() {
+		It("should flush the events while the file is scanned for the next event", func() {
+			w := &flushRecorder{ResponseRecorder: httptest.NewRecorder(), event: "event_2", flushed: make(chan struct{})}
+			p := &mocks.EventProcessor{}
+			p.On("Next").Return("event_3", nil).Once()
+			p.On("Next").Return("event_2", nil).Once()
+			p.On("Next").Run(func(mock.Arguments) {
+				// the scan of the next event is slow, the previous event must be received by the client meanwhile
+				Eventually(w.flushed).Should(BeClosed())
+			}).Return("", io.EOF).Once()
+
+			http.StreamFile(context.Background(), http.NewEventStream(w, nil), "/var/log/app.log", p,
+				func() []string { return []string{"/var/log/app.log"} }, nil,
+				func(int) (string, error) { return "", nil }, zap.NewNop())
+
+			lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+			Expect(lines).Should(HaveLen(3))
+			Expect(lines[1]).Should(MatchJSON(`{"event": "event_2"}`))
+		})
+	})
 })
+
+// flushRecorder is a ResponseRecorder that signals the first flush of the given event
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	event   string
+	flushed chan struct{}
+	once    sync.Once
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	if strings.Contains(r.Body.String(), r.event) {
+		r.once.Do(func() {
+			close(r.flushed)
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/spf13/afero"
+)
+
+// cursorParameters lists the query parameters that select the events, a cursor can only be used with the parameters
+// of the request that created it
+var cursorParameters = []string{"source", "filter", "filter_mode", "regex", "q", "ignore_case", "whole_word", "since",
+	"until", "parser", "where", "before", "after"}
+
+// cursor locates where the previous page of events stopped. It is encoded as base64 JSON so that it is opaque to the
+// clients.
+type cursor struct {
+	// File is the file parameter of the request
+	File string `json:"file"`
+	// Name is the path, relative to the folder of the source, of the file being read. It differs from File once the rotated files
+	// are read or when File is a symbolic link.
+	Name string `json:"name"`
+	// Size is the size of the file being read when the cursor was created
+	Size int64 `json:"size"`
+	// OffsetFromEnd is the number of bytes already read from the end of the file
+	OffsetFromEnd int64 `json:"offset_from_end"`
+	// Fingerprint identifies the file being read
+	Fingerprint string `json:"fingerprint"`
+	// Filter is a hash of the parameters that select the events
+	Filter string `json:"filter"`
+}
+
+var invalidCursorErr = httpError{
+	code:       invalidParameter,
+	details:    "cursor is not valid",
+	httpStatus: http.StatusBadRequest,
+}
+
+// filterHash hashes the parameters of the query that select the events
+func filterHash(query url.Values) string {
+	h := sha256.New()
+	for _, param := range cursorParameters {
+		for _, value := range query[param] {
+			fmt.Fprintf(h, "%s=%q\n", param, value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// encodeCursor creates the cursor of the given position in a file of the log folder
+func encodeCursor(logFolder string, file string, filter string, position processor.Position) (string, error) {
+	name, err := filepath.Rel(filepath.Clean(logFolder), position.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to locate file in log folder %w", err)
+	}
+	payload, err := json.Marshal(cursor{
+		File:          file,
+		Name:          name,
+		Size:          position.Size,
+		OffsetFromEnd: position.OffsetFromEnd,
+		Fingerprint:   position.Fingerprint,
+		Filter:        filter,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize cursor %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(payload), nil
+}
+
+// parseCursor decodes the cursor parameter and verifies that it was created by a request for the same file and with the
+// same filter. It returns nil if the cursor is empty.
+func parseCursor(value string, file string, filter string) (*cursor, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidCursorErr
+	}
+	c := cursor{}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, invalidCursorErr
+	}
+	if c.File != file || validateFileParameter(c.Name) != nil || c.Size < 0 || c.OffsetFromEnd < 0 ||
+		c.OffsetFromEnd > c.Size {
+		return nil, invalidCursorErr
+	}
+	if c.Filter != filter {
+		return nil, httpError{
+			code:       invalidParameter,
+			details:    "cursor was created with other filter parameters",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return &c, nil
+}
+
+// openCursor opens the file of the given folder where the cursor stopped and positions the reader at the cursor. It
+// returns an error if the file has been rotated or truncated since the cursor was created.
+func openCursor(fs afero.Fs, config *Config, folder string, c *cursor) (*processor.RotatedTailReader, error) {
+	name, err := resolvePath(fs, folder, c.Name, config.FollowSymlinks)
+	if err != nil {
+		return nil, err
+	}
+	position := processor.Position{
+		Name:          name,
+		Size:          c.Size,
+		OffsetFromEnd: c.OffsetFromEnd,
+		Fingerprint:   c.Fingerprint,
+	}
+	expiredErr := httpError{
+		code:       cursorExpired,
+		details:    fmt.Sprintf("file %s has been rotated or truncated since the cursor was created", position.Name),
+		httpStatus: http.StatusConflict,
+	}
+	exist, err := afero.Exists(fs, position.Name)
+	if err != nil {
+		return nil, internalErr
+	}
+	if !exist {
+		return nil, expiredErr
+	}
+	reader, err := processor.NewRotatedTailReader(fs, position.Name, config.decompressionCache)
+	if err != nil {
+		return nil, err
+	}
+	if err := reader.Resume(position); err != nil {
+		reader.Close()
+		if errors.Is(err, processor.StalePositionErr) {
+			return nil, expiredErr
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+// nextCursor returns the cursor of the next page when the page of events is full, an empty string otherwise
+func nextCursor(p *pipeline, reader *processor.RotatedTailReader, logFolder string, file string, filter string,
+	count int, limit uint) (string, error) {
+	if uint(count) < limit {
+		return "", nil
+	}
+	if err := p.rewind(); err != nil {
+		return "", err
+	}
+	position, ok, err := reader.Position()
+	if err != nil || !ok {
+		return "", err
+	}
+	return encodeCursor(logFolder, file, filter, position)
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"encoding/base64"
+	"net/url"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cursor", func() {
+
+	Describe("filterHash", func() {
+		It("should only depend on the parameters that select the events", func() {
+			Expect(http.FilterHash(url.Values{"filter": {"GET"}, "limit": {"10"}})).
+				Should(Equal(http.FilterHash(url.Values{"filter": {"GET"}, "format": {"ndjson"}})))
+			Expect(http.FilterHash(url.Values{"filter": {"GET"}})).
+				ShouldNot(Equal(http.FilterHash(url.Values{"regex": {"GET"}})))
+		})
+	})
+
+	Describe("parseCursor", func() {
+		encode := func(payload string) string {
+			return base64.RawURLEncoding.EncodeToString([]byte(payload))
+		}
+
+		When("cursor is empty", func() {
+			It("should return a nil cursor", func() {
+				c, err := http.ParseCursor("", "foo.log", "hash")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(c).Should(BeNil())
+			})
+		})
+
+		When("cursor is valid", func() {
+			It("should return the cursor", func() {
+				c, err := http.ParseCursor(encode(`{"file":"foo.log","name":"foo.log.1","size":10,"offset_from_end":4,"filter":"hash"}`),
+					"foo.log", "hash")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(c).ShouldNot(BeNil())
+			})
+		})
+
+		When("cursor is invalid", func() {
+			DescribeTable("should return an error", func(value string, msg string) {
+				_, err := http.ParseCursor(value, "foo.log", "hash")
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("cursor is not base64", "!!!", "cursor is not valid"),
+				Entry("cursor is not JSON", encode("foo"), "cursor is not valid"),
+				Entry("cursor belongs to another file", encode(`{"file":"bar.log","name":"bar.log","size":10,"filter":"hash"}`),
+					"cursor is not valid"),
+				Entry("cursor refers to a parent folder", encode(`{"file":"foo.log","name":"../foo.log","size":10,"filter":"hash"}`),
+					"cursor is not valid"),
+				Entry("offset is beyond the size", encode(`{"file":"foo.log","name":"foo.log","size":10,"offset_from_end":11,"filter":"hash"}`),
+					"cursor is not valid"),
+				Entry("filter has changed", encode(`{"file":"foo.log","name":"foo.log","size":10,"filter":"other"}`),
+					"cursor was created with other filter parameters"),
+			)
+		})
+	})
+})
@@ -19,10 +19,55 @@
 
 package http
 
+import "time"
+
 var (
 	ValidateFileParameter = validateFileParameter
 	ParseLimit            = parseLimit
+	ParseBool             = parseBool
+	ParseFormat           = parseFormat
+	ParseFilter           = parseFilter
+	ParseTimeRange        = parseTimeRange
+	ParseCursor           = parseCursor
+	FilterHash            = filterHash
 	CheckFile             = checkFile
+	ResolvePath           = resolvePath
+	SourceFolder          = sourceFolder
+	ExpandFiles           = expandFiles
+	ParseEventParser      = parseEventParser
+	ParseParserParameters = parseParserParameters
+	ParseContextSize      = parseContextSize
+	Highlights            = highlights
+	ParseInterval         = parseInterval
+	NewEventStream        = newEventStream
+	StreamFile            = streamFile
+	ParseLogRequest       = parseLogRequest
 
-	LogHandler = logHandler
+	LogHandler   = logHandler
+	FilesHandler = filesHandler
+	StatsHandler = statsHandler
 )
+
+func (tr timeRange) Since() time.Time {
+	return tr.since
+}
+
+func (tr timeRange) Until() time.Time {
+	return tr.until
+}
+
+func (c *Config) Source(name string) (*Config, error) {
+	return c.source(name)
+}
+
+func (r *logRequest) Validate() error {
+	return r.validate()
+}
+
+func (r *logRequest) Limit() uint {
+	return r.limit
+}
+
+func (r *logRequest) IsMerge() bool {
+	return r.isMerge()
+}
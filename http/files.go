@@ -0,0 +1,198 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+const (
+	sortByName    = "name"
+	sortBySize    = "size"
+	sortByModTime = "mod_time"
+
+	ascendingOrder  = "asc"
+	descendingOrder = "desc"
+)
+
+// fileListing defines how the files are listed
+type fileListing struct {
+	sort  string
+	order string
+	// glob is nil if all the files are listed
+	glob processor.EventFilter
+}
+
+// parseFileListing parses the sort, order and glob query parameters
+func parseFileListing(query url.Values, maxPatternLength uint) (fileListing, error) {
+	listing := fileListing{
+		sort:  query.Get("sort"),
+		order: query.Get("order"),
+	}
+	switch listing.sort {
+	case "":
+		listing.sort = sortByName
+	case sortByName, sortBySize, sortByModTime:
+	default:
+		return fileListing{}, httpError{
+			code:       invalidParameter,
+			details:    "sort must be one of name, size or mod_time",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	switch listing.order {
+	case "":
+		listing.order = ascendingOrder
+	case ascendingOrder, descendingOrder:
+	default:
+		return fileListing{}, httpError{
+			code:       invalidParameter,
+			details:    "order must be one of asc or desc",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	if glob := query.Get("glob"); len(glob) != 0 {
+		f, _, err := compileFilter("glob", glob, globFilterMode, processor.MatchOptions{}, maxPatternLength)
+		if err != nil {
+			return fileListing{}, err
+		}
+		listing.glob = f
+	}
+	return listing, nil
+}
+
+// listFiles describes the readable files of the folders of the source. The files that cannot be passed as the file
+// parameter of a log request, the directories and the files that cannot be opened are not listed.
+func listFiles(fs afero.Fs, logFolder string, listing fileListing, logger *zap.Logger) ([]api.FileInfo, error) {
+	folders, err := sourceFolders(fs, logFolder)
+	if err != nil {
+		logger.Error("failed to list source folders", zap.Error(err))
+		return nil, internalErr
+	}
+	files := []api.FileInfo{}
+	for folder, prefix := range folders {
+		infos, err := afero.ReadDir(fs, folder)
+		if err != nil {
+			logger.Error("failed to list log folder", zap.Error(err))
+			return nil, internalErr
+		}
+		files = append(files, describeFiles(fs, folder, prefix, infos, listing, logger)...)
+	}
+	sortFiles(files, listing)
+	return files, nil
+}
+
+// describeFiles describes the given files of a folder, their name is prefixed by the given prefix
+func describeFiles(fs afero.Fs, folder string, prefix string, infos []os.FileInfo, listing fileListing,
+	logger *zap.Logger) []api.FileInfo {
+	files := []api.FileInfo{}
+	for _, info := range infos {
+		name := filepath.Join(prefix, info.Name())
+		if !info.Mode().IsRegular() || validateFileParameter(name) != nil {
+			continue
+		}
+		if listing.glob != nil && !listing.glob(name) {
+			continue
+		}
+		file := filepath.Join(folder, info.Name())
+		compression, err := processor.DetectCompression(fs, file)
+		if err != nil {
+			logger.Debug("skipping unreadable file", zap.String("file", file), zap.Error(err))
+			continue
+		}
+		text, err := processor.IsTextFile(fs, file, compression)
+		if err != nil {
+			logger.Debug("skipping unreadable file", zap.String("file", file), zap.Error(err))
+			continue
+		}
+		files = append(files, api.FileInfo{
+			Name:        name,
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			Compression: string(compression),
+			Text:        text,
+		})
+	}
+	return files
+}
+
+func sortFiles(files []api.FileInfo, listing fileListing) {
+	less := func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	}
+	switch listing.sort {
+	case sortBySize:
+		less = func(i, j int) bool {
+			return files[i].Size < files[j].Size
+		}
+	case sortByModTime:
+		less = func(i, j int) bool {
+			return files[i].ModTime.Before(files[j].ModTime)
+		}
+	}
+	if listing.order == descendingOrder {
+		ascending := less
+		less = func(i, j int) bool {
+			return ascending(j, i)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if less(i, j) || less(j, i) {
+			return less(i, j)
+		}
+		// the files with the same size or modification time are sorted by name since the folders of a source are
+		// listed in no particular order
+		return files[i].Name < files[j].Name
+	})
+}
+
+func filesHandler(fs afero.Fs, config *Config, parentLogger *zap.Logger) func(http.ResponseWriter, *http.Request, httprouter.Params) {
+	logger := parentLogger.Named("files-handler")
+	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		query := request.URL.Query()
+		conf, err := config.source(query.Get("source"))
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		listing, err := parseFileListing(query, conf.MaxPatternLength)
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		files, err := listFiles(fs, conf.LogFolder, listing, logger)
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		writeJSONResponse(w, api.FilesResponse{Files: files}, logger)
+	}
+}
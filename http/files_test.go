@@ -0,0 +1,169 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"encoding/json"
+	gohttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/http"
+
+	"github.com/julienschmidt/httprouter"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+var _ = Describe("Files", func() {
+	const logFolder = "/var/log"
+
+	Describe("filesHandler", func() {
+		var (
+			fs  afero.Fs
+			h   httprouter.Handle
+			now = time.Date(2020, time.October, 5, 10, 32, 51, 0, time.UTC)
+		)
+
+		BeforeEach(func() {
+			fs = afero.NewMemMapFs()
+			Expect(fs.MkdirAll(logFolder+"/subdir", 0755)).Should(Succeed())
+			h = http.FilesHandler(fs, &http.Config{
+				LogFolder:        logFolder,
+				MaxPatternLength: 20,
+			}, zap.NewNop())
+			for i, f := range []struct {
+				name    string
+				content []byte
+			}{
+				{name: "b.log", content: []byte("event_1\n")},
+				{name: "a.log", content: []byte("event_1\nevent_2\n")},
+				{name: "c.log.1.gz", content: []byte{0x1f, 0x8b, 0x00}},
+				{name: "d.bin", content: []byte{0x00, 0x01}},
+			} {
+				path := logFolder + "/" + f.name
+				Expect(afero.WriteFile(fs, path, f.content, 0644)).Should(Succeed())
+				Expect(fs.Chtimes(path, now, now.Add(time.Duration(i)*time.Minute))).Should(Succeed())
+			}
+		})
+
+		list := func(query string) (int, []byte) {
+			req := httptest.NewRequest("GET", "http://localhost:8888/files?"+query, nil)
+			w := httptest.NewRecorder()
+
+			h(w, req, httprouter.Params{})
+
+			resp := w.Result()
+			Expect(resp.Header.Get("Content-Type")).Should(Equal("application/json"))
+			return resp.StatusCode, w.Body.Bytes()
+		}
+
+		names := func(query string) []string {
+			status, body := list(query)
+			Expect(status).Should(Equal(gohttp.StatusOK))
+			fr := api.FilesResponse{}
+			Expect(json.Unmarshal(body, &fr)).Should(Succeed())
+			var names []string
+			for _, f := range fr.Files {
+				names = append(names, f.Name)
+			}
+			return names
+		}
+
+		It("should describe the files of the log folder", func() {
+			status, body := list("")
+			Expect(status).Should(Equal(gohttp.StatusOK))
+			fr := api.FilesResponse{}
+			Expect(json.Unmarshal(body, &fr)).Should(Succeed())
+			Expect(fr.Files).Should(HaveLen(4))
+			Expect(fr.Files[0].Name).Should(Equal("a.log"))
+			Expect(fr.Files[0].Size).Should(BeEquivalentTo(16))
+			Expect(fr.Files[0].ModTime).Should(BeTemporally("==", now.Add(time.Minute)))
+			Expect(fr.Files[0].Compression).Should(Equal("none"))
+			Expect(fr.Files[0].Text).Should(BeTrue())
+			Expect(fr.Files[2].Compression).Should(Equal("gzip"))
+			Expect(fr.Files[2].Text).Should(BeFalse())
+			Expect(fr.Files[3].Text).Should(BeFalse())
+		})
+
+		DescribeTable("should sort and filter the files", func(query string, expected []string) {
+			Expect(names(query)).Should(Equal(expected))
+		},
+			Entry("files are sorted by name by default", "", []string{"a.log", "b.log", "c.log.1.gz", "d.bin"}),
+			Entry("files are sorted by size", "sort=size", []string{"d.bin", "c.log.1.gz", "b.log", "a.log"}),
+			Entry("files are sorted by modification time", "sort=mod_time&order=desc",
+				[]string{"d.bin", "c.log.1.gz", "a.log", "b.log"}),
+			Entry("files are filtered by a glob pattern", "glob=*.log*", []string{"a.log", "b.log", "c.log.1.gz"}),
+		)
+
+		When("source is given", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, "/srv/shop/logs/api.log", []byte("event_1\n"), 0644)).Should(Succeed())
+				Expect(afero.WriteFile(fs, "/srv/blog/logs/api.log", []byte("event_1\n"), 0644)).Should(Succeed())
+				Expect(afero.WriteFile(fs, "/srv/blog/logs/db.log", []byte("event_1\n"), 0644)).Should(Succeed())
+				Expect(afero.WriteFile(fs, "/srv/README", []byte("services\n"), 0644)).Should(Succeed())
+				conf, err := http.LoadConfig([]byte(`
+log_folder: /var/log
+max_pattern_length: 20
+sources:
+  services: /srv/*/logs
+`), fs)
+				Expect(err).ShouldNot(HaveOccurred())
+				h = http.FilesHandler(fs, conf, zap.NewNop())
+			})
+
+			It("should prefix the files with the folders matched by the wildcards", func() {
+				Expect(names("source=services")).Should(Equal([]string{"blog/api.log", "blog/db.log", "shop/api.log"}))
+				Expect(names("source=services&glob=*api*")).Should(Equal([]string{"blog/api.log", "shop/api.log"}))
+			})
+
+			It("should sort the files of the same size by name", func() {
+				Expect(names("source=services&sort=size")).Should(Equal([]string{"blog/api.log", "blog/db.log", "shop/api.log"}))
+				Expect(names("source=services&sort=size&order=desc")).Should(
+					Equal([]string{"blog/api.log", "blog/db.log", "shop/api.log"}))
+			})
+
+			It("should reject an unknown source", func() {
+				status, body := list("source=db")
+				Expect(status).Should(Equal(gohttp.StatusBadRequest))
+				err := api.ErrorResponse{}
+				Expect(json.Unmarshal(body, &err)).Should(Succeed())
+				Expect(err.Details).Should(Equal("source db is not declared"))
+			})
+		})
+
+		DescribeTable("should return an error when parameters are invalid", func(query string, msg string) {
+			status, body := list(query)
+			Expect(status).Should(Equal(gohttp.StatusBadRequest))
+			err := api.ErrorResponse{}
+			Expect(json.Unmarshal(body, &err)).Should(Succeed())
+			Expect(err.Code).Should(Equal("invalid.parameter"))
+			Expect(err.Details).Should(Equal(msg))
+		},
+			Entry("sort is unknown", "sort=owner", "sort must be one of name, size or mod_time"),
+			Entry("order is unknown", "order=random", "order must be one of asc or desc"),
+			Entry("glob is invalid", "glob=[a-", "glob is not a valid glob pattern"),
+			Entry("glob is too long", "glob=aaaaaaaaaaaaaaaaaaaaa", "glob must not be longer than 20 characters"),
+		)
+	})
+})
@@ -0,0 +1,196 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/dvergnes/log-collector/processor"
+)
+
+const (
+	substringFilterMode = "substring"
+	regexFilterMode     = "regex"
+	globFilterMode      = "glob"
+)
+
+// parseFilter creates the EventFilter defined by the filter, filter_mode, regex and q query parameters. When several
+// parameters are set, the event must pass all of them. The ignore_case and whole_word parameters apply to all of them.
+// The Highlighter returns where the event matches these parameters. It returns nil if no filter is defined.
+func parseFilter(query url.Values, maxPatternLength uint) (processor.EventFilter, processor.Highlighter, error) {
+	ignoreCase, err := parseBool("ignore_case", query.Get("ignore_case"))
+	if err != nil {
+		return nil, nil, err
+	}
+	wholeWord, err := parseBool("whole_word", query.Get("whole_word"))
+	if err != nil {
+		return nil, nil, err
+	}
+	options := processor.MatchOptions{
+		IgnoreCase: ignoreCase,
+		WholeWord:  wholeWord,
+	}
+
+	mode := query.Get("filter_mode")
+	if len(mode) == 0 {
+		mode = substringFilterMode
+	}
+	if mode != substringFilterMode && mode != regexFilterMode && mode != globFilterMode {
+		return nil, nil, httpError{
+			code:       invalidParameter,
+			details:    "filter_mode must be one of substring, regex or glob",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+
+	if mode == globFilterMode && options.WholeWord {
+		return nil, nil, httpError{
+			code:       invalidParameter,
+			details:    "whole_word cannot be used with glob filter mode since a glob pattern matches the entire event",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+
+	var filters []processor.EventFilter
+	var highlighters []processor.Highlighter
+	if filter := query.Get("filter"); len(filter) != 0 {
+		f, h, err := compileFilter("filter", filter, mode, options, maxPatternLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, f)
+		highlighters = append(highlighters, h)
+	}
+	if regex := query.Get("regex"); len(regex) != 0 {
+		f, h, err := compileFilter("regex", regex, regexFilterMode, options, maxPatternLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, f)
+		highlighters = append(highlighters, h)
+	}
+	if q := query.Get("q"); len(q) != 0 {
+		f, h, err := compileQuery(q, options, maxPatternLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, f)
+		highlighters = append(highlighters, h)
+	}
+
+	switch len(filters) {
+	case 0:
+		return nil, nil, nil
+	case 1:
+		return filters[0], highlighters[0], nil
+	default:
+		return processor.And(filters...), processor.HighlightAll(highlighters...), nil
+	}
+}
+
+func compileQuery(q string, options processor.MatchOptions, maxPatternLength uint) (processor.EventFilter,
+	processor.Highlighter, error) {
+	if uint(len(q)) > maxPatternLength {
+		return nil, nil, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("q must not be longer than %d characters", maxPatternLength),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	f, err := processor.ParseQuery(q, options)
+	if syntaxErr, ok := err.(*processor.QuerySyntaxError); ok {
+		return nil, nil, httpError{
+			code:       invalidQuery,
+			details:    syntaxErr.Error(),
+			httpStatus: http.StatusBadRequest,
+			column:     syntaxErr.Column,
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// the query is valid since it has been parsed
+	h, err := processor.HighlightQuery(q, options)
+	return f, h, err
+}
+
+func compileFilter(param string, pattern string, mode string, options processor.MatchOptions, maxPatternLength uint) (processor.EventFilter, processor.Highlighter, error) {
+	if uint(len(pattern)) > maxPatternLength {
+		return nil, nil, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("%s must not be longer than %d characters", param, maxPatternLength),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	switch mode {
+	case regexFilterMode:
+		re, err := processor.CompileRegexp(pattern, options)
+		if err != nil {
+			return nil, nil, httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("%s is not a valid regular expression", param),
+				httpStatus: http.StatusBadRequest,
+			}
+		}
+		return processor.MatchRegexp(re), processor.HighlightRegexp(re, options), nil
+	case globFilterMode:
+		re, err := processor.GlobToRegexp(pattern)
+		if err == nil && options.IgnoreCase {
+			re, err = regexp.Compile("(?i)" + re.String())
+		}
+		if err != nil {
+			return nil, nil, httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("%s is not a valid glob pattern", param),
+				httpStatus: http.StatusBadRequest,
+			}
+		}
+		// a glob pattern matches the entire event
+		return processor.MatchRegexp(re), processor.HighlightRegexp(re, processor.MatchOptions{}), nil
+	default:
+		return processor.ContainsWithOptions(pattern, options), processor.HighlightSubstring(pattern, options), nil
+	}
+}
+
+// combineFilters combines the filter of the events, the where conditions and, when the events are CSV records, the
+// filter of the records, any of which may be nil. It returns nil if there is no filter.
+func combineFilters(filter processor.EventFilter, where processor.EventFilter,
+	csv *processor.CSVParser) processor.EventFilter {
+	var filters []processor.EventFilter
+	if csv != nil {
+		// the header is read from the start of the file, it is not returned as an event
+		filters = append(filters, csv.IsRecord)
+	}
+	for _, f := range []processor.EventFilter{filter, where} {
+		if f != nil {
+			filters = append(filters, f)
+		}
+	}
+	switch len(filters) {
+	case 0:
+		return nil
+	case 1:
+		return filters[0]
+	}
+	return processor.And(filters...)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"net/url"
+
+	"github.com/dvergnes/log-collector/http"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Filter", func() {
+
+	Describe("parseFilter", func() {
+		const maxPatternLength = 20
+
+		When("no filter is defined", func() {
+			It("should return a nil filter", func() {
+				f, h, err := http.ParseFilter(url.Values{}, maxPatternLength)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(f).Should(BeNil())
+				Expect(h).Should(BeNil())
+			})
+		})
+
+		When("parameters are valid", func() {
+			DescribeTable("should return the filter", func(query string, event string, expected bool) {
+				values, err := url.ParseQuery(query)
+				Expect(err).ShouldNot(HaveOccurred())
+				f, _, err := http.ParseFilter(values, maxPatternLength)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(f(event)).Should(Equal(expected))
+			},
+				Entry("substring is the default mode", "filter=GET", `"GET /" 503`, true),
+				Entry("substring is not a pattern", "filter=G.T&filter_mode=substring", `"GET /" 503`, false),
+				Entry("filter is a regular expression", `filter=%22%205\d\d&filter_mode=regex`, `"GET /" 503`, true),
+				Entry("filter is a glob pattern", `filter=*GET*5??&filter_mode=glob`, `"GET /" 503`, true),
+				Entry("regex is applied", `regex=%22%205\d\d`, `"GET /" 404`, false),
+				Entry("filter and regex are both applied", `filter=HEAD&regex=%22%205\d\d`, `"GET /" 503`, false),
+				Entry("query is applied", `q=(GET OR HEAD) 503`, `"GET /" 503`, true),
+				Entry("filter and query are both applied", `filter=HEAD&q=GET`, `"GET /" 503`, false),
+				Entry("case is ignored for substring", `filter=get&ignore_case=true`, `"GET /" 503`, true),
+				Entry("case is ignored for regex", `regex=g.t&ignore_case=true`, `"GET /" 503`, true),
+				Entry("case is ignored for glob", `filter=*get*&filter_mode=glob&ignore_case=true`, `"GET /" 503`, true),
+				Entry("case is ignored for query", `q=get OR head&ignore_case=true`, `"GET /" 503`, true),
+				Entry("whole word is required for substring", `filter=GE&whole_word=true`, `"GET /" 503`, false),
+				Entry("whole word is required for regex", `regex=50\d&whole_word=true`, `"GET /" 503`, true),
+				Entry("whole word is required for query", `q=GE OR 50&whole_word=true`, `"GET /" 503`, false),
+			)
+		})
+
+		When("matches are highlighted", func() {
+			DescribeTable("should return the highlighter of the parameters", func(query string, event string, expected []processor.Span) {
+				values, err := url.ParseQuery(query)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, h, err := http.ParseFilter(values, maxPatternLength)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(h(event)).Should(Equal(expected))
+			},
+				Entry("substring is highlighted", "filter=GET", `"GET /" 503`, []processor.Span{{Start: 1, End: 4}}),
+				Entry("regex is highlighted", `regex=5\d\d&whole_word=true`, `"GET /" 503`, []processor.Span{{Start: 8, End: 11}}),
+				Entry("glob highlights the entire event", `filter=*GET*&filter_mode=glob`, `"GET /" 503`, []processor.Span{{Start: 0, End: 11}}),
+				Entry("terms of query are highlighted", `q=get NOT head&ignore_case=true`, `"GET /" 503`, []processor.Span{{Start: 1, End: 4}}),
+				Entry("all parameters are highlighted", `filter=GET&regex=/&q=503`, `"GET /" 503`, []processor.Span{{Start: 1, End: 4}, {Start: 5, End: 6}, {Start: 8, End: 11}}),
+			)
+		})
+
+		When("parameters are invalid", func() {
+			DescribeTable("should return an error", func(query string, msg string) {
+				values, err := url.ParseQuery(query)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, _, err = http.ParseFilter(values, maxPatternLength)
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("ignore_case is invalid", "filter=GET&ignore_case=maybe", "ignore_case is not a valid boolean"),
+				Entry("whole_word is invalid", "filter=GET&whole_word=maybe", "whole_word is not a valid boolean"),
+				Entry("whole_word is used with glob", "filter=GET*&filter_mode=glob&whole_word=true", "whole_word cannot be used with glob filter mode since a glob pattern matches the entire event"),
+				Entry("filter mode is unknown", "filter=GET&filter_mode=fuzzy", "filter_mode must be one of substring, regex or glob"),
+				Entry("regex is invalid", "regex=[a-", "regex is not a valid regular expression"),
+				Entry("filter is an invalid regular expression", "filter=(GET&filter_mode=regex", "filter is not a valid regular expression"),
+				Entry("filter is an invalid glob pattern", "filter=[a-&filter_mode=glob", "filter is not a valid glob pattern"),
+				Entry("regex is too long", "regex=aaaaaaaaaaaaaaaaaaaaa", "regex must not be longer than 20 characters"),
+				Entry("query is too long", "q=aaaaaaaaaaaaaaaaaaaaa", "q must not be longer than 20 characters"),
+				Entry("query is invalid", "q=GET AND", "unexpected end of query at column 8"),
+			)
+		})
+	})
+})
@@ -0,0 +1,75 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"unicode/utf8"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+)
+
+// highlights returns where the event matches with offsets in bytes and in UTF-16 code units. It returns nil if
+// highlighter is nil or if the event does not match. An invalid UTF-8 byte counts as one code unit since it is encoded
+// as the replacement character in JSON.
+func highlights(highlighter processor.Highlighter, event string) []api.Span {
+	if highlighter == nil {
+		return nil
+	}
+	spans := highlighter(event)
+	if len(spans) == 0 {
+		return nil
+	}
+	// the spans are sorted so that the code units are counted in a single pass over the event
+	offset, units := 0, 0
+	advance := func(to int) int {
+		for offset < to {
+			r, size := utf8.DecodeRuneInString(event[offset:])
+			// the characters outside the basic multilingual plane are encoded as a surrogate pair
+			if r > 0xffff {
+				units += 2
+			} else {
+				units++
+			}
+			offset += size
+		}
+		return units
+	}
+	result := make([]api.Span, 0, len(spans))
+	for _, span := range spans {
+		start := advance(span.Start)
+		result = append(result, api.Span{
+			Start:      span.Start,
+			End:        span.End,
+			StartUTF16: start,
+			EndUTF16:   advance(span.End),
+		})
+	}
+	return result
+}
+
+// highlightedEvents returns the events with the location of their matches
+func highlightedEvents(highlighter processor.Highlighter, events []string) []api.LogEvent {
+	result := make([]api.LogEvent, 0, len(events))
+	for _, event := range events {
+		result = append(result, api.LogEvent{Event: event, Highlights: highlights(highlighter, event)})
+	}
+	return result
+}
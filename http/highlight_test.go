@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/http"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Highlight", func() {
+
+	Describe("highlights", func() {
+		DescribeTable("should return the offsets in bytes and in UTF-16 code units", func(substr string, event string, expected []api.Span) {
+			Expect(http.Highlights(processor.HighlightSubstring(substr, processor.MatchOptions{}), event)).Should(Equal(expected))
+		},
+			Entry("ASCII offsets are equal", "GET", `"GET /"`, []api.Span{{Start: 1, End: 4, StartUTF16: 1, EndUTF16: 4}}),
+			Entry("multi-byte characters are one code unit", "été", "l'été", []api.Span{{Start: 2, End: 7, StartUTF16: 2, EndUTF16: 5}}),
+			Entry("characters outside the basic multilingual plane are two code units", "ok", "🔥 ok 🔥 ok", []api.Span{
+				{Start: 5, End: 7, StartUTF16: 3, EndUTF16: 5},
+				{Start: 13, End: 15, StartUTF16: 9, EndUTF16: 11},
+			}),
+			Entry("invalid bytes are one code unit", "ok", "\xff\xfe ok", []api.Span{{Start: 3, End: 5, StartUTF16: 3, EndUTF16: 5}}),
+			Entry("event does not match", "KO", "ok", nil),
+		)
+
+		When("highlighter is nil", func() {
+			It("should return nil", func() {
+				Expect(http.Highlights(nil, "ok")).Should(BeNil())
+			})
+		})
+	})
+})
@@ -0,0 +1,261 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+// isMerge returns whether the file parameters refer to several files
+func isMerge(files []string) bool {
+	return len(files) > 1 || hasWildcard(files[0])
+}
+
+// severalFilesErr rejects a request that reads several files, reason tells what does not apply to several files e.g.
+// "follow cannot be used"
+func severalFilesErr(reason string) httpError {
+	return httpError{
+		code:       invalidParameter,
+		details:    reason + " when several files are read",
+		httpStatus: http.StatusBadRequest,
+	}
+}
+
+// expandFiles resolves the given file parameters, which must have been validated by validateFileParameter, into the
+// paths of the files to read. A file parameter containing wildcards is expanded into the regular files it matches in the
+// folder of the source, the files that cannot be read according to the configuration are skipped.
+func expandFiles(fs afero.Fs, config *Config, files []string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, file := range files {
+		folder, name, err := sourceFolder(config.LogFolder, file)
+		if err != nil {
+			return nil, err
+		}
+		if !hasWildcard(file) {
+			path, err := resolvePath(fs, folder, name, config.FollowSymlinks)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkFile(fs, path); err != nil {
+				return nil, err
+			}
+			add(path)
+			continue
+		}
+
+		matches, err := afero.Glob(fs, filepath.Join(folder, name))
+		if err != nil {
+			return nil, httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("file %s is not a valid glob pattern", file),
+				httpStatus: http.StatusBadRequest,
+			}
+		}
+		found := false
+		for _, match := range matches {
+			// the match is resolved again as a file parameter so that it is confined to the folder of the source
+			param := fileParameter(config.LogFolder, match)
+			if validateFileParameter(param) != nil {
+				continue
+			}
+			folder, name, err := sourceFolder(config.LogFolder, param)
+			if err != nil {
+				continue
+			}
+			path, err := resolvePath(fs, folder, name, config.FollowSymlinks)
+			if err != nil {
+				continue
+			}
+			info, err := fs.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			found = true
+			add(path)
+		}
+		if !found {
+			return nil, httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("file %s does not match any file", file),
+				httpStatus: http.StatusNotFound,
+			}
+		}
+	}
+	if uint(len(paths)) > config.MaxMergedFiles {
+		return nil, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("files refer to more than %d files", config.MaxMergedFiles),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return paths, nil
+}
+
+// fileParameter returns the file parameter that refers to the given path of a file matched in the given folder, whose
+// wildcards are replaced by the matched folders
+func fileParameter(folder string, path string) string {
+	folderElements := strings.Split(filepath.Clean(folder), "/")
+	pathElements := strings.Split(path, "/")
+	var param []string
+	for i, element := range folderElements {
+		if hasWildcard(element) {
+			param = append(param, pathElements[i])
+		}
+	}
+	return strings.Join(append(param, pathElements[len(folderElements):]...), "/")
+}
+
+// mergeProcessor records the file of the events returned by the merge of several files
+type mergeProcessor struct {
+	processor.EventProcessor
+
+	merge *processor.MergeEventProcessor
+	paths []string
+	// eventFiles contains the file of each event returned
+	eventFiles []string
+}
+
+// Next implements EventProcessor contract
+func (m *mergeProcessor) Next() (string, error) {
+	event, err := m.EventProcessor.Next()
+	if err == nil {
+		m.eventFiles = append(m.eventFiles, m.paths[m.merge.Source()])
+	}
+	return event, err
+}
+
+// lastFile returns the file of the last event returned
+func (m *mergeProcessor) lastFile() string {
+	return m.eventFiles[len(m.eventFiles)-1]
+}
+
+// mergeFiles returns the events of the given files merged from the most recent to the oldest one according to their
+// timestamp. The time range applies to each file while the filter and the limit apply to the merged events. The events
+// are parsed if parser is not nil and the matches are highlighted if highlighter is not nil.
+func mergeFiles(ctx context.Context, w http.ResponseWriter, fs afero.Fs, config *Config, files []string,
+	timeRange timeRange, filter processor.EventFilter, limit uint, format string, parser processor.EventParser,
+	highlighter processor.Highlighter, logger *zap.Logger) {
+	paths, err := expandFiles(fs, config, files)
+	if err != nil {
+		handleError(w, err, logger)
+		return
+	}
+
+	readers := make([]*processor.RotatedTailReader, 0, len(paths))
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+	processors := make([]processor.EventProcessor, 0, len(paths))
+	for _, path := range paths {
+		reader, err := processor.NewRotatedTailReader(fs, path, config.decompressionCache)
+		if err != nil {
+			logger.Error("failed to open reader", zap.Error(err))
+			handleError(w, err, logger)
+			return
+		}
+		readers = append(readers, reader)
+		pl, err := createFileProcessor(reader, config, timeRange, false)
+		if err != nil {
+			logger.Error("failed to create processor", zap.Error(err))
+			handleError(w, err, logger)
+			return
+		}
+		processors = append(processors, pl)
+	}
+	readFiles := func() []string {
+		files := []string{}
+		for _, reader := range readers {
+			files = append(files, reader.Files()...)
+		}
+		return files
+	}
+
+	logger.Sugar().Infow("merging files",
+		"files", paths,
+		"since", timeRange.since,
+		"until", timeRange.until,
+		"limit", limit,
+		"format", format,
+		"parser", parser != nil)
+	merge := processor.NewMergeEventProcessor(processors, config.timestamps())
+	p := processor.EventProcessor(merge)
+	if filter != nil {
+		p = processor.WithFilter(p, filter)
+	}
+	mp := &mergeProcessor{
+		EventProcessor: processor.WithLimit(p, limit),
+		merge:          merge,
+		paths:          paths,
+	}
+	if format == ndjsonFormat {
+		noCursor := func(int) (string, error) {
+			return "", nil
+		}
+		stream := newEventStream(w, parser)
+		stream.highlighter = highlighter
+		streamFile(ctx, stream, "", mp, readFiles, mp.lastFile, noCursor, logger)
+		return
+	}
+
+	events, err := processFile(ctx, mp)
+	if err != nil {
+		logger.Error("failed to process files", zap.Error(err))
+		handleError(w, err, logger)
+		return
+	}
+	if parser != nil {
+		writeJSONResponse(w, api.StructuredLogResponse{
+			Files:  readFiles(),
+			Events: structuredEvents(parser, events, mp.eventFiles),
+		}, logger)
+		return
+	}
+	resp := api.MergedLogResponse{
+		Files:  readFiles(),
+		Events: make([]api.LogEvent, 0, len(events)),
+	}
+	for i, event := range events {
+		resp.Events = append(resp.Events, api.LogEvent{
+			Event:      event,
+			File:       mp.eventFiles[i],
+			Highlights: highlights(highlighter, event),
+		})
+	}
+	writeJSONResponse(w, resp, logger)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Merge", func() {
+
+	Describe("expandFiles", func() {
+		var (
+			fs     afero.Fs
+			config *http.Config
+		)
+
+		BeforeEach(func() {
+			fs = afero.NewMemMapFs()
+			for _, file := range []string{"/var/log/a.log", "/var/log/b.log", "/var/log/c.txt", "/var/log/nginx/access.log",
+				"/var/log/nginx/error.log", "/srv/shop/logs/api.log", "/srv/blog/logs/api.log"} {
+				Expect(afero.WriteFile(fs, file, []byte("event\n"), 0644)).Should(Succeed())
+			}
+			Expect(fs.MkdirAll("/var/log/d.log", 0755)).Should(Succeed())
+			config = &http.Config{LogFolder: "/var/log", MaxMergedFiles: 3}
+		})
+
+		DescribeTable("should return the files to read", func(folder string, files []string, expected []string) {
+			config.LogFolder = folder
+			paths, err := http.ExpandFiles(fs, config, files)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(paths).Should(Equal(expected))
+		},
+			Entry("files are listed", "/var/log", []string{"b.log", "a.log"}, []string{"/var/log/b.log", "/var/log/a.log"}),
+			Entry("files are duplicated", "/var/log", []string{"a.log", "a.log"}, []string{"/var/log/a.log"}),
+			Entry("directories are not matched", "/var/log", []string{"*.log"}, []string{"/var/log/a.log", "/var/log/b.log"}),
+			Entry("pattern is in a sub folder", "/var/log", []string{"nginx/*.log"},
+				[]string{"/var/log/nginx/access.log", "/var/log/nginx/error.log"}),
+			Entry("pattern matches the folders of a source", "/srv/*/logs", []string{"*/api.log"},
+				[]string{"/srv/blog/logs/api.log", "/srv/shop/logs/api.log"}),
+		)
+
+		DescribeTable("should return an error", func(files []string, msg string) {
+			_, err := http.ExpandFiles(fs, config, files)
+			Expect(err).Should(MatchError(msg))
+		},
+			Entry("file does not exist", []string{"a.log", "z.log"}, "file /var/log/z.log was not found"),
+			Entry("pattern matches no file", []string{"*.json"}, "file *.json does not match any file"),
+			Entry("pattern is invalid", []string{"[a-.log"}, "file [a-.log is not a valid glob pattern"),
+			Entry("too many files are matched", []string{"*.log", "*.txt", "nginx/*"}, "files refer to more than 3 files"),
+		)
+
+		When("a matched file is a symbolic link", func() {
+			BeforeEach(func() {
+				folder := GinkgoT().TempDir()
+				fs = afero.NewOsFs()
+				Expect(afero.WriteFile(fs, filepath.Join(folder, "a.log"), []byte("event\n"), 0644)).Should(Succeed())
+				Expect(os.Symlink("/etc/passwd", filepath.Join(folder, "b.log"))).Should(Succeed())
+				config.LogFolder = folder
+			})
+
+			It("should skip it", func() {
+				paths, err := http.ExpandFiles(fs, config, []string{"*.log"})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(paths).Should(Equal([]string{filepath.Join(config.LogFolder, "a.log")}))
+			})
+		})
+	})
+})
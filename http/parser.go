@@ -0,0 +1,225 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+)
+
+const (
+	rawParser      = "raw"
+	combinedParser = "combined"
+	jsonParser     = "json"
+	logfmtParser   = "logfmt"
+	csvParser      = "csv"
+	tsvParser      = "tsv"
+	syslogParser   = "syslog"
+	rfc3164Parser  = "rfc3164"
+	rfc5424Parser  = "rfc5424"
+)
+
+// builtinParsers lists the names of the parsers that are not declared in the configuration
+var builtinParsers = []string{rawParser, combinedParser, jsonParser, logfmtParser, csvParser, tsvParser, syslogParser,
+	rfc3164Parser, rfc5424Parser}
+
+// whereOperators lists the operators of the where conditions, the operators that start with another operator come first
+var whereOperators = []struct {
+	token    string
+	operator processor.Operator
+}{
+	{token: "!=", operator: processor.NotEqual},
+	{token: ">=", operator: processor.GreaterOrEqual},
+	{token: "<=", operator: processor.LessOrEqual},
+	{token: ">", operator: processor.Greater},
+	{token: "<", operator: processor.Less},
+	{token: ":", operator: processor.Equal},
+	{token: "=", operator: processor.Equal},
+}
+
+// parseEventParser returns the parser selected by the parser parameter among the built-in parsers and the grok parsers of
+// the configuration, nil if the events are not parsed
+func parseEventParser(name string, config *Config) (processor.EventParser, error) {
+	switch name {
+	case "", rawParser:
+		return nil, nil
+	case combinedParser:
+		return processor.CombinedLogParser, nil
+	case jsonParser:
+		return processor.JSONParser, nil
+	case logfmtParser:
+		return processor.LogfmtParser, nil
+	case csvParser:
+		return processor.NewCSVParser(','), nil
+	case tsvParser:
+		return processor.NewCSVParser('\t'), nil
+	case syslogParser:
+		return processor.NewSyslogParser(time.Now(), time.Local), nil
+	case rfc3164Parser:
+		return processor.NewRFC3164Parser(time.Now(), time.Local), nil
+	case rfc5424Parser:
+		return processor.RFC5424Parser, nil
+	}
+	if parser, ok := config.grokParsers[name]; ok {
+		return parser, nil
+	}
+	return nil, httpError{
+		code:       invalidParameter,
+		details:    "parser must be one of " + config.parserNames(),
+		httpStatus: http.StatusBadRequest,
+	}
+}
+
+// parserNames lists the names of the built-in parsers followed by the names of the grok parsers
+func (c *Config) parserNames() string {
+	names := append([]string{}, builtinParsers...)
+	grok := make([]string, 0, len(c.grokParsers))
+	for name := range c.grokParsers {
+		grok = append(grok, name)
+	}
+	sort.Strings(grok)
+	names = append(names, grok...)
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// parseParserParameters parses the parser, where and fields parameters. When the parser parameter is not set, the parser
+// rules of the configuration apply to the file parameter. It returns the parser of the events returned, which only
+// returns the selected fields, and the filter of the where conditions. Both are nil if the events are not parsed and the
+// filter is nil if there is no condition. It also returns the CSV parser whose header must be read from the file, nil if
+// the events are not CSV records.
+func parseParserParameters(query url.Values, config *Config) (processor.EventParser, processor.EventFilter,
+	*processor.CSVParser, error) {
+	name := query.Get("parser")
+	if len(name) == 0 {
+		name = config.parserOf(query.Get("file"))
+	}
+	parser, err := parseEventParser(name, config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	csv, _ := parser.(*processor.CSVParser)
+	if parser == nil {
+		for _, param := range []string{"where", "fields"} {
+			if _, ok := query[param]; ok {
+				return nil, nil, nil, httpError{
+					code:       invalidParameter,
+					details:    fmt.Sprintf("%s cannot be used without parser", param),
+					httpStatus: http.StatusBadRequest,
+				}
+			}
+		}
+		return nil, nil, nil, nil
+	}
+
+	var where processor.EventFilter
+	if values := query["where"]; len(values) > 0 {
+		conditions := make([]processor.Condition, 0, len(values))
+		for _, value := range values {
+			condition, err := parseCondition(value, config.MaxPatternLength)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		where = processor.Where(parser, conditions)
+	}
+
+	if fields := query.Get("fields"); len(fields) > 0 {
+		var paths []processor.FieldPath
+		for _, field := range strings.Split(fields, ",") {
+			path, ok := processor.ParseFieldPath(strings.TrimSpace(field))
+			if !ok {
+				return nil, nil, nil, httpError{
+					code:       invalidParameter,
+					details:    fmt.Sprintf("fields contains an invalid field path %q", field),
+					httpStatus: http.StatusBadRequest,
+				}
+			}
+			paths = append(paths, path)
+		}
+		parser = processor.WithProjection(parser, paths)
+	}
+	return parser, where, csv, nil
+}
+
+// parseCondition parses a where condition formed as a field path, an operator and a value e.g. http.status>=500. A
+// condition whose value cannot be compared with the value of the field is not satisfied.
+func parseCondition(value string, maxPatternLength uint) (processor.Condition, error) {
+	if uint(len(value)) > maxPatternLength {
+		return processor.Condition{}, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("where must not be longer than %d characters", maxPatternLength),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	invalidErr := httpError{
+		code:       invalidParameter,
+		details:    fmt.Sprintf("where %s must be formed as a field, one of :, =, !=, >, >=, < or <= and a value", value),
+		httpStatus: http.StatusBadRequest,
+	}
+	end := strings.IndexAny(value, ":=!<>")
+	if end < 0 {
+		return processor.Condition{}, invalidErr
+	}
+	path, ok := processor.ParseFieldPath(value[:end])
+	if !ok {
+		return processor.Condition{}, invalidErr
+	}
+	for _, operator := range whereOperators {
+		if strings.HasPrefix(value[end:], operator.token) {
+			return processor.Condition{
+				Path:     path,
+				Operator: operator.operator,
+				Value:    value[end+len(operator.token):],
+			}, nil
+		}
+	}
+	return processor.Condition{}, invalidErr
+}
+
+// structuredEvent parses the given event of the given file. The event is returned as is, with a parse error flag, when
+// it cannot be parsed.
+func structuredEvent(parser processor.EventParser, event string, file string) api.StructuredEvent {
+	fields, err := parser.Parse(event)
+	if err != nil {
+		return api.StructuredEvent{Raw: event, ParseError: true, File: file}
+	}
+	return api.StructuredEvent{Fields: fields, File: file}
+}
+
+// structuredEvents parses the given events, files contains the file of each event when several files are read at once
+func structuredEvents(parser processor.EventParser, events []string, files []string) []api.StructuredEvent {
+	structured := make([]api.StructuredEvent, 0, len(events))
+	for i, event := range events {
+		file := ""
+		if files != nil {
+			file = files[i]
+		}
+		structured = append(structured, structuredEvent(parser, event, file))
+	}
+	return structured
+}
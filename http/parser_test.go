@@ -0,0 +1,135 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/dvergnes/log-collector/http"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parser", func() {
+
+	Describe("parseEventParser", func() {
+		It("should return the selected parser", func() {
+			parser, err := http.ParseEventParser("combined", &http.Config{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parser).ShouldNot(BeNil())
+		})
+
+		It("should not parse the events when no parser is selected", func() {
+			parser, err := http.ParseEventParser("", &http.Config{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(parser).Should(BeNil())
+		})
+
+		It("should reject an unknown parser", func() {
+			_, err := http.ParseEventParser("xml", &http.Config{})
+			Expect(err).Should(MatchError("parser must be one of raw, combined, json, logfmt, csv, tsv, syslog, rfc3164 or rfc5424"))
+		})
+	})
+
+	Describe("parseParserParameters", func() {
+		parse := func(query string) (processor.EventParser, processor.EventFilter, error) {
+			values, err := url.ParseQuery(query)
+			Expect(err).ShouldNot(HaveOccurred())
+			parser, where, _, err := http.ParseParserParameters(values, &http.Config{
+				MaxPatternLength: 30,
+				Parsers: []http.ParserRule{
+					{Files: "syslog*", Parser: "syslog"},
+					{Files: "*.json", Parser: "json"},
+					{Files: "*", Parser: "raw"},
+				},
+			})
+			return parser, where, err
+		}
+
+		It("should return the CSV parser whose header must be read", func() {
+			values, err := url.ParseQuery("parser=tsv&fields=user")
+			Expect(err).ShouldNot(HaveOccurred())
+			parser, _, csv, err := http.ParseParserParameters(values, &http.Config{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(csv).ShouldNot(BeNil())
+			Expect(csv.ReadHeader(strings.NewReader("time\tuser\n"), 100)).Should(Succeed())
+			Expect(parser.Parse("2022-01-01\talice")).Should(Equal(processor.Fields{"user": "alice"}))
+
+			values, err = url.ParseQuery("parser=json")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, _, csv, err = http.ParseParserParameters(values, &http.Config{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(csv).Should(BeNil())
+		})
+
+		DescribeTable("should filter the events on their fields", func(query string, event string, expected bool) {
+			_, where, err := parse(query)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(where(event)).Should(Equal(expected))
+		},
+			Entry("field is equal", "parser=json&where=level:error", `{"level": "error"}`, true),
+			Entry("nested field is compared", "parser=json&where=http.status>%3D500", `{"http": {"status": 503}}`, true),
+			Entry("all conditions must be satisfied", "parser=json&where=level:error&where=http.status<500",
+				`{"level": "error", "http": {"status": 503}}`, false),
+			Entry("value contains an operator", "parser=json&where=url:/a?b=c", `{"url": "/a?b=c"}`, true),
+			Entry("event cannot be parsed", "parser=json&where=level!%3Derror", "level=info", false),
+			Entry("fields of the combined parser are compared", "parser=combined&where=status>%3D500",
+				`::1 - - [05/Oct/2020:10:32:51 +0000] "GET / HTTP/1.1" 503 12 "-" "-"`, true),
+			Entry("equal sign is an equality", "parser=syslog&where=app%3Dsshd", "<38>Jan  2 07:00:00 host sshd[1]: failed", true),
+			Entry("severity is compared by name", "parser=syslog&where=severity<%3Dwarning",
+				"<38>Jan  2 07:00:00 host sshd[1]: failed", false),
+			Entry("value is not comparable", "parser=json&where=status>high", `{"status": 503}`, false),
+			Entry("parser rule of the file applies", "file=syslog.1&where=app:sshd", "<38>Jan  2 07:00:00 host sshd[1]: failed", true),
+			Entry("parser parameter overrides the rules", "file=app.json&parser=combined&where=status:503",
+				`::1 - - [05/Oct/2020:10:32:51 +0000] "GET / HTTP/1.1" 503 12 "-" "-"`, true),
+		)
+
+		It("should only return the selected fields", func() {
+			parser, where, err := parse("parser=json&fields=level,%20http.status")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(where).Should(BeNil())
+			fields, err := parser.Parse(`{"level": "error", "msg": "oops", "http": {"status": 503, "method": "GET"}}`)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields).Should(Equal(processor.Fields{
+				"level": "error",
+				"http":  map[string]interface{}{"status": json.Number("503")},
+			}))
+		})
+
+		DescribeTable("should return an error", func(query string, msg string) {
+			_, _, err := parse(query)
+			Expect(err).Should(MatchError(msg))
+		},
+			Entry("where is used without parser", "where=level:error", "where cannot be used without parser"),
+			Entry("raw parser is selected", "file=app.json&parser=raw&where=level:error", "where cannot be used without parser"),
+			Entry("raw parser rule applies", "file=app.log&fields=level", "fields cannot be used without parser"),
+			Entry("fields is used without parser", "fields=level", "fields cannot be used without parser"),
+			Entry("condition has no operator", "parser=json&where=level", "where level must be formed as a field, one of :, =, !=, >, >=, < or <= and a value"),
+			Entry("condition has no field", "parser=json&where=:error", "where :error must be formed as a field, one of :, =, !=, >, >=, < or <= and a value"),
+			Entry("operator is unknown", "parser=json&where=level!error", "where level!error must be formed as a field, one of :, =, !=, >, >=, < or <= and a value"),
+			Entry("condition is too long", "parser=json&where=message:aaaaaaaaaaaaaaaaaaaaaaaaaaa", "where must not be longer than 30 characters"),
+			Entry("field path is invalid", "parser=json&fields=level,,msg", `fields contains an invalid field path ""`),
+		)
+	})
+})
@@ -0,0 +1,138 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// maxSymlinks is the maximum number of symbolic links followed to resolve a path, like the OS does
+const maxSymlinks = 40
+
+// resolvePath resolves the given file, which must have been validated by validateFileParameter, in the log folder. Each
+// element of the path is resolved so that a symbolic link cannot lead outside the log folder. The symbolic links are
+// only followed if followSymlinks is true. A path that does not exist is returned as is, so that the caller reports it.
+func resolvePath(fs afero.Fs, logFolder string, file string, followSymlinks bool) (string, error) {
+	root := filepath.Clean(logFolder)
+	resolved := root
+	remaining := strings.Split(file, "/")
+	links := 0
+	for len(remaining) > 0 {
+		element := remaining[0]
+		remaining = remaining[1:]
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			// a symbolic link may refer to a parent folder
+			if resolved == root {
+				return "", outsideLogFolderErr(file)
+			}
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, element)
+		info, err := lstat(fs, next)
+		if os.IsNotExist(err) {
+			// the remaining elements may come from the target of a symbolic link and refer to a parent folder, which
+			// cannot be resolved once an element does not exist
+			for _, e := range remaining {
+				if e == ".." {
+					return "", outsideLogFolderErr(file)
+				}
+			}
+			return filepath.Join(append([]string{next}, remaining...)...), nil
+		}
+		if err != nil {
+			return "", internalErr
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if !followSymlinks {
+			return "", httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("file %s refers to a symbolic link, which is not followed", file),
+				httpStatus: http.StatusForbidden,
+			}
+		}
+		links++
+		if links > maxSymlinks {
+			return "", httpError{
+				code:       invalidParameter,
+				details:    fmt.Sprintf("file %s refers to too many symbolic links", file),
+				httpStatus: http.StatusBadRequest,
+			}
+		}
+		target, err := readlink(fs, next)
+		if err != nil {
+			return "", internalErr
+		}
+		if filepath.IsAbs(target) {
+			rel, err := filepath.Rel(root, filepath.Clean(target))
+			if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+				return "", outsideLogFolderErr(file)
+			}
+			resolved = root
+			target = rel
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return resolved, nil
+}
+
+func outsideLogFolderErr(file string) httpError {
+	return httpError{
+		code:       invalidParameter,
+		details:    fmt.Sprintf("file %s refers to a file outside of the log folder", file),
+		httpStatus: http.StatusForbidden,
+	}
+}
+
+// lstat returns the FileInfo of the given file without following symbolic links when the file system supports it
+func lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return fs.Stat(name)
+}
+
+// readlink returns the target of the given symbolic link
+func readlink(fs afero.Fs, name string) (string, error) {
+	reader, ok := fs.(afero.LinkReader)
+	if !ok {
+		return "", fmt.Errorf("failed to read symbolic link %s: file system does not support symbolic links", name)
+	}
+	target, err := reader.ReadlinkIfPossible(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read symbolic link %w", err)
+	}
+	return target, nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Path", func() {
+
+	Describe("resolvePath", func() {
+
+		When("file system does not support symbolic links", func() {
+			const logFolder = "/var/log"
+			var fs afero.Fs
+
+			BeforeEach(func() {
+				fs = afero.NewMemMapFs()
+				for _, name := range []string{"/var/log/nginx/access.log", "/var/log/..foo.log", "/var/log/.../bar.log", "/etc/passwd"} {
+					Expect(afero.WriteFile(fs, name, []byte("event\n"), 0644)).Should(Succeed())
+				}
+			})
+
+			DescribeTable("should resolve the file in the log folder", func(file string, expected string) {
+				path, err := http.ResolvePath(fs, logFolder, file, true)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(path).Should(Equal(expected))
+			},
+				Entry("file is in a sub folder", "nginx/access.log", "/var/log/nginx/access.log"),
+				Entry("file name starts with dots", "..foo.log", "/var/log/..foo.log"),
+				Entry("folder name is made of dots", ".../bar.log", "/var/log/.../bar.log"),
+				Entry("file does not exist", "nginx/missing/error.log", "/var/log/nginx/missing/error.log"),
+				Entry("file is the log folder", ".", "/var/log"),
+			)
+		})
+
+		When("file system supports symbolic links", func() {
+			var (
+				fs        afero.Fs
+				root      string
+				logFolder string
+			)
+
+			BeforeEach(func() {
+				fs = afero.NewOsFs()
+				root = GinkgoT().TempDir()
+				logFolder = filepath.Join(root, "log")
+				Expect(fs.MkdirAll(filepath.Join(logFolder, "nginx"), 0755)).Should(Succeed())
+				Expect(afero.WriteFile(fs, filepath.Join(logFolder, "nginx", "access.log"), []byte("event\n"), 0644)).Should(Succeed())
+				Expect(afero.WriteFile(fs, filepath.Join(root, "secret"), []byte("secret\n"), 0644)).Should(Succeed())
+				for link, target := range map[string]string{
+					"current.log":       "nginx/access.log",
+					"absolute.log":      filepath.Join(logFolder, "nginx", "access.log"),
+					"nginx_link":        "nginx",
+					"nginx/parent":      "..",
+					"nginx/grandparent": "../..",
+					"escape.log":        "../secret",
+					"missing_escape":    "missing/../../secret",
+					"absolute_escape":   filepath.Join(root, "secret"),
+					"prefix_escape":     logFolder + "_other/secret",
+					"loop_a":            "loop_b",
+					"loop_b":            "loop_a",
+				} {
+					Expect(os.Symlink(target, filepath.Join(logFolder, link))).Should(Succeed())
+				}
+			})
+
+			DescribeTable("should follow the symbolic links inside the log folder", func(file string, expected string) {
+				path, err := http.ResolvePath(fs, logFolder, file, true)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(path).Should(Equal(filepath.Join(logFolder, expected)))
+			},
+				Entry("link is relative", "current.log", "nginx/access.log"),
+				Entry("link is absolute", "absolute.log", "nginx/access.log"),
+				Entry("link is a folder", "nginx_link/access.log", "nginx/access.log"),
+				Entry("link refers to a parent folder inside the log folder", "nginx/parent/nginx/access.log", "nginx/access.log"),
+			)
+
+			DescribeTable("should refuse the files outside the log folder", func(file string, msg string) {
+				_, err := http.ResolvePath(fs, logFolder, file, true)
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("link refers to a parent folder", "escape.log", "file escape.log refers to a file outside of the log folder"),
+				Entry("link refers to the parent of the log folder", "nginx/grandparent/secret",
+					"file nginx/grandparent/secret refers to a file outside of the log folder"),
+				Entry("link is absolute", "absolute_escape", "file absolute_escape refers to a file outside of the log folder"),
+				Entry("link shares a prefix with the log folder", "prefix_escape",
+					"file prefix_escape refers to a file outside of the log folder"),
+				Entry("link refers to a parent folder through a missing folder", "missing_escape",
+					"file missing_escape refers to a file outside of the log folder"),
+				Entry("links form a loop", "loop_a", "file loop_a refers to too many symbolic links"),
+			)
+
+			When("symbolic links are not followed", func() {
+				It("should refuse the symbolic links", func() {
+					_, err := http.ResolvePath(fs, logFolder, "nginx_link/access.log", false)
+					Expect(err).Should(MatchError("file nginx_link/access.log refers to a symbolic link, which is not followed"))
+				})
+
+				It("should resolve the other files", func() {
+					path, err := http.ResolvePath(fs, logFolder, "nginx/access.log", false)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(path).Should(Equal(filepath.Join(logFolder, "nginx", "access.log")))
+				})
+			})
+		})
+	})
+})
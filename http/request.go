@@ -0,0 +1,152 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+)
+
+// logRequest contains the parameters of a request to the log handler
+type logRequest struct {
+	files       []string
+	conf        *Config
+	limit       uint
+	follow      bool
+	format      string
+	filter      processor.EventFilter
+	highlighter processor.Highlighter
+	highlight   bool
+	parser      processor.EventParser
+	where       processor.EventFilter
+	csv         *processor.CSVParser
+	contextSize contextSize
+	timeRange   timeRange
+	cursor      string
+}
+
+// parseLogRequest parses each parameter of a request to the log handler, the combinations of parameters are checked
+// by validate
+func parseLogRequest(query url.Values, header http.Header, config *Config) (*logRequest, error) {
+	r := logRequest{files: query["file"], cursor: query.Get("cursor")}
+	if len(r.files) == 0 {
+		r.files = []string{""}
+	}
+	for _, file := range r.files {
+		if err := validateFileParameter(file); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if r.conf, err = config.source(query.Get("source")); err != nil {
+		return nil, err
+	}
+	if r.limit, err = parseLimit(r.conf.MaxEvents, query.Get("limit")); err != nil {
+		return nil, err
+	}
+	if r.limit == 0 {
+		r.limit = r.conf.MaxEvents
+	}
+	if r.follow, err = parseBool("follow", query.Get("follow")); err != nil {
+		return nil, err
+	}
+	if r.filter, r.highlighter, err = parseFilter(query, r.conf.MaxPatternLength); err != nil {
+		return nil, err
+	}
+	if r.highlight, err = parseBool("highlight", query.Get("highlight")); err != nil {
+		return nil, err
+	}
+	if r.format, err = parseFormat(query, header); err != nil {
+		return nil, err
+	}
+	if r.parser, r.where, r.csv, err = parseParserParameters(query, r.conf); err != nil {
+		return nil, err
+	}
+	if r.contextSize, err = parseContextSize(query); err != nil {
+		return nil, err
+	}
+	if r.timeRange, err = parseTimeRange(query, time.Now()); err != nil {
+		return nil, err
+	}
+	if r.csv != nil {
+		r.conf = r.conf.withCSVRecords()
+	}
+	// the matches are only highlighted on demand
+	if !r.highlight {
+		r.highlighter = nil
+	}
+	return &r, nil
+}
+
+// validate checks that the parameters of the request can be used together
+func (r *logRequest) validate() error {
+	if r.highlight {
+		if r.highlighter == nil {
+			return invalidCombinationErr("highlight cannot be used without filter, regex or q")
+		}
+		// the offsets of the matches refer to the raw event
+		if r.parser != nil {
+			return invalidCombinationErr("highlight cannot be used with parser")
+		}
+	}
+	if !r.contextSize.isZero() {
+		if r.filter == nil && r.where == nil {
+			return invalidCombinationErr("before and after cannot be used without filter")
+		}
+		if r.follow || r.isMerge() {
+			return invalidCombinationErr("before and after cannot be used with follow or when several files are read")
+		}
+	}
+	if r.csv != nil && (r.follow || r.isMerge()) {
+		return invalidCombinationErr("csv and tsv parsers cannot be used with follow or when several files are read")
+	}
+	if r.follow && !r.timeRange.until.IsZero() {
+		return invalidCombinationErr("until cannot be used with follow since the followed events are always the most recent ones")
+	}
+	if r.isMerge() && (r.follow || r.cursor != "") {
+		return severalFilesErr("follow and cursor cannot be used")
+	}
+	if r.follow && r.cursor != "" {
+		return invalidCombinationErr("cursor cannot be used with follow")
+	}
+	return nil
+}
+
+// isMerge returns whether the request reads several files
+func (r *logRequest) isMerge() bool {
+	return isMerge(r.files)
+}
+
+// eventFilter returns the filter of the events that combines the text filter, the where clauses and the filter of the
+// csv header
+func (r *logRequest) eventFilter() processor.EventFilter {
+	return combineFilters(r.filter, r.where, r.csv)
+}
+
+func invalidCombinationErr(details string) httpError {
+	return httpError{
+		code:       invalidParameter,
+		details:    details,
+		httpStatus: http.StatusBadRequest,
+	}
+}
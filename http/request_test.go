@@ -0,0 +1,107 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	gohttp "net/http"
+	"net/url"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Request", func() {
+
+	config := &http.Config{
+		LogFolder:        "/var/log",
+		MaxEvents:        10,
+		MaxPatternLength: 20,
+	}
+
+	Describe("parseLogRequest", func() {
+		It("should apply the defaults of the configuration", func() {
+			r, err := http.ParseLogRequest(url.Values{"file": {"foo.log"}}, gohttp.Header{}, config)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(r.Limit()).Should(Equal(uint(10)))
+			Expect(r.IsMerge()).Should(BeFalse())
+		})
+
+		It("should return the error of the first invalid parameter", func() {
+			values, err := url.ParseQuery("file=foo.log&limit=11&follow=maybe")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = http.ParseLogRequest(values, gohttp.Header{}, config)
+			Expect(err).Should(MatchError("limit must be equal or less than 10"))
+		})
+	})
+
+	Describe("validate", func() {
+		validate := func(query string) error {
+			values, err := url.ParseQuery(query)
+			Expect(err).ShouldNot(HaveOccurred())
+			r, err := http.ParseLogRequest(values, gohttp.Header{}, config)
+			Expect(err).ShouldNot(HaveOccurred())
+			return r.Validate()
+		}
+
+		DescribeTable("should accept the valid combinations", func(query string) {
+			Expect(validate(query)).Should(Succeed())
+		},
+			Entry("single file", "file=foo.log"),
+			Entry("follow", "file=foo.log&follow=true&since=5m"),
+			Entry("highlight with filter", "file=foo.log&filter=GET&highlight=true"),
+			Entry("highlight disabled without filter", "file=foo.log&highlight=false"),
+			Entry("context with filter", "file=foo.log&filter=GET&before=1&after=1"),
+			Entry("context with where", "file=foo.log&parser=json&where=level=error&after=1"),
+			Entry("csv parser", "file=foo.csv&parser=csv"),
+			Entry("cursor", "file=foo.log&cursor=abc"),
+			Entry("several files", "file=foo.log&file=bar.log&filter=GET&highlight=true"),
+			Entry("wildcard", "file=*.log&until=5m"),
+		)
+
+		DescribeTable("should reject the invalid combinations", func(query string, msg string) {
+			Expect(validate(query)).Should(MatchError(msg))
+		},
+			Entry("highlight without filter", "file=foo.log&highlight=true",
+				"highlight cannot be used without filter, regex or q"),
+			Entry("highlight with parser", "file=foo.log&filter=GET&parser=json&highlight=true",
+				"highlight cannot be used with parser"),
+			Entry("context without filter", "file=foo.log&before=1",
+				"before and after cannot be used without filter"),
+			Entry("context with follow", "file=foo.log&filter=GET&after=1&follow=true",
+				"before and after cannot be used with follow or when several files are read"),
+			Entry("context with several files", "file=foo.log&file=bar.log&filter=GET&after=1",
+				"before and after cannot be used with follow or when several files are read"),
+			Entry("csv with follow", "file=foo.csv&parser=csv&follow=true",
+				"csv and tsv parsers cannot be used with follow or when several files are read"),
+			Entry("csv with wildcard", "file=*.csv&parser=tsv",
+				"csv and tsv parsers cannot be used with follow or when several files are read"),
+			Entry("until with follow", "file=foo.log&until=5m&follow=true",
+				"until cannot be used with follow since the followed events are always the most recent ones"),
+			Entry("follow with several files", "file=foo.log&file=bar.log&follow=true",
+				"follow and cursor cannot be used when several files are read"),
+			Entry("cursor with wildcard", "file=*.log&cursor=abc",
+				"follow and cursor cannot be used when several files are read"),
+			Entry("cursor with follow", "file=foo.log&cursor=abc&follow=true",
+				"cursor cannot be used with follow"),
+		)
+	})
+})
@@ -22,18 +22,33 @@ package http
 import (
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
 
 	"go.uber.org/zap"
 )
 
 const (
 	invalidParameter = "invalid.parameter"
+	invalidQuery     = "invalid.query"
 	internalError    = "internal.error"
 	requestCanceled  = "request.canceled"
+	cursorExpired    = "cursor.expired"
 )
 
+const (
+	jsonFormat   = "json"
+	ndjsonFormat = "ndjson"
+
+	ndjsonContentType = "application/x-ndjson"
+)
+
+// streamFlushInterval is the interval between two flushes of a stream of events
+const streamFlushInterval = 500 * time.Millisecond
+
 const internalErrorDetails = "Oops, try again later. If the problem persist, please contact your administrator"
 
 func writeErrorResponse(w http.ResponseWriter, httpCode int, resp api.ErrorResponse, logger *zap.Logger) {
@@ -49,9 +64,8 @@ func writeErrorResponse(w http.ResponseWriter, httpCode int, resp api.ErrorRespo
 	}
 }
 
-
-
-func writeResponse(w http.ResponseWriter, resp api.LogResponse, logger *zap.Logger) {
+// writeJSONResponse writes the response as a JSON document
+func writeJSONResponse(w http.ResponseWriter, resp interface{}, logger *zap.Logger) {
 	payload, err := json.Marshal(resp)
 	if err != nil {
 		logger.Error("failed to serialize response", zap.Error(err))
@@ -62,3 +76,87 @@ func writeResponse(w http.ResponseWriter, resp api.LogResponse, logger *zap.Logg
 		logger.Error("failed to write response", zap.Error(err))
 	}
 }
+
+// eventStream writes events as newline delimited JSON. The events can be flushed from another goroutine.
+type eventStream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	flusher http.Flusher
+	// unflushed is true when events have been written since the last flush
+	unflushed bool
+	// parser is nil if the events are not parsed
+	parser processor.EventParser
+	// context is nil if the context of the matching events is not requested
+	context *contextProcessor
+	// highlighter is nil if the matches are not highlighted
+	highlighter processor.Highlighter
+}
+
+func newEventStream(w http.ResponseWriter, parser processor.EventParser) *eventStream {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	return &eventStream{
+		encoder: json.NewEncoder(w),
+		flusher: flusher,
+		parser:  parser,
+	}
+}
+
+// write writes an event, file is the file of the event when several files are read at once
+func (s *eventStream) write(event string, file string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unflushed = true
+	if s.context != nil {
+		// the event is the last one returned by the context processor
+		return s.encoder.Encode(s.context.contextEvent(s.parser, event, len(s.context.groups)-1))
+	}
+	if s.parser != nil {
+		return s.encoder.Encode(structuredEvent(s.parser, event, file))
+	}
+	return s.encoder.Encode(api.LogEvent{Event: event, File: file, Highlights: highlights(s.highlighter, event)})
+}
+
+// writeSummary writes the summary record that ends the stream
+func (s *eventStream) writeSummary(summary api.StreamSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unflushed = true
+	return s.encoder.Encode(api.LogSummary{Summary: summary})
+}
+
+// flush sends to the client the events written so far
+func (s *eventStream) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flusher != nil && s.unflushed {
+		s.flusher.Flush()
+	}
+	s.unflushed = false
+}
+
+// flushEvery flushes the stream at the given interval, even while the processor scans the file for the next event to
+// return, until the returned function is called
+func (s *eventStream) flushEvery(interval time.Duration) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.flush()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		// the stream must not be flushed once the response is complete
+		<-stopped
+	}
+}
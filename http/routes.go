@@ -32,10 +32,12 @@ func index(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	fmt.Fprint(w, "Welcome!\n")
 }
 
-func routes(fs afero.Fs, config *Config, logger *zap.Logger) *httprouter.Router {
+func routes(fs afero.Fs, config *Config, shutdown <-chan struct{}, logger *zap.Logger) *httprouter.Router {
 	router := httprouter.New()
 	logger.Named("router").Info("installing http handlers")
 	router.GET("/", index)
-	router.GET("/log", logHandler(fs, config, logger))
+	router.GET("/log", logHandler(fs, config, shutdown, logger))
+	router.GET("/log/stats", statsHandler(fs, config, logger))
+	router.GET("/files", filesHandler(fs, config, logger))
 	return router
 }
@@ -39,13 +39,17 @@ type Server struct {
 
 // NewServer creates a Server with the given Config
 func NewServer(config *Config, fs afero.Fs, parentLogger *zap.Logger) *Server {
-	router := routes(fs, config, parentLogger)
+	// shutdownCtx is canceled when the server shuts down so that the followed files stop streaming
+	shutdownCtx, cancel := context.WithCancel(context.Background())
+	router := routes(fs, config, shutdownCtx.Done(), parentLogger)
+	server := &http.Server{
+		Handler: router,
+	}
+	server.RegisterOnShutdown(cancel)
 	return &Server{
 		config: config,
 		logger: parentLogger.Named("http-server"),
-		server: &http.Server{
-			Handler: router,
-		},
+		server: server,
 	}
 }
 
@@ -76,4 +80,9 @@ func (s *Server) Stop() {
 	} else {
 		s.logger.Info("http server is stopped")
 	}
+	if s.config.decompressionCache != nil {
+		if err := s.config.decompressionCache.Close(); err != nil {
+			s.logger.Warn("failed to remove decompressed files", zap.Error(err))
+		}
+	}
 }
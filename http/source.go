@@ -0,0 +1,190 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/spf13/afero"
+)
+
+// Source defines a named folder of log files. Its settings override the ones of the Config, the settings that are not
+// set are inherited from the Config.
+type Source struct {
+	// Folder defines the folder that contains the log files of the source. It may contain wildcards e.g. /srv/*/logs,
+	// in which case the file parameter starts with the folders matched by the wildcards e.g. shop/api.log
+	Folder string `yaml:"folder"`
+	// BufferSize overrides the buffer size of the Config
+	BufferSize int `yaml:"buffer_size"`
+	// MaxEvents overrides the maximum number of events of the Config
+	MaxEvents uint `yaml:"max_events"`
+	// MultilineStart overrides the regular expression matching the first line of an event
+	MultilineStart string `yaml:"multiline_start"`
+	// FollowSymlinks overrides whether the symbolic links are followed
+	FollowSymlinks *bool `yaml:"follow_symlinks"`
+	// Parsers overrides the parser rules
+	Parsers []ParserRule `yaml:"parsers"`
+	// TimestampFormats overrides the formats of the timestamps
+	TimestampFormats []TimestampFormat `yaml:"timestamp_formats"`
+	// UnparseableTimestamp overrides the policy of the events whose timestamp cannot be parsed
+	UnparseableTimestamp processor.UnparseableTimestampPolicy `yaml:"unparseable_timestamp"`
+}
+
+// UnmarshalYAML reads a source declared either as the path of its folder or as a mapping
+func (s *Source) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var folder string
+	if err := unmarshal(&folder); err == nil {
+		*s = Source{Folder: folder}
+		return nil
+	}
+	type plain Source
+	return unmarshal((*plain)(s))
+}
+
+// config returns the configuration of the source, which is the given configuration with the settings of the source
+func (s Source) config(fs afero.Fs, parent *Config) (*Config, error) {
+	if s.Folder == "" {
+		return nil, errors.New("folder must not be empty")
+	}
+	c := *parent
+	c.LogFolder = s.Folder
+	c.Sources = nil
+	c.sources = nil
+	if s.BufferSize != 0 {
+		c.BufferSize = s.BufferSize
+	}
+	if s.MaxEvents != 0 {
+		c.MaxEvents = s.MaxEvents
+	}
+	if s.MultilineStart != "" {
+		c.MultilineStart = s.MultilineStart
+	}
+	if s.FollowSymlinks != nil {
+		c.FollowSymlinks = *s.FollowSymlinks
+	}
+	if s.Parsers != nil {
+		c.Parsers = s.Parsers
+	}
+	if s.TimestampFormats != nil {
+		c.TimestampFormats = s.TimestampFormats
+	}
+	if s.UnparseableTimestamp != "" {
+		c.UnparseableTimestamp = s.UnparseableTimestamp
+	}
+	if err := c.compile(); err != nil {
+		return nil, err
+	}
+	if !hasWildcard(s.Folder) {
+		return &c, checkLogFolder(fs, s.Folder)
+	}
+	if _, err := filepath.Match(s.Folder, ""); err != nil {
+		return nil, fmt.Errorf("folder is not a valid glob pattern %w", err)
+	}
+	return &c, nil
+}
+
+// source returns the configuration of the given source. The configuration itself is returned when name is empty.
+func (c *Config) source(name string) (*Config, error) {
+	if len(name) == 0 {
+		return c, nil
+	}
+	conf, ok := c.sources[name]
+	if !ok {
+		return nil, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("source %s is not declared", name),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return conf, nil
+}
+
+func hasWildcard(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// sourceFolder returns the folder that contains the given file, which must have been validated by
+// validateFileParameter, and the path of the file in this folder. When the folder of the source contains wildcards,
+// the first elements of the file replace the elements of the folder that contain wildcards.
+func sourceFolder(folder string, file string) (string, string, error) {
+	if !hasWildcard(folder) {
+		return folder, file, nil
+	}
+	elements := strings.Split(filepath.Clean(folder), "/")
+	remaining := strings.Split(file, "/")
+	for i, element := range elements {
+		if !hasWildcard(element) {
+			continue
+		}
+		// the last element is the file itself
+		if len(remaining) < 2 {
+			return "", "", wildcardMismatchErr(file)
+		}
+		if ok, err := filepath.Match(element, remaining[0]); err != nil || !ok {
+			return "", "", wildcardMismatchErr(file)
+		}
+		elements[i] = remaining[0]
+		remaining = remaining[1:]
+	}
+	return strings.Join(elements, "/"), strings.Join(remaining, "/"), nil
+}
+
+func wildcardMismatchErr(file string) httpError {
+	return httpError{
+		code:       invalidParameter,
+		details:    fmt.Sprintf("file %s does not start with the folders matched by the wildcards of the source", file),
+		httpStatus: http.StatusBadRequest,
+	}
+}
+
+// sourceFolders returns the folders matched by the folder of a source, mapped to the prefix of their files in the file
+// parameter
+func sourceFolders(fs afero.Fs, folder string) (map[string]string, error) {
+	if !hasWildcard(folder) {
+		return map[string]string{folder: ""}, nil
+	}
+	pattern := filepath.Clean(folder)
+	matches, err := afero.Glob(fs, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders of source %w", err)
+	}
+	elements := strings.Split(pattern, "/")
+	folders := make(map[string]string, len(matches))
+	for _, match := range matches {
+		ok, err := afero.IsDir(fs, match)
+		if err != nil || !ok {
+			continue
+		}
+		var prefix []string
+		for i, element := range strings.Split(match, "/") {
+			if hasWildcard(elements[i]) {
+				prefix = append(prefix, element)
+			}
+		}
+		folders[match] = strings.Join(prefix, "/")
+	}
+	return folders, nil
+}
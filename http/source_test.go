@@ -0,0 +1,56 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source", func() {
+
+	Describe("sourceFolder", func() {
+		DescribeTable("should locate the file in the folders of the source", func(folder string, file string,
+			expectedFolder string, expectedFile string) {
+			f, name, err := http.SourceFolder(folder, file)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(f).Should(Equal(expectedFolder))
+			Expect(name).Should(Equal(expectedFile))
+		},
+			Entry("folder has no wildcard", "/opt/app/logs", "nginx/api.log", "/opt/app/logs", "nginx/api.log"),
+			Entry("folder has a wildcard", "/srv/*/logs", "shop/api.log", "/srv/shop/logs", "api.log"),
+			Entry("folder has several wildcards", "/srv/*/logs/app-?", "shop/app-1/nginx/api.log",
+				"/srv/shop/logs/app-1", "nginx/api.log"),
+			Entry("folder ends with a slash", "/srv/*/", "shop/api.log", "/srv/shop", "api.log"),
+		)
+
+		DescribeTable("should return an error", func(folder string, file string) {
+			_, _, err := http.SourceFolder(folder, file)
+			Expect(err).Should(MatchError(
+				"file " + file + " does not start with the folders matched by the wildcards of the source"))
+		},
+			Entry("file has no folder", "/srv/*/logs", "api.log"),
+			Entry("file does not match the wildcard", "/srv/app-?/logs", "shop/api.log"),
+			Entry("file does not have enough folders", "/srv/*/logs/*", "shop/api.log"),
+		)
+	})
+})
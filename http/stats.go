@@ -0,0 +1,193 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+const defaultStatsInterval = time.Minute
+
+// parseInterval parses the duration of the buckets of the statistics, one minute by default
+func parseInterval(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return defaultStatsInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, httpError{
+			code:       invalidParameter,
+			details:    "interval is not a valid duration",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	if interval < time.Second {
+		return 0, httpError{
+			code:       invalidParameter,
+			details:    "interval must be at least 1s",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return interval, nil
+}
+
+// statsHandler counts the events of a file that pass the filters by period of time. The events are read like the
+// events of a log request, except that they are counted instead of being returned, so MaxEvents does not apply and the
+// number of events read is limited by MaxScannedEvents instead.
+func statsHandler(fs afero.Fs, config *Config, parentLogger *zap.Logger) func(http.ResponseWriter, *http.Request, httprouter.Params) {
+	logger := parentLogger.Named("stats-handler")
+	return func(w http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		query := request.URL.Query()
+		name := query.Get("file")
+		if err := validateFileParameter(name); err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		if isMerge(query["file"]) {
+			handleError(w, severalFilesErr("statistics cannot be computed"), logger)
+			return
+		}
+		conf, err := config.source(query.Get("source"))
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+
+		interval, err := parseInterval(query.Get("interval"))
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		filter, _, err := parseFilter(query, conf.MaxPatternLength)
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		_, where, csv, err := parseParserParameters(query, conf)
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		if csv != nil {
+			conf = conf.withCSVRecords()
+		}
+		filter = combineFilters(filter, where, csv)
+		timeRange, err := parseTimeRange(query, time.Now())
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+
+		reader, _, path, err := openFile(fs, conf, name, nil, false, csv, logger)
+		if err != nil {
+			handleError(w, err, logger)
+			return
+		}
+		defer reader.Close()
+
+		logger.Sugar().Infow("computing statistics",
+			"file", path,
+			"source", query.Get("source"),
+			"interval", interval,
+			"filter", query.Get("filter"),
+			"filter_mode", query.Get("filter_mode"),
+			"regex", query.Get("regex"),
+			"q", query.Get("q"),
+			"ignore_case", query.Get("ignore_case"),
+			"whole_word", query.Get("whole_word"),
+			"since", timeRange.since,
+			"until", timeRange.until,
+			"parser", query.Get("parser"),
+			"where", query["where"])
+		p, budget, err := createStatsProcessor(reader, conf, timeRange, filter)
+		if err != nil {
+			logger.Error("failed to create processor", zap.Error(err))
+			handleError(w, err, logger)
+			return
+		}
+		histogram := processor.NewHistogram(conf.timestamps(), interval)
+		for {
+			if request.Context().Err() != nil {
+				handleError(w, httpError{
+					code:       requestCanceled,
+					details:    "client canceled request",
+					httpStatus: http.StatusBadRequest,
+				}, logger)
+				return
+			}
+			event, err := p.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				logger.Error("failed to process file", zap.Error(err))
+				handleError(w, err, logger)
+				return
+			}
+			histogram.Add(event)
+		}
+
+		buckets := histogram.Buckets()
+		resp := api.StatsResponse{
+			File:          path,
+			Files:         reader.Files(),
+			Total:         histogram.Total(),
+			Untimestamped: histogram.Untimestamped(),
+			Buckets:       make([]api.StatsBucket, 0, len(buckets)),
+			Scanned:       budget.Scanned(),
+			Truncated:     budget.Exhausted(),
+		}
+		for _, bucket := range buckets {
+			resp.Buckets = append(resp.Buckets, api.StatsBucket{Start: bucket.Start, Count: bucket.Count})
+		}
+		writeJSONResponse(w, resp, logger)
+	}
+}
+
+// createStatsProcessor creates the pipeline of processors that returns the events to count. The scan budget applies to
+// the events read from the file, before the time range and the filter.
+func createStatsProcessor(reader processor.TailReader, config *Config, timeRange timeRange,
+	filter processor.EventFilter) (processor.EventProcessor, *processor.ScanBudgetEventProcessor, error) {
+	breaker := processor.NewEventBreaker(reader, config.splitter(), config.BufferSize)
+	if !timeRange.until.IsZero() {
+		if err := breaker.SeekToTime(config.timestamps(), timeRange.until); err != nil {
+			return nil, nil, err
+		}
+	}
+	budget := processor.WithScanBudget(breaker, config.MaxScannedEvents)
+	p := processor.EventProcessor(budget)
+	if !timeRange.isZero() {
+		p = processor.WithTimeRange(p, config.timestamps(), timeRange.since, timeRange.until,
+			config.UnparseableTimestamp)
+	}
+	if filter != nil {
+		p = processor.WithFilter(p, filter)
+	}
+	return p, budget, nil
+}
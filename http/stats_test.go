@@ -0,0 +1,191 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"encoding/json"
+	gohttp "net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dvergnes/log-collector/api"
+	"github.com/dvergnes/log-collector/http"
+
+	"github.com/julienschmidt/httprouter"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+var _ = Describe("Stats", func() {
+	const logFolder = "/var/log"
+
+	Describe("parseInterval", func() {
+		DescribeTable("should parse the interval", func(value string, expected time.Duration) {
+			Expect(http.ParseInterval(value)).Should(Equal(expected))
+		},
+			Entry("interval is one minute by default", "", time.Minute),
+			Entry("interval is a duration", "1h30m", 90*time.Minute),
+		)
+
+		DescribeTable("should reject the invalid intervals", func(value string, msg string) {
+			_, err := http.ParseInterval(value)
+			Expect(err).Should(MatchError(msg))
+		},
+			Entry("interval is not a duration", "often", "interval is not a valid duration"),
+			Entry("interval is too short", "500ms", "interval must be at least 1s"),
+			Entry("interval is negative", "-1m", "interval must be at least 1s"),
+		)
+	})
+
+	Describe("statsHandler", func() {
+		var (
+			fs     afero.Fs
+			h      httprouter.Handle
+			config string
+		)
+
+		BeforeEach(func() {
+			fs = afero.NewMemMapFs()
+			Expect(fs.MkdirAll(logFolder, 0755)).Should(Succeed())
+			Expect(afero.WriteFile(fs, logFolder+"/app.log", []byte(`2020-10-05 10:00:01 INFO started
+2020-10-05 10:00:30 ERROR oops
+2020-10-05 10:00:45 ERROR again
+  at main.go:12
+2020-10-05 10:02:10 ERROR later
+2020-10-05 10:02:50 INFO done
+`), 0644)).Should(Succeed())
+			config = `
+log_folder: /var/log
+max_events: 1
+`
+		})
+
+		JustBeforeEach(func() {
+			conf, err := http.LoadConfig([]byte(config), fs)
+			Expect(err).ShouldNot(HaveOccurred())
+			h = http.StatsHandler(fs, conf, zap.NewNop())
+		})
+
+		stats := func(query string) (int, []byte) {
+			req := httptest.NewRequest("GET", "http://localhost:8888/log/stats?file=app.log&"+query, nil)
+			w := httptest.NewRecorder()
+
+			h(w, req, httprouter.Params{})
+
+			resp := w.Result()
+			Expect(resp.Header.Get("Content-Type")).Should(Equal("application/json"))
+			return resp.StatusCode, w.Body.Bytes()
+		}
+
+		get := func(query string) api.StatsResponse {
+			status, body := stats(query)
+			Expect(status).Should(Equal(gohttp.StatusOK))
+			sr := api.StatsResponse{}
+			Expect(json.Unmarshal(body, &sr)).Should(Succeed())
+			return sr
+		}
+
+		minute := func(m int) time.Time {
+			return time.Date(2020, 10, 5, 10, m, 0, 0, time.UTC)
+		}
+
+		It("should count the matching events by interval regardless of max events", func() {
+			sr := get("filter=ERROR")
+			Expect(sr.File).Should(Equal("/var/log/app.log"))
+			Expect(sr.Files).Should(Equal([]string{"/var/log/app.log"}))
+			Expect(sr.Total).Should(Equal(3))
+			Expect(sr.Untimestamped).Should(BeZero())
+			Expect(sr.Scanned).Should(Equal(uint(6)))
+			Expect(sr.Truncated).Should(BeFalse())
+			Expect(sr.Buckets).Should(HaveLen(2))
+			Expect(sr.Buckets[0].Start).Should(BeTemporally("==", minute(0)))
+			Expect(sr.Buckets[0].Count).Should(Equal(2))
+			Expect(sr.Buckets[1].Start).Should(BeTemporally("==", minute(2)))
+			Expect(sr.Buckets[1].Count).Should(Equal(1))
+		})
+
+		It("should count the events without timestamp apart", func() {
+			sr := get("interval=1h")
+			Expect(sr.Total).Should(Equal(6))
+			Expect(sr.Untimestamped).Should(Equal(1))
+			Expect(sr.Buckets).Should(HaveLen(1))
+			Expect(sr.Buckets[0].Start).Should(BeTemporally("==", time.Date(2020, 10, 5, 10, 0, 0, 0, time.UTC)))
+			Expect(sr.Buckets[0].Count).Should(Equal(5))
+		})
+
+		It("should apply the time range", func() {
+			sr := get("since=2020-10-05T10:00:30Z&until=2020-10-05T10:02:10Z&q=ERROR")
+			Expect(sr.Total).Should(Equal(3))
+			Expect(sr.Buckets).Should(HaveLen(2))
+		})
+
+		It("should apply the where conditions of the parser", func() {
+			Expect(afero.WriteFile(fs, logFolder+"/app.json", []byte(`{"time": "2020-10-05T10:00:01Z", "status": 200}
+{"time": "2020-10-05T10:00:02Z", "status": 503}
+{"time": "2020-10-05T10:01:03Z", "status": 500}
+`), 0644)).Should(Succeed())
+			req := httptest.NewRequest("GET", "http://localhost:8888/log/stats?file=app.json&parser=json&where=status%3E%3D500", nil)
+			w := httptest.NewRecorder()
+
+			h(w, req, httprouter.Params{})
+
+			sr := api.StatsResponse{}
+			Expect(json.Unmarshal(w.Body.Bytes(), &sr)).Should(Succeed())
+			Expect(sr.Total).Should(Equal(2))
+			Expect(sr.Buckets).Should(HaveLen(2))
+		})
+
+		When("scan budget is reached", func() {
+			BeforeEach(func() {
+				config = `
+log_folder: /var/log
+max_scanned_events: 2
+`
+			})
+
+			It("should count the most recent events and report that the statistics are truncated", func() {
+				sr := get("filter=ERROR")
+				Expect(sr.Scanned).Should(Equal(uint(2)))
+				Expect(sr.Truncated).Should(BeTrue())
+				Expect(sr.Total).Should(Equal(1))
+				Expect(sr.Buckets).Should(HaveLen(1))
+				Expect(sr.Buckets[0].Start).Should(BeTemporally("==", minute(2)))
+			})
+		})
+
+		When("parameters are invalid", func() {
+			DescribeTable("should return an error response", func(query string, msg string) {
+				status, body := stats(query)
+				Expect(status).Should(Equal(gohttp.StatusBadRequest))
+				err := api.ErrorResponse{}
+				Expect(json.Unmarshal(body, &err)).Should(Succeed())
+				Expect(err.Code).Should(Equal("invalid.parameter"))
+				Expect(err.Details).Should(Equal(msg))
+			},
+				Entry("interval is invalid", "interval=often", "interval is not a valid duration"),
+				Entry("several files are read", "file=other.log", "statistics cannot be computed when several files are read"),
+				Entry("regex is invalid", "regex=[a-", "regex is not a valid regular expression"),
+				Entry("since is invalid", "since=yesterday", "since is neither a valid RFC 3339 timestamp nor a valid duration"),
+			)
+		})
+	})
+})
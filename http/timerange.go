@@ -0,0 +1,84 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// timeRange defines the period of time of the requested events, a zero bound leaves the range open
+type timeRange struct {
+	since time.Time
+	until time.Time
+}
+
+// isZero returns true if the range is open on both ends
+func (tr timeRange) isZero() bool {
+	return tr.since.IsZero() && tr.until.IsZero()
+}
+
+// parseTimeRange creates the timeRange defined by the since and until query parameters. Each of them is either an
+// RFC 3339 timestamp or a duration, e.g. 15m, relative to now.
+func parseTimeRange(query url.Values, now time.Time) (timeRange, error) {
+	since, err := parseTime("since", query.Get("since"), now)
+	if err != nil {
+		return timeRange{}, err
+	}
+	until, err := parseTime("until", query.Get("until"), now)
+	if err != nil {
+		return timeRange{}, err
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		return timeRange{}, httpError{
+			code:       invalidParameter,
+			details:    "since must be before until",
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return timeRange{since: since, until: until}, nil
+}
+
+func parseTime(param string, value string, now time.Time) (time.Time, error) {
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("%s is neither a valid RFC 3339 timestamp nor a valid duration", param),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	if d < 0 {
+		return time.Time{}, httpError{
+			code:       invalidParameter,
+			details:    fmt.Sprintf("%s must not be a negative duration", param),
+			httpStatus: http.StatusBadRequest,
+		}
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package http_test
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/dvergnes/log-collector/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TimeRange", func() {
+
+	Describe("parseTimeRange", func() {
+		now := time.Date(2020, time.October, 5, 18, 32, 51, 0, time.UTC)
+
+		When("parameters are valid", func() {
+			DescribeTable("should return the time range", func(query string, since time.Time, until time.Time) {
+				values, err := url.ParseQuery(query)
+				Expect(err).ShouldNot(HaveOccurred())
+				tr, err := http.ParseTimeRange(values, now)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tr.Since()).Should(BeTemporally("==", since))
+				Expect(tr.Until()).Should(BeTemporally("==", until))
+			},
+				Entry("range is open", "", time.Time{}, time.Time{}),
+				Entry("since is a timestamp", "since=2020-10-05T10:32:51-08:00", now, time.Time{}),
+				Entry("until is relative to now", "until=15m", time.Time{}, now.Add(-15*time.Minute)),
+				Entry("both are set", "since=1h&until=2020-10-05T18:30:00.5Z", now.Add(-time.Hour),
+					time.Date(2020, time.October, 5, 18, 30, 0, 500_000_000, time.UTC)),
+			)
+		})
+
+		When("parameters are invalid", func() {
+			DescribeTable("should return an error", func(query string, msg string) {
+				values, err := url.ParseQuery(query)
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = http.ParseTimeRange(values, now)
+				Expect(err).Should(MatchError(msg))
+			},
+				Entry("since is invalid", "since=yesterday", "since is neither a valid RFC 3339 timestamp nor a valid duration"),
+				Entry("until is invalid", "until=2020-10-05", "until is neither a valid RFC 3339 timestamp nor a valid duration"),
+				Entry("duration is negative", "since=-5m", "since must not be a negative duration"),
+				Entry("since is after until", "since=5m&until=10m", "since must be before until"),
+			)
+		})
+	})
+})
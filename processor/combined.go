@@ -0,0 +1,96 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// combinedTimestampLayout is the layout of the timestamps of the access logs
+const combinedTimestampLayout = "02/Jan/2006:15:04:05 -0700"
+
+// combinedPattern matches the combined log format of Apache and NGINX. The fields that may follow the user agent are
+// ignored.
+var combinedPattern = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-) ` +
+	`"((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"`)
+
+// CombinedLogParser parses the events written in the combined log format of Apache and NGINX e.g.
+// 127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/5.0"
+// The fields are client_ip, ident, user, timestamp, method, path, protocol, status, bytes, referer and user_agent. The
+// method, path and protocol are empty when the request line is malformed.
+var CombinedLogParser EventParser = EventParserFunc(parseCombined)
+
+func parseCombined(event string) (Fields, error) {
+	m := combinedPattern.FindStringSubmatch(event)
+	if m == nil {
+		return nil, fmt.Errorf("failed to parse combined log %w", ParseErr)
+	}
+	timestamp, err := time.Parse(combinedTimestampLayout, m[4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp of combined log %w", ParseErr)
+	}
+	status, _ := strconv.Atoi(m[6])
+	// a response without body has - as size
+	bytes := 0
+	if m[7] != "-" {
+		if bytes, err = strconv.Atoi(m[7]); err != nil {
+			return nil, fmt.Errorf("failed to parse size of combined log %w", ParseErr)
+		}
+	}
+	method, path, protocol := "", "", ""
+	if request := strings.Split(unescapeQuoted(m[5]), " "); len(request) == 3 {
+		method, path, protocol = request[0], request[1], request[2]
+	}
+	return Fields{
+		"client_ip":  m[1],
+		"ident":      m[2],
+		"user":       m[3],
+		"timestamp":  timestamp,
+		"method":     method,
+		"path":       path,
+		"protocol":   protocol,
+		"status":     status,
+		"bytes":      bytes,
+		"referer":    unescapeQuoted(m[8]),
+		"user_agent": unescapeQuoted(m[9]),
+	}, nil
+}
+
+// unescapeQuoted removes the backslashes that escape the characters of a quoted field
+func unescapeQuoted(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var b strings.Builder
+	escaped := false
+	for _, r := range field {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
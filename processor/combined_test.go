@@ -0,0 +1,87 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CombinedLogParser", func() {
+
+	It("should extract the fields of an access log", func() {
+		fields, err := processor.CombinedLogParser.Parse(
+			`190.134.145.226 - frank [05/Oct/2020:10:32:51 -0800] "GET /index.html?q=1 HTTP/1.1" 200 185077 "http://sourceforge.net/" "Mozilla/5.0 (Windows; U)" "190.134.145.226.6087629394390021"`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields).Should(Equal(processor.Fields{
+			"client_ip":  "190.134.145.226",
+			"ident":      "-",
+			"user":       "frank",
+			"timestamp":  time.Date(2020, time.October, 5, 10, 32, 51, 0, time.FixedZone("", -8*3600)),
+			"method":     "GET",
+			"path":       "/index.html?q=1",
+			"protocol":   "HTTP/1.1",
+			"status":     200,
+			"bytes":      185077,
+			"referer":    "http://sourceforge.net/",
+			"user_agent": "Mozilla/5.0 (Windows; U)",
+		}))
+	})
+
+	DescribeTable("should handle the variations of the format", func(event string, field string, expected interface{}) {
+		fields, err := processor.CombinedLogParser.Parse(event)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields[field]).Should(Equal(expected))
+	},
+		Entry("response has no body", `::1 - - [05/Oct/2020:10:32:51 +0000] "HEAD / HTTP/1.1" 304 - "-" "curl/7.68.0"`,
+			"bytes", 0),
+		Entry("user agent contains escaped quotes", `::1 - - [05/Oct/2020:10:32:51 +0000] "GET / HTTP/1.1" 200 12 "-" "say \"hi\""`,
+			"user_agent", `say "hi"`),
+		Entry("request line is malformed", `::1 - - [05/Oct/2020:10:32:51 +0000] "\x16\x03\x01" 400 0 "-" "-"`,
+			"method", ""),
+	)
+
+	DescribeTable("should return a parse error", func(event string) {
+		_, err := processor.CombinedLogParser.Parse(event)
+		Expect(err).Should(MatchError(processor.ParseErr))
+	},
+		Entry("event is not an access log", "2020-10-05 10:32:51 ERROR oops"),
+		Entry("timestamp is invalid", `::1 - - [05/Oct/2020 10:32:51] "GET / HTTP/1.1" 200 12 "-" "-"`),
+		Entry("status is missing", `::1 - - [05/Oct/2020:10:32:51 +0000] "GET / HTTP/1.1" - 12 "-" "-"`),
+	)
+
+	It("should parse the bundled access log", func() {
+		f, err := os.Open("../log/access_combined.log")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			_, err := processor.CombinedLogParser.Parse(scanner.Text())
+			Expect(err).ShouldNot(HaveOccurred(), scanner.Text())
+		}
+		Expect(scanner.Err()).ShouldNot(HaveOccurred())
+	})
+})
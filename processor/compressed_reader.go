@@ -0,0 +1,194 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/spf13/afero"
+)
+
+// Compression identifies the compression format of a file
+type Compression string
+
+const (
+	// NoCompression identifies a file that is not compressed
+	NoCompression Compression = "none"
+	// Gzip identifies a file compressed with gzip
+	Gzip Compression = "gzip"
+	// Zstd identifies a file compressed with zstd
+	Zstd Compression = "zstd"
+	// Bzip2 identifies a file compressed with bzip2
+	Bzip2 Compression = "bzip2"
+)
+
+var magicBytes = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{compression: Gzip, magic: []byte{0x1f, 0x8b}},
+	{compression: Zstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{compression: Bzip2, magic: []byte("BZh")},
+}
+
+// DetectCompression detects the compression format of the given file from its first bytes
+func DetectCompression(fs afero.Fs, name string) (Compression, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return NoCompression, fmt.Errorf("failed to open file %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return NoCompression, fmt.Errorf("failed to read file %w", err)
+	}
+	for _, m := range magicBytes {
+		if bytes.HasPrefix(header[:n], m.magic) {
+			return m.compression, nil
+		}
+	}
+	return NoCompression, nil
+}
+
+// DefaultMaxDecompressedSize is the maximum size in bytes of a decompressed file when no DecompressionCache is used
+const DefaultMaxDecompressedSize = 1 << 30
+
+// DecompressedSizeErr indicates that a compressed file is larger than the maximum decompressed size once decompressed
+var DecompressedSizeErr = errors.New("file is larger than the maximum decompressed size once decompressed")
+
+// OpenTailReader creates a TailReader for the given file. The compression format of the file is detected so that a
+// compressed file is decompressed transparently, through the given cache if it is not nil, in the temporary folder of
+// the OS otherwise.
+func OpenTailReader(fs afero.Fs, name string, cache *DecompressionCache) (TailReader, error) {
+	compression, err := DetectCompression(fs, name)
+	if err != nil {
+		return nil, err
+	}
+	if compression == NoCompression {
+		return NewTailReader(fs, name)
+	}
+	if cache == nil {
+		return newUncachedTailReader(fs, name, compression)
+	}
+	return cache.open(fs, name, compression)
+}
+
+// decompressedTailReader implements TailReader interface. Since a compressed stream cannot be read backward, the file
+// is decompressed once into a temporary file which is then read backward by a tailReader, so that the memory usage
+// does not depend on the size of the file.
+type decompressedTailReader struct {
+	TailReader
+
+	// release is called once the reader is closed
+	release func() error
+}
+
+// newUncachedTailReader decompresses the file into a temporary file of the OS which is removed once the reader is
+// closed
+func newUncachedTailReader(fs afero.Fs, name string, compression Compression) (TailReader, error) {
+	tempFs := afero.NewOsFs()
+	path, _, err := decompress(fs, name, compression, tempFs, "", DefaultMaxDecompressedSize)
+	if err != nil {
+		return nil, err
+	}
+	remove := func() error {
+		if err := tempFs.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove temporary file %w", err)
+		}
+		return nil
+	}
+	reader, err := NewTailReader(tempFs, path)
+	if err != nil {
+		_ = remove()
+		return nil, err
+	}
+	return &decompressedTailReader{TailReader: reader, release: remove}, nil
+}
+
+// Close closes the reader and releases the temporary file that contains the decompressed content
+func (dr *decompressedTailReader) Close() error {
+	err := dr.TailReader.Close()
+	if releaseErr := dr.release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// decompress decompresses the file into a temporary file created in tempFolder of tempFs, or in the temporary folder
+// of tempFs if tempFolder is empty, and returns its name and size. It returns DecompressedSizeErr if the decompressed
+// content is larger than maxSize.
+func decompress(fs afero.Fs, name string, compression Compression, tempFs afero.Fs, tempFolder string,
+	maxSize int64) (string, int64, error) {
+	file, err := openResolved(fs, name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	decompressed, err := newDecompressor(file, compression)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to decompress file %w", err)
+	}
+	defer decompressed.Close()
+
+	tmp, err := afero.TempFile(tempFs, tempFolder, "log-collector-")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temporary file %w", err)
+	}
+	// one more byte than the maximum is read to detect that the content is too large
+	n, err := io.Copy(tmp, io.LimitReader(decompressed, maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxSize {
+		err = DecompressedSizeErr
+	}
+	if err != nil {
+		_ = tempFs.Remove(tmp.Name())
+		return "", 0, fmt.Errorf("failed to decompress file %w", err)
+	}
+	return tmp.Name(), n, nil
+}
+
+func newDecompressor(r io.Reader, compression Compression) (io.ReadCloser, error) {
+	switch compression {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		// the decoder is limited to a single goroutine and low memory usage since the content is read sequentially
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", compression)
+	}
+}
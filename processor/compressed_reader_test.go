@@ -0,0 +1,138 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("CompressedReader", func() {
+
+	const content = "event_1\nevent_2\nevent_3\n"
+
+	var (
+		fs afero.Fs
+
+		gzipContent = func() []byte {
+			b := bytes.Buffer{}
+			w := gzip.NewWriter(&b)
+			_, err := w.Write([]byte(content))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.Close()).Should(Succeed())
+			return b.Bytes()
+		}
+		zstdContent = func() []byte {
+			b := bytes.Buffer{}
+			w, err := zstd.NewWriter(&b)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = w.Write([]byte(content))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.Close()).Should(Succeed())
+			return b.Bytes()
+		}
+		// generated with bzip2 since the standard library does not provide a bzip2 writer
+		bzip2Content = []byte{
+			0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xb1, 0x73,
+			0xce, 0x13, 0x00, 0x00, 0x06, 0x4b, 0x80, 0x00, 0x10, 0x38, 0x00, 0x00,
+			0x00, 0x82, 0x01, 0x05, 0x00, 0x20, 0x00, 0x22, 0x3d, 0x46, 0x83, 0x42,
+			0x0c, 0x98, 0x85, 0x4a, 0xce, 0x19, 0xa7, 0x0c, 0x93, 0xc5, 0xdc, 0x91,
+			0x4e, 0x14, 0x24, 0x2c, 0x5c, 0xf3, 0x84, 0xc0,
+		}
+	)
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+		Expect(fs.MkdirAll("/var/log", 0755)).Should(Succeed())
+	})
+
+	Describe("DetectCompression", func() {
+		DescribeTable("should detect the compression from the magic bytes", func(data func() []byte, expected processor.Compression) {
+			Expect(afero.WriteFile(fs, "/var/log/file", data(), 0644)).Should(Succeed())
+			compression, err := processor.DetectCompression(fs, "/var/log/file")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(compression).Should(Equal(expected))
+		},
+			Entry("file is a text file", func() []byte { return []byte(content) }, processor.NoCompression),
+			Entry("file is empty", func() []byte { return nil }, processor.NoCompression),
+			Entry("file is compressed with gzip", gzipContent, processor.Gzip),
+			Entry("file is compressed with zstd", zstdContent, processor.Zstd),
+			Entry("file is compressed with bzip2", func() []byte { return bzip2Content }, processor.Bzip2),
+		)
+
+		When("file does not exist", func() {
+			It("should return an error", func() {
+				_, err := processor.DetectCompression(fs, "I_dont_exist")
+				Expect(err).Should(MatchError(ContainSubstring("failed to open file")))
+			})
+		})
+	})
+
+	Describe("OpenTailReader", func() {
+		DescribeTable("should read the decompressed content from the end to the start", func(data func() []byte) {
+			Expect(afero.WriteFile(fs, "/var/log/file", data(), 0644)).Should(Succeed())
+			reader, err := processor.OpenTailReader(fs, "/var/log/file", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reader.Size()).Should(BeEquivalentTo(len(content)))
+
+			eb := processor.NewEventBreaker(reader, processor.ReverseScanLines, 10)
+			var events []string
+			for {
+				e, err := eb.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, e)
+			}
+			Expect(events).Should(Equal([]string{"event_3", "event_2", "event_1"}))
+
+			Expect(reader.Close()).Should(Succeed())
+			// the temporary file is written in the temporary folder of the OS, not in the file system of the log files
+			tmp, err := afero.Glob(fs, "/tmp/*")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tmp).Should(BeEmpty())
+		},
+			Entry("file is a text file", func() []byte { return []byte(content) }),
+			Entry("file is compressed with gzip", gzipContent),
+			Entry("file is compressed with zstd", zstdContent),
+			Entry("file is compressed with bzip2", func() []byte { return bzip2Content }),
+		)
+
+		When("compressed file is corrupted", func() {
+			It("should return an error", func() {
+				Expect(afero.WriteFile(fs, "/var/log/file", gzipContent()[:15], 0644)).Should(Succeed())
+				_, err := processor.OpenTailReader(fs, "/var/log/file", nil)
+				Expect(err).Should(MatchError(ContainSubstring("failed to decompress file")))
+				tmp, err := afero.Glob(fs, "/tmp/*")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tmp).Should(BeEmpty())
+			})
+		})
+	})
+})
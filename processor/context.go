@@ -0,0 +1,160 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import "io"
+
+// MaxContextEvents is the maximum number of events that can be returned before or after a matching event
+const MaxContextEvents = 100
+
+// contextEvent is an event read by a ContextEventProcessor
+type contextEvent struct {
+	event string
+	// index is the position of the event among the events read
+	index int
+	match bool
+	// offset locates the event, see OffsetEventProcessor
+	offset int64
+}
+
+// contextRing keeps the most recent events read up to its capacity
+type contextRing struct {
+	events []contextEvent
+	start  int
+	size   int
+}
+
+func (r *contextRing) push(e contextEvent) {
+	if len(r.events) == 0 {
+		return
+	}
+	if r.size < len(r.events) {
+		r.events[(r.start+r.size)%len(r.events)] = e
+		r.size++
+		return
+	}
+	// the ring is full, the oldest event read is overwritten
+	r.events[r.start] = e
+	r.start = (r.start + 1) % len(r.events)
+}
+
+// drain appends the events of the ring to dst in the order they were read and empties the ring
+func (r *contextRing) drain(dst []contextEvent) []contextEvent {
+	for i := 0; i < r.size; i++ {
+		dst = append(dst, r.events[(r.start+i)%len(r.events)])
+	}
+	r.start, r.size = 0, 0
+	return dst
+}
+
+// ContextEventProcessor returns the events that match a filter along with the events that surround them in the file,
+// like grep -B and -A. Since the events are read from the most recent to the oldest, the events located after a match
+// in the file are kept in a ring buffer until an older event matches, while the events located before a match are the
+// ones read after it. The contiguous events are returned once and form a group, so that the context of close matches
+// is merged.
+type ContextEventProcessor struct {
+	processor EventProcessor
+	filter    EventFilter
+	before    int
+	limit     uint
+
+	read int
+	// newer contains the most recent events that may be located after an older match
+	newer contextRing
+	// queue contains the events to return
+	queue []contextEvent
+	// older is the number of events still to return before the last match
+	older   int
+	matches uint
+
+	last  contextEvent
+	group int
+}
+
+// NewContextEventProcessor creates a ContextEventProcessor that returns up to before events located before each event
+// matching the filter and up to after events located after it. It stops once the context of the limit-th match is
+// returned.
+func NewContextEventProcessor(processor EventProcessor, filter EventFilter, before int, after int,
+	limit uint) *ContextEventProcessor {
+	return &ContextEventProcessor{
+		processor: processor,
+		filter:    filter,
+		before:    before,
+		limit:     limit,
+		newer:     contextRing{events: make([]contextEvent, after)},
+		last:      contextEvent{index: -2},
+		group:     -1,
+	}
+}
+
+// Next implements EventProcessor contract
+func (p *ContextEventProcessor) Next() (string, error) {
+	for len(p.queue) == 0 {
+		if p.matches == p.limit && p.older == 0 {
+			return "", io.EOF
+		}
+		event, err := p.processor.Next()
+		if err != nil {
+			return "", err
+		}
+		e := contextEvent{event: event, index: p.read, match: p.filter(event), offset: offsetOf(p.processor)}
+		p.read++
+		switch {
+		case e.match && p.matches < p.limit:
+			p.matches++
+			p.queue = append(p.newer.drain(p.queue), e)
+			p.older = p.before
+		case p.older > 0:
+			// a match beyond the limit is only part of the context of the last match
+			e.match = false
+			p.older--
+			p.queue = append(p.queue, e)
+		default:
+			p.newer.push(e)
+		}
+	}
+	e := p.queue[0]
+	p.queue = p.queue[1:]
+	if e.index != p.last.index+1 {
+		p.group++
+	}
+	p.last = e
+	return e.event, nil
+}
+
+// Offset implements OffsetEventProcessor contract
+func (p *ContextEventProcessor) Offset() int64 {
+	return p.last.offset
+}
+
+// Match returns whether the last event returned matches the filter
+func (p *ContextEventProcessor) Match() bool {
+	return p.last.match
+}
+
+// Group returns the group of the last event returned, the groups are numbered from 0 in the order they are returned
+func (p *ContextEventProcessor) Group() int {
+	return p.group
+}
+
+// Matches returns the number of matches whose context was returned or is being returned
+func (p *ContextEventProcessor) Matches() uint {
+	return p.matches
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/dvergnes/log-collector/mocks"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("ContextEventProcessor", func() {
+
+	// the events are the line numbers of a file of 10 lines, read from the most recent to the oldest one
+	lines := func() *mocks.EventProcessor {
+		p := &mocks.EventProcessor{}
+		for i := 10; i > 0; i-- {
+			p.On("Next").Return(strconv.Itoa(i), nil).Once()
+		}
+		p.On("Next").Return("", io.EOF).Once()
+		return p
+	}
+	matching := func(lines ...string) processor.EventFilter {
+		return func(event string) bool {
+			for _, line := range lines {
+				if event == line {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	type groupedEvent struct {
+		group int
+		event string
+		match bool
+	}
+
+	readAll := func(p *processor.ContextEventProcessor) []groupedEvent {
+		events := []groupedEvent{}
+		for {
+			s, err := p.Next()
+			if err == io.EOF {
+				return events
+			}
+			Expect(err).ShouldNot(HaveOccurred())
+			events = append(events, groupedEvent{group: p.Group(), event: s, match: p.Match()})
+		}
+	}
+
+	DescribeTable("should return the matching events with their context",
+		func(matches []string, before int, after int, limit uint, expected []groupedEvent) {
+			p := processor.NewContextEventProcessor(lines(), matching(matches...), before, after, limit)
+			Expect(readAll(p)).Should(Equal(expected))
+		},
+		Entry("separate windows", []string{"8", "3"}, 1, 1, uint(10), []groupedEvent{
+			{0, "9", false}, {0, "8", true}, {0, "7", false},
+			{1, "4", false}, {1, "3", true}, {1, "2", false},
+		}),
+		Entry("overlapping windows", []string{"8", "6"}, 1, 1, uint(10), []groupedEvent{
+			{0, "9", false}, {0, "8", true}, {0, "7", false}, {0, "6", true}, {0, "5", false},
+		}),
+		Entry("adjacent windows", []string{"8", "5"}, 1, 1, uint(10), []groupedEvent{
+			{0, "9", false}, {0, "8", true}, {0, "7", false}, {0, "6", false}, {0, "5", true}, {0, "4", false},
+		}),
+		Entry("windows truncated by the ends of the file", []string{"10", "1"}, 2, 2, uint(10), []groupedEvent{
+			{0, "10", true}, {0, "9", false}, {0, "8", false},
+			{1, "3", false}, {1, "2", false}, {1, "1", true},
+		}),
+		Entry("only the events located after", []string{"5"}, 0, 3, uint(10), []groupedEvent{
+			{0, "8", false}, {0, "7", false}, {0, "6", false}, {0, "5", true},
+		}),
+		Entry("limit of matches", []string{"8", "7", "3"}, 1, 1, uint(1), []groupedEvent{
+			{0, "9", false}, {0, "8", true}, {0, "7", false},
+		}),
+		Entry("match beyond the limit in the window of the last match", []string{"9", "7", "5"}, 3, 0, uint(2),
+			[]groupedEvent{{0, "9", true}, {0, "8", false}, {0, "7", true}, {0, "6", false}, {0, "5", false},
+				{0, "4", false}}),
+		Entry("no match", []string{"11"}, 1, 1, uint(10), []groupedEvent{}),
+	)
+
+	It("should locate the last event returned", func() {
+		fs := afero.NewMemMapFs()
+		Expect(afero.WriteFile(fs, "/var/log/file.log", []byte("7\n8\n9\n10\n"), 0644)).Should(Succeed())
+		reader, err := processor.NewTailReader(fs, "/var/log/file.log")
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(reader.Close)
+		breaker := processor.NewEventBreaker(reader, processor.ReverseScanLines, 64)
+
+		p := processor.NewContextEventProcessor(breaker, matching("8"), 0, 1, 2)
+		Expect(p.Next()).Should(Equal("9"))
+		Expect(p.Offset()).Should(BeNumerically("<", breaker.Offset()))
+		Expect(p.Matches()).Should(BeEquivalentTo(1))
+		Expect(breaker.Rewind(p.Offset())).Should(Succeed())
+		Expect(breaker.Next()).Should(Equal("8"))
+	})
+
+	It("should propagate the error of the decorated processor", func() {
+		criticalError := errors.New("oops")
+		delegate := &mocks.EventProcessor{}
+		delegate.On("Next").Return("", criticalError).Once()
+		p := processor.NewContextEventProcessor(delegate, matching("1"), 1, 1, 10)
+		_, err := p.Next()
+		Expect(err).Should(Equal(criticalError))
+		delegate.AssertExpectations(GinkgoT())
+	})
+})
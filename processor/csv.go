@@ -0,0 +1,142 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReverseScanCSVRecords is similar to ReverseScanLines except that the new lines located in quoted fields do not end
+// the records. Since the bytes are scanned backward from the end of a record, a new line ends a record when it is
+// followed by an even number of quotes in the record.
+var ReverseScanCSVRecords = func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+	// trailing new lines do not belong to the record
+	end := len(data)
+	for end > 0 && (data[end-1] == '\n' || data[end-1] == '\r') {
+		end--
+	}
+	if end == 0 {
+		return len(data), data[:0], nil
+	}
+	quotes := 0
+	for i := end - 1; i >= 0; i-- {
+		switch data[i] {
+		case '"':
+			quotes++
+		case '\n':
+			if quotes%2 == 0 {
+				return len(data) - i, data[i+1 : end], nil
+			}
+		}
+	}
+	// the first record may be truncated so it can only be returned at EOF
+	if atEOF {
+		return len(data), data[:end], nil
+	}
+	return 0, nil, nil
+}
+
+// CSVParser parses the records of a delimited file e.g. a CSV or a TSV file, whose first record is the header that
+// names the columns. The fields may be quoted, in which case they can contain the delimiter, new lines and quotes
+// written twice. The header must be read by ReadHeader before the records are parsed.
+type CSVParser struct {
+	comma  rune
+	header []string
+	// rawHeader is the header as written in the file
+	rawHeader string
+}
+
+// NewCSVParser creates a CSVParser for the records whose fields are separated by the given delimiter
+func NewCSVParser(comma rune) *CSVParser {
+	return &CSVParser{comma: comma}
+}
+
+// ReadHeader reads the header from the start of the file, independently of the position of the reader. The header must
+// not be longer than maxSize bytes. A file without header is considered as empty.
+func (p *CSVParser) ReadHeader(reader io.ReaderAt, maxSize int) error {
+	buf := make([]byte, maxSize)
+	n, err := reader.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read CSV header %w", err)
+	}
+	buf = bytes.TrimPrefix(buf[:n], []byte("\ufeff"))
+	if len(bytes.TrimRight(buf, "\r\n")) == 0 {
+		p.header, p.rawHeader = nil, ""
+		return nil
+	}
+	r := p.newReader(bytes.NewReader(buf))
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to parse CSV header %w", err)
+	}
+	offset := int(r.InputOffset())
+	if n == maxSize && offset == len(buf) && buf[offset-1] != '\n' {
+		return fmt.Errorf("CSV header must not be longer than %d bytes", maxSize)
+	}
+	p.header = header
+	p.rawHeader = strings.TrimRight(string(buf[:offset]), "\r\n")
+	return nil
+}
+
+// IsRecord returns whether the event is a record rather than the header. It can be used as an EventFilter to exclude
+// the header from the events.
+func (p *CSVParser) IsRecord(event string) bool {
+	return p.header == nil || strings.TrimPrefix(event, "\ufeff") != p.rawHeader
+}
+
+// Parse implements EventParser contract. The fields are named by the columns of the header, the record must have as
+// many fields as the header.
+func (p *CSVParser) Parse(event string) (Fields, error) {
+	if p.header == nil {
+		return nil, fmt.Errorf("failed to find CSV header %w", ParseErr)
+	}
+	r := p.newReader(strings.NewReader(event))
+	record, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV record %w", ParseErr)
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse CSV record, event contains several records %w", ParseErr)
+	}
+	if len(record) != len(p.header) {
+		return nil, fmt.Errorf("failed to parse CSV record, record has %d fields but header has %d %w", len(record),
+			len(p.header), ParseErr)
+	}
+	fields := make(Fields, len(record))
+	for i, value := range record {
+		fields[p.header[i]] = value
+	}
+	return fields, nil
+}
+
+func (p *CSVParser) newReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = p.comma
+	reader.FieldsPerRecord = -1
+	return reader
+}
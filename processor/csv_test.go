@@ -0,0 +1,131 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"io"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	"github.com/spf13/afero"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CSV", func() {
+	var fs afero.Fs
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+	})
+
+	open := func(content string) processor.TailReader {
+		Expect(afero.WriteFile(fs, "/var/log/audit.csv", []byte(content), 0644)).Should(Succeed())
+		reader, err := processor.NewTailReader(fs, "/var/log/audit.csv")
+		Expect(err).ShouldNot(HaveOccurred())
+		DeferCleanup(reader.Close)
+		return reader
+	}
+
+	Describe("ReverseScanCSVRecords", func() {
+		readAll := func(content string, bufferSize int) []string {
+			eb := processor.NewEventBreaker(open(content), processor.ReverseScanCSVRecords, bufferSize)
+			var events []string
+			for {
+				e, err := eb.Next()
+				if err == io.EOF {
+					return events
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, e)
+			}
+		}
+
+		It("should not break the records on the new lines of quoted fields", func() {
+			events := readAll("time,user,comment\r\n"+
+				"2022-01-01,alice,\"first line\nsecond \"\"line\"\"\n\"\r\n"+
+				"2022-01-02,bob,plain\n"+
+				"\n"+
+				"2022-01-03,\"carol\",\"a,b\"\n", 64)
+			Expect(events).Should(Equal([]string{
+				`2022-01-03,"carol","a,b"`,
+				"2022-01-02,bob,plain",
+				"2022-01-01,alice,\"first line\nsecond \"\"line\"\"\n\"",
+				"time,user,comment",
+			}))
+		})
+	})
+
+	Describe("CSVParser", func() {
+		It("should name the fields after the header", func() {
+			parser := processor.NewCSVParser(',')
+			Expect(parser.ReadHeader(open("\ufefftime,user,comment\n2022-01-01,alice,hi\n"), 100)).Should(Succeed())
+			fields, err := parser.Parse("2022-01-02,\"bob\",\"a, \"\"quoted\"\"\nnote\"")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields).Should(Equal(processor.Fields{
+				"time":    "2022-01-02",
+				"user":    "bob",
+				"comment": "a, \"quoted\"\nnote",
+			}))
+		})
+
+		It("should parse tab separated values", func() {
+			parser := processor.NewCSVParser('\t')
+			Expect(parser.ReadHeader(open("time\tuser\n"), 100)).Should(Succeed())
+			Expect(parser.Parse("2022-01-02\tbob")).Should(Equal(processor.Fields{"time": "2022-01-02", "user": "bob"}))
+		})
+
+		It("should read a header that contains quoted new lines", func() {
+			parser := processor.NewCSVParser(',')
+			Expect(parser.ReadHeader(open("\"user\nname\",id\r\nalice,1\n"), 100)).Should(Succeed())
+			Expect(parser.IsRecord("\"user\nname\",id")).Should(BeFalse())
+			Expect(parser.IsRecord("alice,1")).Should(BeTrue())
+			Expect(parser.Parse("alice,1")).Should(Equal(processor.Fields{"user\nname": "alice", "id": "1"}))
+		})
+
+		It("should reject a header longer than the maximum size", func() {
+			parser := processor.NewCSVParser(',')
+			Expect(parser.ReadHeader(open("time,user,comment\n"), 10)).Should(MatchError("CSV header must not be longer than 10 bytes"))
+		})
+
+		It("should consider a file without header as empty", func() {
+			parser := processor.NewCSVParser(',')
+			Expect(parser.ReadHeader(open("\n"), 100)).Should(Succeed())
+			Expect(parser.IsRecord("a,b")).Should(BeTrue())
+			_, err := parser.Parse("a,b")
+			Expect(err).Should(MatchError(processor.ParseErr))
+		})
+
+		DescribeTable("should return a parse error", func(event string) {
+			parser := processor.NewCSVParser(',')
+			Expect(parser.ReadHeader(open("time,user\n"), 100)).Should(Succeed())
+			_, err := parser.Parse(event)
+			Expect(err).Should(MatchError(processor.ParseErr))
+		},
+			Entry("record has fewer fields", "2022-01-01"),
+			Entry("record has more fields", "2022-01-01,alice,extra"),
+			Entry("quote is not closed", `2022-01-01,"alice`),
+			Entry("quote is in a bare field", `2022-01-01,al"ice`),
+			Entry("event contains several records", "2022-01-01,alice\n2022-01-02,bob"),
+			Entry("event is empty", ""),
+		)
+	})
+})
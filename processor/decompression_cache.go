@@ -0,0 +1,225 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// DecompressionCache keeps the decompressed content of the compressed files so that a file is decompressed only once
+// for all the readers that read it. gzip and bzip2 streams cannot be resumed from an arbitrary offset, therefore the
+// whole decompressed content is kept in a temporary file that serves as the index of the decompressed offsets.
+// An entry is keyed by the name of the file and is invalidated when the file identity, size or modification time
+// changes. The least recently used entries that are not read anymore are evicted once the total size of the
+// decompressed files exceeds the maximum size of the cache.
+type DecompressionCache struct {
+	tempFs      afero.Fs
+	tempFolder  string
+	maxFileSize int64
+	maxSize     int64
+
+	mu    sync.Mutex
+	files map[string]*decompressedFile
+	size  int64
+	clock uint64
+}
+
+type decompressedFile struct {
+	stat os.FileInfo
+	// archiveSize and modTime are copied since some file systems return a FileInfo that reflects the later changes
+	archiveSize int64
+	modTime     time.Time
+	// ready is closed once the file is decompressed
+	ready chan struct{}
+	path  string
+	size  int64
+	err   error
+	// refs counts the readers of the decompressed file
+	refs    int
+	lastUse uint64
+	counted bool
+	evicted bool
+}
+
+// NewDecompressionCache creates a DecompressionCache that writes the decompressed files in tempFolder of tempFs, or in
+// the temporary folder of tempFs if tempFolder is empty. Neither should be the ones of the log files so that the
+// decompressed files are not read as log files. maxFileSize is the maximum size in bytes of a decompressed file and
+// maxSize is the maximum total size in bytes of the decompressed files that are not read.
+func NewDecompressionCache(tempFs afero.Fs, tempFolder string, maxFileSize, maxSize int64) *DecompressionCache {
+	return &DecompressionCache{
+		tempFs:      tempFs,
+		tempFolder:  tempFolder,
+		maxFileSize: maxFileSize,
+		maxSize:     maxSize,
+		files:       make(map[string]*decompressedFile),
+	}
+}
+
+// Size returns the total size in bytes of the decompressed files kept by the cache
+func (c *DecompressionCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Close removes the decompressed files. The files that are still read are removed once their readers are closed.
+func (c *DecompressionCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for name, file := range c.files {
+		if evictErr := c.evict(name, file); err == nil {
+			err = evictErr
+		}
+	}
+	return err
+}
+
+func (c *DecompressionCache) open(fs afero.Fs, name string, compression Compression) (TailReader, error) {
+	stat, err := fs.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file metadata %w", err)
+	}
+
+	c.mu.Lock()
+	file, found := c.files[name]
+	if found && !file.isSameArchive(stat) {
+		_ = c.evict(name, file)
+		found = false
+	}
+	if !found {
+		file = &decompressedFile{
+			stat:        stat,
+			archiveSize: stat.Size(),
+			modTime:     stat.ModTime(),
+			ready:       make(chan struct{}),
+		}
+		c.files[name] = file
+	}
+	file.refs++
+	c.clock++
+	file.lastUse = c.clock
+	c.mu.Unlock()
+
+	if found {
+		<-file.ready
+	} else {
+		c.decompress(fs, name, file, compression)
+	}
+	if file.err != nil {
+		_ = c.release(file)
+		return nil, file.err
+	}
+
+	reader, err := NewTailReader(c.tempFs, file.path)
+	if err != nil {
+		_ = c.release(file)
+		return nil, err
+	}
+	return &decompressedTailReader{
+		TailReader: reader,
+		release: func() error {
+			return c.release(file)
+		},
+	}, nil
+}
+
+// decompress decompresses the file and notifies the readers that wait for it
+func (c *DecompressionCache) decompress(fs afero.Fs, name string, file *decompressedFile, compression Compression) {
+	path, size, err := decompress(fs, name, compression, c.tempFs, c.tempFolder, c.maxFileSize)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer close(file.ready)
+	file.path, file.size, file.err = path, size, err
+	if err != nil {
+		// the failure is not cached so that the next reader tries again
+		if c.files[name] == file {
+			delete(c.files, name)
+		}
+		file.evicted = true
+		return
+	}
+	if file.evicted {
+		return
+	}
+	file.counted = true
+	c.size += size
+	c.evictLeastRecentlyUsed()
+}
+
+// release is called once a reader of the decompressed file is closed
+func (c *DecompressionCache) release(file *decompressedFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file.refs--
+	return c.removeUnused(file)
+}
+
+// evictLeastRecentlyUsed evicts the least recently used files that are not read until the size of the cache does not
+// exceed its maximum size
+func (c *DecompressionCache) evictLeastRecentlyUsed() {
+	for c.size > c.maxSize {
+		var lruName string
+		var lru *decompressedFile
+		for name, file := range c.files {
+			if file.refs == 0 && file.counted && (lru == nil || file.lastUse < lru.lastUse) {
+				lruName, lru = name, file
+			}
+		}
+		if lru == nil {
+			return
+		}
+		_ = c.evict(lruName, lru)
+	}
+}
+
+func (c *DecompressionCache) evict(name string, file *decompressedFile) error {
+	delete(c.files, name)
+	file.evicted = true
+	if file.counted {
+		c.size -= file.size
+		file.counted = false
+	}
+	return c.removeUnused(file)
+}
+
+// removeUnused removes the decompressed file once it is evicted and not read anymore
+func (c *DecompressionCache) removeUnused(file *decompressedFile) error {
+	if !file.evicted || file.refs > 0 || file.path == "" {
+		return nil
+	}
+	path := file.path
+	file.path = ""
+	if err := c.tempFs.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove temporary file %w", err)
+	}
+	return nil
+}
+
+// isSameArchive reports whether the FileInfo describes the content that has been decompressed
+func (f *decompressedFile) isSameArchive(stat os.FileInfo) bool {
+	return f.archiveSize == stat.Size() && f.modTime.Equal(stat.ModTime()) && isSameFile(f.stat, stat)
+}
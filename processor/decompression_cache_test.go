@@ -0,0 +1,156 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("DecompressionCache", func() {
+
+	var (
+		fs     afero.Fs
+		tempFs afero.Fs
+		cache  *processor.DecompressionCache
+
+		writeGzip = func(name, content string) {
+			b := bytes.Buffer{}
+			w := gzip.NewWriter(&b)
+			_, err := w.Write([]byte(content))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.Close()).Should(Succeed())
+			Expect(afero.WriteFile(fs, name, b.Bytes(), 0644)).Should(Succeed())
+		}
+		readAll = func(reader processor.TailReader) []string {
+			eb := processor.NewEventBreaker(reader, processor.ReverseScanLines, 10)
+			var events []string
+			for {
+				e, err := eb.Next()
+				if err == io.EOF {
+					return events
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, e)
+			}
+		}
+		tmpFiles = func() []string {
+			tmp, err := afero.Glob(tempFs, "/cache/log-collector-*")
+			Expect(err).ShouldNot(HaveOccurred())
+			return tmp
+		}
+	)
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+		tempFs = afero.NewMemMapFs()
+		Expect(tempFs.Mkdir("/cache", 0755)).Should(Succeed())
+		cache = processor.NewDecompressionCache(tempFs, "/cache", 1024, 1024)
+	})
+
+	It("should decompress a file once for all its readers", func() {
+		writeGzip("/var/log/app.log.1.gz", "event_1\nevent_2\n")
+
+		first, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+		Expect(err).ShouldNot(HaveOccurred())
+		second, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(tmpFiles()).Should(HaveLen(1))
+		Expect(cache.Size()).Should(BeEquivalentTo(16))
+		// the file system of the log files is left untouched
+		files, err := afero.Glob(fs, "/tmp/*")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(files).Should(BeEmpty())
+
+		Expect(readAll(first)).Should(Equal([]string{"event_2", "event_1"}))
+		Expect(readAll(second)).Should(Equal([]string{"event_2", "event_1"}))
+		Expect(first.Close()).Should(Succeed())
+		Expect(second.Close()).Should(Succeed())
+		Expect(tmpFiles()).Should(HaveLen(1))
+
+		Expect(cache.Close()).Should(Succeed())
+		Expect(tmpFiles()).Should(BeEmpty())
+		Expect(cache.Size()).Should(BeZero())
+	})
+
+	When("file changes", func() {
+		It("should decompress it again", func() {
+			writeGzip("/var/log/app.log.1.gz", "event_1\n")
+			reader, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			writeGzip("/var/log/app.log.1.gz", "event_1\nevent_2\nevent_3\n")
+			changed, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(readAll(changed)).Should(Equal([]string{"event_3", "event_2", "event_1"}))
+			Expect(readAll(reader)).Should(Equal([]string{"event_1"}))
+			Expect(tmpFiles()).Should(HaveLen(2))
+
+			Expect(reader.Close()).Should(Succeed())
+			Expect(tmpFiles()).Should(HaveLen(1))
+			Expect(changed.Close()).Should(Succeed())
+		})
+	})
+
+	When("file is larger than the maximum decompressed size", func() {
+		It("should return an error", func() {
+			cache = processor.NewDecompressionCache(tempFs, "/cache", 10, 1024)
+			writeGzip("/var/log/app.log.1.gz", "event_1\nevent_2\n")
+			_, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+			Expect(err).Should(MatchError(processor.DecompressedSizeErr))
+			Expect(tmpFiles()).Should(BeEmpty())
+			Expect(cache.Size()).Should(BeZero())
+		})
+	})
+
+	When("cache is full", func() {
+		It("should evict the least recently used files that are not read", func() {
+			cache = processor.NewDecompressionCache(tempFs, "/cache", 16, 32)
+			writeGzip("/var/log/app.log.1.gz", "event_1\nevent_2\n")
+			writeGzip("/var/log/app.log.2.gz", "event_3\nevent_4\n")
+			writeGzip("/var/log/app.log.3.gz", "event_5\nevent_6\n")
+
+			for _, name := range []string{"/var/log/app.log.1.gz", "/var/log/app.log.2.gz"} {
+				reader, err := processor.OpenTailReader(fs, name, cache)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(reader.Close()).Should(Succeed())
+			}
+			reader, err := processor.OpenTailReader(fs, "/var/log/app.log.1.gz", cache)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tmpFiles()).Should(HaveLen(2))
+
+			last, err := processor.OpenTailReader(fs, "/var/log/app.log.3.gz", cache)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tmpFiles()).Should(HaveLen(2))
+			Expect(cache.Size()).Should(BeEquivalentTo(32))
+			Expect(readAll(reader)).Should(Equal([]string{"event_2", "event_1"}))
+			Expect(readAll(last)).Should(Equal([]string{"event_6", "event_5"}))
+			Expect(reader.Close()).Should(Succeed())
+			Expect(last.Close()).Should(Succeed())
+		})
+	})
+})
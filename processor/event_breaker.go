@@ -22,6 +22,8 @@ package processor
 import (
 	"bufio"
 	"fmt"
+	"regexp"
+	"time"
 )
 
 // ReverseScanLines is similar to bufio.ScanLines except that it scans the bytes from right to left
@@ -42,12 +44,63 @@ var ReverseScanLines = func(data []byte, atEOF bool) (advance int, token []byte,
 	return 0, nil, nil
 }
 
+// ReverseScanMultiLines returns a bufio.SplitFunc that scans the bytes from right to left and groups the lines into
+// events. An event starts with a line matching the given regular expression e.g. a timestamp, and contains all the
+// following lines that do not match it e.g. the lines of a stack trace. Since the bytes are scanned backward, the lines
+// are accumulated until a start line is found. If no start line is found, the whole data is returned at EOF.
+func ReverseScanMultiLines(start *regexp.Regexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if len(data) == 0 {
+			return 0, nil, nil
+		}
+		// trailing new lines do not belong to the event
+		end := len(data)
+		for end > 0 && data[end-1] == '\n' {
+			end--
+		}
+		if end == 0 {
+			return len(data), data[:0], nil
+		}
+		lineEnd := end
+		for i := end - 1; i >= 0; i-- {
+			if data[i] != '\n' {
+				continue
+			}
+			if start.Match(data[i+1 : lineEnd]) {
+				return len(data) - i, data[i+1 : end], nil
+			}
+			lineEnd = i
+		}
+		// the first line may be truncated so it can only be considered as the start of the event at EOF
+		if atEOF {
+			return len(data), data[:end], nil
+		}
+		return 0, nil, nil
+	}
+}
+
 // EventProcessor defines an iterator that process an event
 type EventProcessor interface {
 	// Next returns an event as a string. It returns io.EOF if no more event will be returned
 	Next() (string, error)
 }
 
+// OffsetEventProcessor is an EventProcessor that locates the last event it returned in the content read by an
+// EventBreaker, so that the reader can be rewound right after this event even if more events were read ahead
+type OffsetEventProcessor interface {
+	EventProcessor
+	// Offset returns the number of bytes read by the EventBreaker up to the end of the last event returned
+	Offset() int64
+}
+
+// offsetOf returns the offset of the last event returned by the processor, 0 if it is not an OffsetEventProcessor
+func offsetOf(processor EventProcessor) int64 {
+	if p, ok := processor.(OffsetEventProcessor); ok {
+		return p.Offset()
+	}
+	return 0
+}
+
 // EventBreaker is responsible to identify events in an array of bytes read from a io.Reader
 type EventBreaker struct {
 	buf    []byte
@@ -55,6 +108,11 @@ type EventBreaker struct {
 
 	reader   TailReader
 	splitter bufio.SplitFunc
+
+	// read is the number of bytes read from the reader
+	read int64
+	// offset is the number of bytes read from the reader at the end of the last event returned
+	offset int64
 }
 
 // NewEventBreaker creates a new EventBreaker that reads from the passed TailReader with a buffer of the given bufferSize.
@@ -78,7 +136,8 @@ func (eb *EventBreaker) Next() (string, error) {
 	// if we cannot make progress, we try to continue to read the reader so that we can find an event boundary
 	if advance == 0 {
 		// we rewind the reader for the partial event we were reading to be on event boundary
-		eb.reader.SeekToEnd(uint32(eb.pos))
+		eb.reader.SeekToEnd(int64(eb.pos))
+		eb.read -= int64(eb.pos)
 		if err := eb.fillBuffer(); err != nil {
 			return "", err
 		}
@@ -89,11 +148,57 @@ func (eb *EventBreaker) Next() (string, error) {
 		}
 	}
 
+	eb.offset = eb.read - int64(eb.pos)
+
 	// return the event
 	return string(token), nil
 
 }
 
+// timeSeeker is implemented by the TailReader that read several files e.g. RotatedTailReader
+type timeSeeker interface {
+	SeekToTime(parser TimestampParser, until time.Time, bufferSize int) error
+}
+
+// SeekToTime positions the EventBreaker so that the next event is the most recent event at or before until. It
+// discards the content that was buffered. The events must be ordered by time, see FindTimestampOffset. When the reader
+// reads several files, the files whose events are all after until are skipped.
+func (eb *EventBreaker) SeekToTime(parser TimestampParser, until time.Time) error {
+	if seeker, ok := eb.reader.(timeSeeker); ok {
+		if err := seeker.SeekToTime(parser, until, len(eb.buf)); err != nil {
+			return fmt.Errorf("failed to seek to timestamp %w", err)
+		}
+	} else {
+		offset, err := FindTimestampOffset(eb.reader, eb.reader.Size(), parser, until, len(eb.buf))
+		if err != nil {
+			return fmt.Errorf("failed to seek to timestamp %w", err)
+		}
+		eb.reader.SeekTo(offset)
+	}
+	eb.pos = 0
+	eb.read = 0
+	eb.offset = 0
+	return nil
+}
+
+// Offset implements OffsetEventProcessor contract
+func (eb *EventBreaker) Offset() int64 {
+	return eb.offset
+}
+
+// Rewind positions the reader right after the event that ends at the given offset, as returned by Offset, so that the
+// events read after this event are returned again. The content that was buffered is discarded.
+func (eb *EventBreaker) Rewind(offset int64) error {
+	if offset < 0 || offset > eb.offset {
+		return fmt.Errorf("cannot rewind to offset %d, only the offsets up to %d can be rewound", offset, eb.offset)
+	}
+	eb.reader.SeekToEnd(eb.read - offset)
+	eb.read = offset
+	eb.offset = offset
+	eb.pos = 0
+	return nil
+}
+
 func (eb *EventBreaker) nextEvent(atEOF bool) (int, []byte, error) {
 	for {
 		// if buffer is empty, fill the buffer
@@ -126,6 +231,7 @@ func (eb *EventBreaker) fillBuffer() error {
 		return err
 	}
 	eb.pos = n
+	eb.read += int64(n)
 
 	return nil
 }
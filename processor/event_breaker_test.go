@@ -23,12 +23,16 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"os"
+	"regexp"
+	"time"
 
 	"github.com/dvergnes/log-collector/mocks"
 	"github.com/dvergnes/log-collector/processor"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -66,7 +70,7 @@ var _ = Describe("EventBreaker", func() {
 					copy(buf, content)
 					return true
 				})).Return(len(content), nil).Twice()
-				reader.On("SeekToEnd", uint32(len(content)))
+				reader.On("SeekToEnd", int64(len(content)))
 			})
 			It("should return whatever was read", func() {
 				e1, err := eventBreaker.Next()
@@ -102,7 +106,7 @@ var _ = Describe("EventBreaker", func() {
 						return n - len(b)
 					}
 				}, nil).Times(3)
-				reader.On("SeekToEnd", uint32(len("event_1")))
+				reader.On("SeekToEnd", int64(len("event_1")))
 			})
 			It("should skip the empty events", func() {
 				e1, err := eventBreaker.Next()
@@ -125,7 +129,7 @@ event_2
 					copy(buf, content)
 					return true
 				})).Return(len(content), nil).Once()
-				//reader.On("SeekToEnd", uint32(16))
+				//reader.On("SeekToEnd", int64(16))
 			})
 			It("should return events one by one in reverse order", func() {
 				e1, err := eventBreaker.Next()
@@ -163,7 +167,7 @@ event_1`
 						return len(chunk2)
 					}
 				}, nil).Twice()
-				reader.On("SeekToEnd", uint32(len("anning_over_chunks")))
+				reader.On("SeekToEnd", int64(len("anning_over_chunks")))
 			})
 			It("should fill buffer by calling the reader where it left off", func() {
 				e1, err := eventBreaker.Next()
@@ -176,6 +180,103 @@ event_1`
 			})
 		})
 
+		When("file is appended between calls", func() {
+			var fs afero.Fs
+
+			BeforeEach(func() {
+				fs = afero.NewMemMapFs()
+				Expect(afero.WriteFile(fs, "/var/log/file.log", []byte("event_1\nevent_2\nevent_3\n"), 0644)).Should(Succeed())
+				tailReader, err := processor.NewTailReader(fs, "/var/log/file.log")
+				Expect(err).ShouldNot(HaveOccurred())
+				DeferCleanup(tailReader.Close)
+				eventBreaker = processor.NewEventBreaker(tailReader, processor.ReverseScanLines, 8)
+			})
+
+			It("should return the events present when the reader was opened", func() {
+				var events []string
+				for {
+					e, err := eventBreaker.Next()
+					if err == io.EOF {
+						break
+					}
+					Expect(err).ShouldNot(HaveOccurred())
+					events = append(events, e)
+
+					f, err := fs.OpenFile("/var/log/file.log", os.O_APPEND|os.O_WRONLY, 0644)
+					Expect(err).ShouldNot(HaveOccurred())
+					_, err = f.WriteString("appended_event\n")
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(f.Close()).Should(Succeed())
+				}
+				Expect(events).Should(Equal([]string{"event_3", "event_2", "event_1"}))
+			})
+		})
+
+		When("breaker is positioned at a timestamp", func() {
+			BeforeEach(func() {
+				fs := afero.NewMemMapFs()
+				Expect(afero.WriteFile(fs, "/var/log/file.log", []byte(
+					"2020-10-05 10:00:00 event_1\n2020-10-05 11:00:00 event_2\n2020-10-05 12:00:00 event_3\n"), 0644)).Should(Succeed())
+				tailReader, err := processor.NewTailReader(fs, "/var/log/file.log")
+				Expect(err).ShouldNot(HaveOccurred())
+				DeferCleanup(tailReader.Close)
+				eventBreaker = processor.NewEventBreaker(tailReader, processor.ReverseScanLines, 64)
+			})
+
+			It("should continue to read backward from the most recent event at or before the timestamp", func() {
+				until := time.Date(2020, time.October, 5, 11, 30, 0, 0, time.UTC)
+				Expect(eventBreaker.SeekToTime(processor.DefaultTimestampFormats, until)).Should(Succeed())
+				var events []string
+				for {
+					e, err := eventBreaker.Next()
+					if err == io.EOF {
+						break
+					}
+					Expect(err).ShouldNot(HaveOccurred())
+					events = append(events, e)
+				}
+				Expect(events).Should(Equal([]string{"2020-10-05 11:00:00 event_2", "2020-10-05 10:00:00 event_1"}))
+			})
+		})
+
+		When("breaker is rewound", func() {
+			var tailReader processor.TailReader
+
+			BeforeEach(func() {
+				fs := afero.NewMemMapFs()
+				Expect(afero.WriteFile(fs, "/var/log/file.log", []byte("event_1\nevent_2\nevent_3\nevent_4\n"), 0644)).Should(Succeed())
+				var err error
+				tailReader, err = processor.NewTailReader(fs, "/var/log/file.log")
+				Expect(err).ShouldNot(HaveOccurred())
+				DeferCleanup(tailReader.Close)
+				eventBreaker = processor.NewEventBreaker(tailReader, processor.ReverseScanLines, 64)
+			})
+
+			It("should return again the events read after the rewound event", func() {
+				Expect(eventBreaker.Next()).Should(Equal("event_4"))
+				Expect(eventBreaker.Next()).Should(Equal("event_3"))
+				offset := eventBreaker.Offset()
+				Expect(eventBreaker.Next()).Should(Equal("event_2"))
+				Expect(eventBreaker.Rewind(offset)).Should(Succeed())
+				Expect(eventBreaker.Next()).Should(Equal("event_2"))
+				Expect(eventBreaker.Next()).Should(Equal("event_1"))
+			})
+
+			It("should position the reader right after the last event", func() {
+				Expect(eventBreaker.Next()).Should(Equal("event_4"))
+				Expect(eventBreaker.Rewind(eventBreaker.Offset())).Should(Succeed())
+				buf := make([]byte, 64)
+				n, err := tailReader.Read(buf)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(string(buf[:n])).Should(Equal("event_1\nevent_2\nevent_3"))
+			})
+
+			It("should return an error when the offset was not read", func() {
+				Expect(eventBreaker.Next()).Should(Equal("event_4"))
+				Expect(eventBreaker.Rewind(eventBreaker.Offset() + 1)).Should(MatchError(ContainSubstring("cannot rewind to offset")))
+			})
+		})
+
 		When("splitter fails to split", func() {
 			var (
 				content       = "start\nevent"
@@ -204,3 +305,62 @@ event_1`
 		})
 	})
 })
+
+var _ = Describe("ReverseScanMultiLines", func() {
+	var (
+		fs      afero.Fs
+		readAll = func(content string, bufferSize int) []string {
+			Expect(afero.WriteFile(fs, "/var/log/file.log", []byte(content), 0644)).Should(Succeed())
+			reader, err := processor.NewTailReader(fs, "/var/log/file.log")
+			Expect(err).ShouldNot(HaveOccurred())
+			DeferCleanup(reader.Close)
+			splitter := processor.ReverseScanMultiLines(regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `))
+			eb := processor.NewEventBreaker(reader, splitter, bufferSize)
+			var events []string
+			for {
+				e, err := eb.Next()
+				if err == io.EOF {
+					return events
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, e)
+			}
+		}
+	)
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+	})
+
+	When("events span over several lines", func() {
+		It("should group the continuation lines with their start line", func() {
+			events := readAll(`2022-01-01 first
+2022-01-02 panic: oops
+goroutine 1 [running]:
+main.main()
+
+2022-01-03 last
+
+`, 100)
+			Expect(events).Should(Equal([]string{
+				"2022-01-03 last",
+				"2022-01-02 panic: oops\ngoroutine 1 [running]:\nmain.main()",
+				"2022-01-01 first",
+			}))
+		})
+	})
+
+	When("file starts with continuation lines", func() {
+		It("should return them as an event", func() {
+			events := readAll("orphan\n2022-01-01 first\n", 100)
+			Expect(events).Should(Equal([]string{"2022-01-01 first", "orphan"}))
+		})
+	})
+
+	When("event is bigger than the buffer", func() {
+		It("should return the content of the buffer as is", func() {
+			events := readAll("2022-01-01 first\ncontinuation_1\ncontinuation_2\n", 32)
+			Expect(events).Should(Equal([]string{"t\ncontinuation_1\ncontinuation_2", "2022-01-01 firs"}))
+		})
+	})
+})
@@ -0,0 +1,255 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldPath locates a field in nested objects e.g. http.status is the status field of the http object
+type FieldPath []string
+
+// ParseFieldPath parses a dotted path e.g. http.status. It returns false if an element of the path is empty.
+func ParseFieldPath(path string) (FieldPath, bool) {
+	elements := strings.Split(path, ".")
+	for _, element := range elements {
+		if len(element) == 0 {
+			return nil, false
+		}
+	}
+	return elements, true
+}
+
+// String implements Stringer contract
+func (p FieldPath) String() string {
+	return strings.Join(p, ".")
+}
+
+// Lookup returns the value of the field located by the given path. It returns false if the field does not exist.
+func (p FieldPath) Lookup(fields Fields) (interface{}, bool) {
+	var value interface{} = map[string]interface{}(fields)
+	for _, element := range p {
+		object, ok := asObject(value)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[element]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func asObject(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case Fields:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+	return nil, false
+}
+
+// Operator compares the value of a field with the value of a Condition
+type Operator string
+
+const (
+	// Equal keeps the events whose field is equal to the value
+	Equal Operator = ":"
+	// NotEqual keeps the events whose field is not equal to the value
+	NotEqual Operator = "!="
+	// Greater keeps the events whose field is a number greater than the value
+	Greater Operator = ">"
+	// GreaterOrEqual keeps the events whose field is a number greater than or equal to the value
+	GreaterOrEqual Operator = ">="
+	// Less keeps the events whose field is a number less than the value
+	Less Operator = "<"
+	// LessOrEqual keeps the events whose field is a number less than or equal to the value
+	LessOrEqual Operator = "<="
+)
+
+// Comparable is implemented by the values of the fields that are compared in their own way e.g. the syslog severities
+type Comparable interface {
+	// Compare returns a negative number, zero or a positive number when the value is respectively less than, equal to
+	// or greater than the given value of a condition. It returns false if the value of the condition is not comparable.
+	Compare(value string) (int, bool)
+}
+
+// Condition verifies the value of a field of an event
+type Condition struct {
+	Path     FieldPath
+	Operator Operator
+	Value    string
+}
+
+// Match returns whether the fields satisfy the condition. A condition is never satisfied by a missing field. Two values
+// are equal if they are equal numbers or if they are written the same way, unless the value of the field is Comparable.
+func (c Condition) Match(fields Fields) bool {
+	value, ok := c.Path.Lookup(fields)
+	if !ok {
+		return false
+	}
+	if comparable, ok := value.(Comparable); ok {
+		return c.compare(comparable)
+	}
+	switch c.Operator {
+	case Equal:
+		return c.equal(value)
+	case NotEqual:
+		return !c.equal(value)
+	}
+	n, ok := asNumber(value)
+	if !ok {
+		return false
+	}
+	expected, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return false
+	}
+	switch c.Operator {
+	case Greater:
+		return n > expected
+	case GreaterOrEqual:
+		return n >= expected
+	case Less:
+		return n < expected
+	case LessOrEqual:
+		return n <= expected
+	}
+	return false
+}
+
+func (c Condition) compare(value Comparable) bool {
+	cmp, ok := value.Compare(c.Value)
+	if !ok {
+		return c.Operator == NotEqual
+	}
+	switch c.Operator {
+	case Equal:
+		return cmp == 0
+	case NotEqual:
+		return cmp != 0
+	case Greater:
+		return cmp > 0
+	case GreaterOrEqual:
+		return cmp >= 0
+	case Less:
+		return cmp < 0
+	case LessOrEqual:
+		return cmp <= 0
+	}
+	return false
+}
+
+func (c Condition) equal(value interface{}) bool {
+	if n, ok := asNumber(value); ok {
+		if expected, err := strconv.ParseFloat(c.Value, 64); err == nil {
+			return n == expected
+		}
+	}
+	s, ok := asString(value)
+	return ok && s == c.Value
+}
+
+// asNumber converts the value of a field into a number, the strings that contain a number are converted
+func asNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// asString converts the value of a field into a string, it returns false for objects and arrays
+func asString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case nil:
+		return "null", true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	case bool, int, int64, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// Where returns an EventFilter that verifies that the event can be parsed by the given parser and that its fields
+// satisfy all the given conditions
+func Where(parser EventParser, conditions []Condition) EventFilter {
+	return func(event string) bool {
+		fields, err := parser.Parse(event)
+		if err != nil {
+			return false
+		}
+		for _, condition := range conditions {
+			if !condition.Match(fields) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// WithProjection decorates an EventParser to only return the fields located by the given paths. The nested objects of
+// the projected fields are kept e.g. the projection of http.status is {"http": {"status": 200}}.
+func WithProjection(parser EventParser, paths []FieldPath) EventParser {
+	return EventParserFunc(func(event string) (Fields, error) {
+		fields, err := parser.Parse(event)
+		if err != nil {
+			return nil, err
+		}
+		projected := Fields{}
+		for _, path := range paths {
+			value, ok := path.Lookup(fields)
+			if !ok {
+				continue
+			}
+			object := map[string]interface{}(projected)
+			for _, element := range path[:len(path)-1] {
+				nested, ok := object[element].(map[string]interface{})
+				if !ok {
+					nested = map[string]interface{}{}
+					object[element] = nested
+				}
+				object = nested
+			}
+			object[path[len(path)-1]] = value
+		}
+		return projected, nil
+	})
+}
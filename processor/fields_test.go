@@ -0,0 +1,150 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fields", func() {
+	fields := processor.Fields{
+		"level":   "error",
+		"msg":     "oops",
+		"retries": "3",
+		"http": map[string]interface{}{
+			"status": json.Number("503"),
+			"method": "GET",
+		},
+		"ok":   false,
+		"tags": []interface{}{"a"},
+	}
+
+	path := func(p string) processor.FieldPath {
+		fp, ok := processor.ParseFieldPath(p)
+		Expect(ok).Should(BeTrue())
+		return fp
+	}
+
+	Describe("ParseFieldPath", func() {
+		DescribeTable("should reject a path with an empty element", func(p string) {
+			_, ok := processor.ParseFieldPath(p)
+			Expect(ok).Should(BeFalse())
+		},
+			Entry("path is empty", ""),
+			Entry("path starts with a dot", ".level"),
+			Entry("path contains two dots", "http..status"),
+		)
+	})
+
+	Describe("Lookup", func() {
+		DescribeTable("should return the value of the field", func(p string, expected interface{}, found bool) {
+			value, ok := path(p).Lookup(fields)
+			Expect(ok).Should(Equal(found))
+			if found {
+				Expect(value).Should(Equal(expected))
+			}
+		},
+			Entry("field is at the top level", "level", "error", true),
+			Entry("field is nested", "http.status", json.Number("503"), true),
+			Entry("field does not exist", "http.path", nil, false),
+			Entry("parent is not an object", "level.name", nil, false),
+		)
+	})
+
+	Describe("Condition", func() {
+		DescribeTable("should verify the value of the field", func(p string, operator processor.Operator, value string,
+			expected bool) {
+			Expect(processor.Condition{Path: path(p), Operator: operator, Value: value}.Match(fields)).Should(Equal(expected))
+		},
+			Entry("string is equal", "level", processor.Equal, "error", true),
+			Entry("string is not equal", "level", processor.Equal, "info", false),
+			Entry("number is equal", "http.status", processor.Equal, "503.0", true),
+			Entry("boolean is equal", "ok", processor.Equal, "false", true),
+			Entry("string is different", "level", processor.NotEqual, "info", true),
+			Entry("number is greater", "http.status", processor.Greater, "500", true),
+			Entry("number is not greater", "http.status", processor.Greater, "503", false),
+			Entry("number is greater or equal", "http.status", processor.GreaterOrEqual, "503", true),
+			Entry("number is less", "http.status", processor.Less, "600", true),
+			Entry("number is less or equal", "http.status", processor.LessOrEqual, "502", false),
+			Entry("string contains a number", "retries", processor.GreaterOrEqual, "3", true),
+			Entry("string is not a number", "level", processor.Greater, "1", false),
+			Entry("field is missing", "http.path", processor.NotEqual, "/", false),
+			Entry("field is an array", "tags", processor.Equal, "a", false),
+		)
+	})
+
+	Describe("Where", func() {
+		parser := processor.EventParserFunc(func(event string) (processor.Fields, error) {
+			if event == "raw" {
+				return nil, processor.ParseErr
+			}
+			return fields, nil
+		})
+
+		It("should keep the events that satisfy all the conditions", func() {
+			where := processor.Where(parser, []processor.Condition{
+				{Path: path("level"), Operator: processor.Equal, Value: "error"},
+				{Path: path("http.status"), Operator: processor.GreaterOrEqual, Value: "500"},
+			})
+			Expect(where("event")).Should(BeTrue())
+		})
+
+		It("should reject the events that do not satisfy a condition", func() {
+			where := processor.Where(parser, []processor.Condition{
+				{Path: path("level"), Operator: processor.Equal, Value: "error"},
+				{Path: path("http.method"), Operator: processor.Equal, Value: "POST"},
+			})
+			Expect(where("event")).Should(BeFalse())
+		})
+
+		It("should reject the events that cannot be parsed", func() {
+			Expect(processor.Where(parser, nil)("raw")).Should(BeFalse())
+		})
+	})
+
+	Describe("WithProjection", func() {
+		It("should only return the selected fields", func() {
+			parser := processor.WithProjection(processor.EventParserFunc(func(string) (processor.Fields, error) {
+				return fields, nil
+			}), []processor.FieldPath{path("level"), path("http.status"), path("missing")})
+			projected, err := parser.Parse("event")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(projected).Should(Equal(processor.Fields{
+				"level": "error",
+				"http":  map[string]interface{}{"status": json.Number("503")},
+			}))
+		})
+
+		It("should propagate the parse error", func() {
+			criticalError := errors.New("oops")
+			parser := processor.WithProjection(processor.EventParserFunc(func(string) (processor.Fields, error) {
+				return nil, criticalError
+			}), []processor.FieldPath{path("level")})
+			_, err := parser.Parse("event")
+			Expect(err).Should(Equal(criticalError))
+		})
+	})
+})
@@ -19,7 +19,11 @@
 
 package processor
 
-import "io"
+import (
+	"io"
+	"regexp"
+	"strings"
+)
 
 type limitEventProcessor struct {
 	delegate EventProcessor
@@ -52,6 +56,81 @@ func (l *limitEventProcessor) Next() (string, error) {
 // EventFilter verifies that an event matches a condition. It returns true if the event passes the check
 type EventFilter func(string) bool
 
+// Contains returns an EventFilter that verifies that the event contains the given substring
+func Contains(substr string) EventFilter {
+	return func(s string) bool {
+		return strings.Contains(s, substr)
+	}
+}
+
+// MatchRegexp returns an EventFilter that verifies that the event matches the given regular expression
+func MatchRegexp(re *regexp.Regexp) EventFilter {
+	return re.MatchString
+}
+
+// GlobToRegexp converts a glob pattern to a regular expression that matches an entire event. In the pattern, * matches
+// any sequence of characters, ? matches any single character and [...] or [!...] matches a class of characters.
+func GlobToRegexp(pattern string) (*regexp.Regexp, error) {
+	b := strings.Builder{}
+	b.WriteString("(?s)^")
+	inClass := false
+	for i, r := range pattern {
+		switch {
+		case inClass && r == ']':
+			inClass = false
+			b.WriteRune(r)
+		case inClass && r == '!' && pattern[i-1] == '[':
+			b.WriteRune('^')
+		case inClass && r == '\\':
+			b.WriteString(`\\`)
+		case inClass:
+			b.WriteRune(r)
+		case r == '*':
+			b.WriteString(".*")
+		case r == '?':
+			b.WriteString(".")
+		case r == '[':
+			inClass = true
+			b.WriteRune(r)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// And returns an EventFilter that verifies that the event passes all the given filters
+func And(filters ...EventFilter) EventFilter {
+	return func(s string) bool {
+		for _, f := range filters {
+			if !f(s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns an EventFilter that verifies that the event passes at least one of the given filters
+func Or(filters ...EventFilter) EventFilter {
+	return func(s string) bool {
+		for _, f := range filters {
+			if f(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns an EventFilter that verifies that the event does not pass the given filter
+func Not(filter EventFilter) EventFilter {
+	return func(s string) bool {
+		return !filter(s)
+	}
+}
+
 // WithFilter decorates an EventProcessor to apply a predicate that must validate the event to return it
 func WithFilter(processor EventProcessor, filter EventFilter) EventProcessor {
 	return &filterEventProcessor{
@@ -22,6 +22,7 @@ package processor_test
 import (
 	"errors"
 	"io"
+	"regexp"
 	"strconv"
 
 	"github.com/dvergnes/log-collector/mocks"
@@ -125,4 +126,36 @@ var _ = Describe("Filter", func() {
 		})
 	})
 
+	Describe("GlobToRegexp", func() {
+		DescribeTable("should convert the glob pattern", func(pattern string, event string, expected bool) {
+			re, err := processor.GlobToRegexp(pattern)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(re.MatchString(event)).Should(Equal(expected))
+		},
+			Entry("* matches any sequence", "*GET*404*", `"GET /index.html HTTP/1.1" 404 -`, true),
+			Entry("pattern matches the entire event", "GET*", `"GET /index.html HTTP/1.1" 404 -`, false),
+			Entry("? matches a single character", "HTTP/1.?", "HTTP/1.1", true),
+			Entry("class matches a character", "* [45]0? *", "GET 503 -", true),
+			Entry("negated class does not match the characters", "* [!45]0? *", "GET 503 -", false),
+			Entry("special characters are escaped", "(a+b).*", "(a+b).log", true),
+		)
+
+		When("pattern is invalid", func() {
+			It("should return an error", func() {
+				_, err := processor.GlobToRegexp("[a-")
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("And", func() {
+		DescribeTable("should verify that the event passes all the filters", func(event string, expected bool) {
+			filter := processor.And(processor.Contains("GET"), processor.MatchRegexp(regexp.MustCompile(` 5\d\d `)))
+			Expect(filter(event)).Should(Equal(expected))
+		},
+			Entry("event passes all the filters", "GET / 503 -", true),
+			Entry("event fails one filter", "GET / 200 -", false),
+		)
+	})
+
 })
@@ -0,0 +1,197 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Follower returns the events appended to a file from the oldest to the most recent one, like tail -f does
+type Follower struct {
+	file   afero.File
+	offset int64
+
+	buf          []byte
+	pollInterval time.Duration
+
+	// multilineStart matches the first line of an event, it is nil if each line is an event
+	multilineStart *regexp.Regexp
+	// pending contains the lines of the multiline event being read
+	pending []byte
+}
+
+// NewFollower creates a Follower that returns the events appended to the given file after the given offset.
+// The bufferSize defines the maximum size of an event and the pollInterval defines how often the file is checked for
+// new content. When multilineStart is not nil, the lines that do not match it are grouped with the previous line.
+func NewFollower(fs afero.Fs, name string, offset int64, bufferSize int, multilineStart *regexp.Regexp,
+	pollInterval time.Duration) (*Follower, error) {
+	file, err := openResolved(fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Follower{
+		file:           file,
+		offset:         offset,
+		buf:            make([]byte, 0, bufferSize),
+		pollInterval:   pollInterval,
+		multilineStart: multilineStart,
+	}, nil
+}
+
+// Next returns the next event appended to the file. It blocks until a complete event is appended to the file, and
+// returns io.EOF once the context is done. Since the end of a multiline event is only known when the next event
+// starts, a multiline event is also returned when nothing is appended to the file during a poll interval.
+func (f *Follower) Next(ctx context.Context) (string, error) {
+	for polls := 0; ; {
+		if event, ok := f.nextEvent(); ok {
+			return event, nil
+		}
+
+		n, err := f.read()
+		if err != nil {
+			return "", err
+		}
+		if n > 0 {
+			polls = 0
+			continue
+		}
+		if polls > 0 {
+			if event, ok := f.flush(); ok {
+				return event, nil
+			}
+		}
+
+		timer := time.NewTimer(f.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", io.EOF
+		case <-timer.C:
+		}
+		polls++
+	}
+}
+
+// nextEvent extracts the first complete event from the buffer. If no event separator is found and the buffer is full,
+// its content is returned as is.
+func (f *Follower) nextEvent() (string, bool) {
+	for {
+		i := bytes.IndexByte(f.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := f.buf[:i]
+		if f.multilineStart == nil {
+			token := string(line)
+			f.buf = f.buf[:copy(f.buf, f.buf[i+1:])]
+			// empty events are skipped
+			if len(token) != 0 {
+				return token, true
+			}
+			continue
+		}
+		event, ok := f.appendLine(line)
+		f.buf = f.buf[:copy(f.buf, f.buf[i+1:])]
+		if ok {
+			return event, true
+		}
+	}
+	if len(f.buf) == cap(f.buf) {
+		token := string(f.buf)
+		f.buf = f.buf[:0]
+		return token, true
+	}
+	return "", false
+}
+
+// appendLine adds a line to the pending multiline event. It returns the pending event when the line starts a new event
+// or when the pending event reaches the size of the buffer.
+func (f *Follower) appendLine(line []byte) (string, bool) {
+	// empty lines are skipped
+	if len(line) == 0 {
+		return "", false
+	}
+	if len(f.pending) != 0 && f.multilineStart.Match(line) {
+		event := string(f.pending)
+		f.pending = append(f.pending[:0], line...)
+		return event, true
+	}
+	if len(f.pending) != 0 {
+		f.pending = append(f.pending, '\n')
+	}
+	f.pending = append(f.pending, line...)
+	if len(f.pending) >= cap(f.buf) {
+		return f.flush()
+	}
+	return "", false
+}
+
+// flush returns the pending multiline event, if any
+func (f *Follower) flush() (string, bool) {
+	if len(f.pending) == 0 {
+		return "", false
+	}
+	event := string(f.pending)
+	f.pending = f.pending[:0]
+	return event, true
+}
+
+// read appends to the buffer the content written to the file since the last read. If the file has been truncated, it
+// starts over from the start of the file.
+func (f *Follower) read() (int, error) {
+	stat, err := readFileStat(f.file)
+	if err != nil {
+		return 0, err
+	}
+	size := stat.Size()
+	if size < f.offset {
+		f.offset = 0
+		f.buf = f.buf[:0]
+		f.pending = f.pending[:0]
+	}
+
+	length := int64(cap(f.buf) - len(f.buf))
+	if remaining := size - f.offset; remaining < length {
+		length = remaining
+	}
+	if length == 0 {
+		return 0, nil
+	}
+	start := len(f.buf)
+	n, err := f.file.ReadAt(f.buf[start:start+int(length)], f.offset)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read file %w", err)
+	}
+	f.buf = f.buf[:start+n]
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Close closes the file that the Follower reads. Any subsequent call to Next will return an error.
+func (f *Follower) Close() error {
+	return f.file.Close()
+}
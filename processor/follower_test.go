@@ -0,0 +1,186 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Follower", func() {
+
+	const name = "/var/log/file.log"
+
+	var (
+		fs       afero.Fs
+		ctx      context.Context
+		cancel   context.CancelFunc
+		follower *processor.Follower
+
+		bufferSize     int
+		multilineStart *regexp.Regexp
+
+		appendContent = func(content string) {
+			f, err := fs.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = f.WriteString(content)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(f.Close()).Should(Succeed())
+		}
+	)
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+		Expect(afero.WriteFile(fs, name, []byte("event_1\n"), 0644)).Should(Succeed())
+		ctx, cancel = context.WithCancel(context.Background())
+		bufferSize = 10
+		multilineStart = nil
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		follower, err = processor.NewFollower(fs, name, int64(len("event_1\n")), bufferSize, multilineStart, time.Millisecond)
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Expect(follower.Close()).Should(Succeed())
+	})
+
+	Describe("New", func() {
+		When("file does not exist", func() {
+			It("should return an error", func() {
+				_, err := processor.NewFollower(fs, "I_dont_exist", 0, 10, nil, time.Millisecond)
+				Expect(err).Should(MatchError(ContainSubstring("failed to open file")))
+			})
+		})
+	})
+
+	Describe("Next", func() {
+		When("events are appended", func() {
+			It("should return the appended events in chronological order", func() {
+				appendContent("event_2\n\nevent_3\n")
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("event_2"))
+
+				e2, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e2).Should(Equal("event_3"))
+			})
+		})
+
+		When("an event is partially written", func() {
+			It("should wait for the event to be complete", func() {
+				appendContent("even")
+				go func() {
+					defer GinkgoRecover()
+					time.Sleep(10 * time.Millisecond)
+					appendContent("t_2\n")
+				}()
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("event_2"))
+			})
+		})
+
+		When("an event is bigger than the buffer", func() {
+			It("should return the content of the buffer as is", func() {
+				appendContent("0123456789abc\n")
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("0123456789"))
+
+				e2, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e2).Should(Equal("abc"))
+			})
+		})
+
+		When("file is truncated", func() {
+			It("should follow the file from its start", func() {
+				Expect(afero.WriteFile(fs, name, []byte("new\n"), 0644)).Should(Succeed())
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("new"))
+			})
+		})
+
+		When("events span several lines", func() {
+			BeforeEach(func() {
+				bufferSize = 64
+				multilineStart = regexp.MustCompile(`^event`)
+			})
+
+			It("should group the lines of an event", func() {
+				appendContent("event_2\n at a\n at b\nevent_3\n")
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("event_2\n at a\n at b"))
+			})
+
+			It("should return the last event once nothing is appended during a poll interval", func() {
+				appendContent("event_2\n at a\n")
+
+				e1, err := follower.Next(ctx)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(e1).Should(Equal("event_2\n at a"))
+			})
+
+			When("an event is bigger than the buffer", func() {
+				BeforeEach(func() {
+					bufferSize = 10
+				})
+
+				It("should return the lines read so far", func() {
+					appendContent("event_2\n 12345\n 6789\n")
+
+					e1, err := follower.Next(ctx)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(e1).Should(Equal("event_2\n 12345"))
+				})
+			})
+		})
+
+		When("context is done", func() {
+			It("should return EOF", func() {
+				cancel()
+				e1, err := follower.Next(ctx)
+				Expect(err).Should(Equal(io.EOF))
+				Expect(e1).Should(BeEmpty())
+			})
+		})
+	})
+})
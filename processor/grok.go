@@ -0,0 +1,259 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GrokPatterns is the standard library of named patterns that can be referenced by the expressions of the grok parsers
+var GrokPatterns = map[string]string{
+	"WORD":       `\b\w+\b`,
+	"NOTSPACE":   `\S+`,
+	"SPACE":      `\s*`,
+	"DATA":       `.*?`,
+	"GREEDYDATA": `.*`,
+
+	"INT":       `[+-]?[0-9]+`,
+	"POSINT":    `\b[1-9][0-9]*\b`,
+	"NONNEGINT": `\b[0-9]+\b`,
+	"BASE10NUM": `[+-]?(?:[0-9]+(?:\.[0-9]*)?|\.[0-9]+)`,
+	"NUMBER":    `%{BASE10NUM}`,
+	"BASE16NUM": `(?:0[xX])?[0-9A-Fa-f]+`,
+
+	"QUOTEDSTRING": `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"UUID":         `[0-9A-Fa-f]{8}-(?:[0-9A-Fa-f]{4}-){3}[0-9A-Fa-f]{12}`,
+	"USERNAME":     `[a-zA-Z0-9._-]+`,
+	"USER":         `%{USERNAME}`,
+	"LOGLEVEL":     `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert|panic)`,
+
+	"IPV4":     `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":     `[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`,
+	"IP":       `%{IPV4}|%{IPV6}`,
+	"HOSTNAME": `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST": `%{IP}|%{HOSTNAME}`,
+	"HOSTPORT": `%{IPORHOST}:%{POSINT}`,
+
+	"PATH":         `(?:/[^\s]*)+`,
+	"URIPROTO":     `[A-Za-z][A-Za-z0-9+\-.]*`,
+	"URIPATH":      `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":     `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM": `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":          `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{IPORHOST}(?::%{POSINT})?)?(?:%{URIPATHPARAM})?`,
+
+	"MONTH":    `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|Jun(?:e)?|Jul(?:y)?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHNUM": `0?[1-9]|1[0-2]`,
+	"MONTHDAY": `0[1-9]|[12][0-9]|3[01]|[1-9]`,
+	"DAY":      `\b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b`,
+	"YEAR":     `(?:[0-9]{2}){1,2}`,
+	"HOUR":     `2[0123]|[01]?[0-9]`,
+	"MINUTE":   `[0-5][0-9]`,
+	"SECOND":   `(?:[0-5]?[0-9]|60)(?:[.,][0-9]+)?`,
+	"TIME":     `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+
+	"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}:?%{MINUTE}`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+}
+
+// GrokType defines the type a captured value is converted to
+type GrokType string
+
+const (
+	// GrokString keeps the captured value as a string
+	GrokString GrokType = ""
+	// GrokInt converts the captured value to an int
+	GrokInt GrokType = "int"
+	// GrokFloat converts the captured value to a float64
+	GrokFloat GrokType = "float"
+	// GrokTimestamp converts the captured value to a time.Time
+	GrokTimestamp GrokType = "timestamp"
+)
+
+// grokTimestampLayouts are the layouts of the timestamps converted by GrokTimestamp, a timestamp without time zone is
+// considered as UTC. The layouts without year are the ones of SYSLOGTIMESTAMP, whose year is inferred like the one of
+// the RFC 3164 timestamps.
+var grokTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+	"Jan _2 15:04:05",
+	"January _2 15:04:05",
+	"Jan _2 15:04",
+	"January _2 15:04",
+}
+
+var (
+	// grokReference matches the references to the patterns, which are validated by grokReferenceSyntax
+	grokReference       = regexp.MustCompile(`%\{[^}]*\}`)
+	grokReferenceSyntax = regexp.MustCompile(`^%\{(\w+)(?::(\w+))?(?::(\w+))?\}$`)
+)
+
+// grokCapture is a field captured by a group of the regular expression of a grok parser
+type grokCapture struct {
+	group     int
+	field     string
+	fieldType GrokType
+}
+
+type grokParser struct {
+	pattern  *regexp.Regexp
+	captures []grokCapture
+}
+
+// NewGrokParser compiles a grok expression into an EventParser. The expression is a regular expression that references
+// named patterns as %{PATTERN}, %{PATTERN:field} or %{PATTERN:field:type}, where type is one of int, float or
+// timestamp. Only the references with a field are captured, a field that is captured several times takes the last
+// value that matched. The patterns override the ones of GrokPatterns.
+func NewGrokParser(expression string, patterns map[string]string) (EventParser, error) {
+	if expression == "" {
+		return nil, errors.New("grok expression must not be empty")
+	}
+	p := &grokParser{}
+	groups := map[string]grokCapture{}
+	expanded, err := expandGrok(expression, patterns, groups, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if p.pattern, err = regexp.Compile(expanded); err != nil {
+		return nil, fmt.Errorf("failed to compile grok expression %w", err)
+	}
+	for name, capture := range groups {
+		capture.group = p.pattern.SubexpIndex(name)
+		p.captures = append(p.captures, capture)
+	}
+	// the captures are applied in the order of the groups so that the last value of a field wins
+	sort.Slice(p.captures, func(i, j int) bool {
+		return p.captures[i].group < p.captures[j].group
+	})
+	return p, nil
+}
+
+// expandGrok replaces the references of the expression by their patterns. The captured fields are added to groups by
+// name of group. The patterns being expanded are marked in visiting to detect the recursive patterns.
+func expandGrok(expression string, patterns map[string]string, groups map[string]grokCapture,
+	visiting map[string]bool) (string, error) {
+	var b strings.Builder
+	last := 0
+	for _, loc := range grokReference.FindAllStringIndex(expression, -1) {
+		b.WriteString(expression[last:loc[0]])
+		last = loc[1]
+		reference := expression[loc[0]:loc[1]]
+		m := grokReferenceSyntax.FindStringSubmatch(reference)
+		if m == nil {
+			return "", fmt.Errorf("reference %s must be formed as %%{PATTERN}, %%{PATTERN:field} or "+
+				"%%{PATTERN:field:type}", reference)
+		}
+		name, field, fieldType := m[1], m[2], GrokType(m[3])
+		pattern, ok := patterns[name]
+		if !ok {
+			pattern, ok = GrokPatterns[name]
+		}
+		if !ok {
+			return "", fmt.Errorf("pattern %s is not defined", name)
+		}
+		if visiting[name] {
+			return "", fmt.Errorf("pattern %s references itself", name)
+		}
+		switch fieldType {
+		case GrokString, GrokInt, GrokFloat, GrokTimestamp:
+		default:
+			return "", fmt.Errorf("type of field %s must be one of int, float or timestamp", field)
+		}
+
+		visiting[name] = true
+		sub, err := expandGrok(pattern, patterns, groups, visiting)
+		delete(visiting, name)
+		if err != nil {
+			return "", err
+		}
+		if field == "" {
+			b.WriteString("(?:" + sub + ")")
+			continue
+		}
+		group := fmt.Sprintf("grok%d", len(groups))
+		groups[group] = grokCapture{field: field, fieldType: fieldType}
+		b.WriteString("(?P<" + group + ">" + sub + ")")
+	}
+	b.WriteString(expression[last:])
+	return b.String(), nil
+}
+
+// Parse implements EventParser contract
+func (p *grokParser) Parse(event string) (Fields, error) {
+	m := p.pattern.FindStringSubmatchIndex(event)
+	if m == nil {
+		return nil, fmt.Errorf("failed to match grok expression %w", ParseErr)
+	}
+	fields := Fields{}
+	for _, capture := range p.captures {
+		start, end := m[2*capture.group], m[2*capture.group+1]
+		if start < 0 {
+			// the group is in an alternative that did not match
+			continue
+		}
+		value, err := convertGrok(event[start:end], capture.fieldType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert field %s %w", capture.field, err)
+		}
+		fields[capture.field] = value
+	}
+	return fields, nil
+}
+
+// convertGrok converts the captured value to the given type
+func convertGrok(value string, fieldType GrokType) (interface{}, error) {
+	switch fieldType {
+	case GrokInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ParseErr
+		}
+		return n, nil
+	case GrokFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, ParseErr
+		}
+		return f, nil
+	case GrokTimestamp:
+		for _, layout := range grokTimestampLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				if t.Year() == 0 {
+					t = inferYear(t, time.Now(), time.UTC)
+				}
+				return t, nil
+			}
+		}
+		return nil, ParseErr
+	}
+	return value, nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GrokParser", func() {
+
+	DescribeTable("should extract the fields", func(expression string, patterns map[string]string, event string,
+		expected processor.Fields) {
+		parser, err := processor.NewGrokParser(expression, patterns)
+		Expect(err).ShouldNot(HaveOccurred())
+		fields, err := parser.Parse(event)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields).Should(Equal(expected))
+	},
+		Entry("standard patterns",
+			`%{IP:client} %{WORD:method} %{URIPATHPARAM:path} %{NUMBER:status:int} %{NUMBER:duration:float}`, nil,
+			"10.0.0.12 GET /api/orders?id=3 503 0.25",
+			processor.Fields{"client": "10.0.0.12", "method": "GET", "path": "/api/orders?id=3", "status": 503,
+				"duration": 0.25}),
+		Entry("custom patterns",
+			`%{ORDER:order} shipped to %{GREEDYDATA:address}`, map[string]string{"ORDER": `ORD-%{POSINT}`},
+			"order ORD-42 shipped to 5 Main Street",
+			processor.Fields{"order": "ORD-42", "address": "5 Main Street"}),
+		Entry("custom pattern overriding a standard pattern",
+			`user=%{USER:user}`, map[string]string{"USER": `[a-z]+`},
+			"user=alice.b", processor.Fields{"user": "alice"}),
+		Entry("captures in patterns",
+			`%{REQUEST} took %{INT:ms:int}ms`, map[string]string{"REQUEST": `%{WORD:method} %{PATH:path}`},
+			"POST /login took 12ms", processor.Fields{"method": "POST", "path": "/login", "ms": 12}),
+		Entry("alternatives",
+			`(?:%{INT:code:int}|%{WORD:name})$`, nil,
+			"exit ENOENT", processor.Fields{"name": "ENOENT"}),
+		Entry("field captured several times",
+			`%{WORD:word} %{WORD:word}`, nil,
+			"first second", processor.Fields{"word": "second"}),
+	)
+
+	It("should convert the timestamps", func() {
+		parser, err := processor.NewGrokParser(
+			`^%{TIMESTAMP_ISO8601:ts:timestamp} \[%{HTTPDATE:served:timestamp}\] %{LOGLEVEL:level}`, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		fields, err := parser.Parse("2022-03-01 10:00:00.5 [01/Mar/2022:11:00:00 +0100] WARN")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields).Should(HaveLen(3))
+		Expect(fields["ts"]).Should(BeTemporally("==", time.Date(2022, 3, 1, 10, 0, 0, 500_000_000, time.UTC)))
+		Expect(fields["served"]).Should(BeTemporally("==", time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)))
+		Expect(fields["level"]).Should(Equal("WARN"))
+	})
+
+	DescribeTable("should infer the year of the syslog timestamps", func(event string, expected time.Time) {
+		parser, err := processor.NewGrokParser(`^%{SYSLOGTIMESTAMP:ts:timestamp}`, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		fields, err := parser.Parse(event)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields["ts"]).Should(BeTemporally("==", expected))
+	},
+		// January 1 is never after tomorrow so it is in the current year
+		Entry("abbreviated month", "Jan  1 10:00:02 host sshd[42]: accepted",
+			time.Date(time.Now().UTC().Year(), time.January, 1, 10, 0, 2, 0, time.UTC)),
+		Entry("full month without seconds", "January 1 10:00 host sshd[42]: accepted",
+			time.Date(time.Now().UTC().Year(), time.January, 1, 10, 0, 0, 0, time.UTC)),
+		Entry("fractional seconds", "Jan 01 10:00:02.250 host sshd[42]: accepted",
+			time.Date(time.Now().UTC().Year(), time.January, 1, 10, 0, 2, 250_000_000, time.UTC)),
+	)
+
+	It("should return a parse error when the event does not match", func() {
+		parser, err := processor.NewGrokParser(`^%{IPV4:client} `, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = parser.Parse("localhost GET /")
+		Expect(err).Should(MatchError(processor.ParseErr))
+	})
+
+	It("should return a parse error when a value cannot be converted", func() {
+		parser, err := processor.NewGrokParser(`%{NOTSPACE:count:int}`, nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = parser.Parse("many")
+		Expect(err).Should(MatchError(processor.ParseErr))
+	})
+
+	DescribeTable("should reject an invalid expression", func(expression string, patterns map[string]string,
+		message string) {
+		_, err := processor.NewGrokParser(expression, patterns)
+		Expect(err).Should(MatchError(ContainSubstring(message)))
+	},
+		Entry("empty expression", "", nil, "grok expression must not be empty"),
+		Entry("unknown pattern", "%{NOPE:x}", nil, "pattern NOPE is not defined"),
+		Entry("malformed reference", "%{IP:client.ip}", nil,
+			"reference %{IP:client.ip} must be formed as %{PATTERN}, %{PATTERN:field} or %{PATTERN:field:type}"),
+		Entry("unknown type", "%{INT:n:bool}", nil, "type of field n must be one of int, float or timestamp"),
+		Entry("recursive pattern", "%{A}", map[string]string{"A": "a%{B}", "B": "%{A}"}, "pattern A references itself"),
+		Entry("invalid regular expression", "%{INT:n} (", nil, "failed to compile grok expression"),
+		Entry("invalid custom pattern", "%{BAD}", map[string]string{"BAD": "[a-"}, "failed to compile grok expression"),
+	)
+
+	It("should compile the standard patterns", func() {
+		for name := range processor.GrokPatterns {
+			_, err := processor.NewGrokParser("%{"+name+":value}", nil)
+			Expect(err).ShouldNot(HaveOccurred(), name)
+		}
+	})
+})
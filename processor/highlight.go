@@ -0,0 +1,135 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span locates a match in an event by the byte offset of its first byte and the byte offset that follows its last byte
+type Span struct {
+	Start int
+	End   int
+}
+
+// Highlighter returns the spans where an event matches, sorted by offset and without overlap. The empty matches are
+// ignored.
+type Highlighter func(event string) []Span
+
+// HighlightSubstring returns a Highlighter of the occurrences of the given substring that ContainsWithOptions matches
+func HighlightSubstring(substr string, options MatchOptions) Highlighter {
+	if len(substr) == 0 {
+		return func(string) []Span {
+			return nil
+		}
+	}
+	if options.IgnoreCase && !isASCII(substr) {
+		re, _ := CompileRegexp(regexp.QuoteMeta(substr), options)
+		return HighlightRegexp(re, options)
+	}
+	index := strings.Index
+	if options.IgnoreCase {
+		substr = strings.ToLower(substr)
+		index = indexFoldASCII
+	}
+	return func(s string) []Span {
+		var spans []Span
+		for start := 0; start < len(s); {
+			i := index(s[start:], substr)
+			if i < 0 {
+				break
+			}
+			i += start
+			if options.WholeWord && !isWholeWord(s, i, i+len(substr)) {
+				start = i + 1
+				continue
+			}
+			spans = append(spans, Span{Start: i, End: i + len(substr)})
+			start = i + len(substr)
+		}
+		return spans
+	}
+}
+
+// HighlightRegexp returns a Highlighter of the matches of a regular expression compiled by CompileRegexp with the same
+// options
+func HighlightRegexp(re *regexp.Regexp, options MatchOptions) Highlighter {
+	if !options.WholeWord {
+		return func(s string) []Span {
+			var spans []Span
+			for _, loc := range re.FindAllStringIndex(s, -1) {
+				if loc[0] < loc[1] {
+					spans = append(spans, Span{Start: loc[0], End: loc[1]})
+				}
+			}
+			return spans
+		}
+	}
+	// the expression consumes the characters around the word, so the search resumes at the end of the word rather than
+	// at the end of the match to find the adjacent words
+	return func(s string) []Span {
+		var spans []Span
+		for start := 0; start < len(s); {
+			loc := re.FindStringSubmatchIndex(s[start:])
+			if loc == nil {
+				break
+			}
+			begin, end := loc[2]+start, loc[3]+start
+			// ^ matches the start of the remaining string, which is a boundary only if a non word character precedes it
+			falseBoundary := begin == start && start > 0 && isWordChar(s[start-1])
+			if begin == end || falseBoundary {
+				_, size := utf8.DecodeRuneInString(s[begin:])
+				start = begin + max(size, 1)
+				continue
+			}
+			spans = append(spans, Span{Start: begin, End: end})
+			start = end
+		}
+		return spans
+	}
+}
+
+// HighlightAll returns a Highlighter of the spans of all the given highlighters, the overlapping spans being merged
+func HighlightAll(highlighters ...Highlighter) Highlighter {
+	if len(highlighters) == 1 {
+		return highlighters[0]
+	}
+	return func(s string) []Span {
+		var spans []Span
+		for _, h := range highlighters {
+			spans = append(spans, h(s)...)
+		}
+		sort.Slice(spans, func(i, j int) bool {
+			return spans[i].Start < spans[j].Start
+		})
+		merged := spans[:0]
+		for _, span := range spans {
+			if n := len(merged); n > 0 && span.Start < merged[n-1].End {
+				merged[n-1].End = max(merged[n-1].End, span.End)
+				continue
+			}
+			merged = append(merged, span)
+		}
+		return merged
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"regexp"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Highlight", func() {
+
+	Describe("HighlightSubstring", func() {
+		DescribeTable("should return the spans of the occurrences of the substring", func(substr string, options processor.MatchOptions, event string, expected []processor.Span) {
+			Expect(processor.HighlightSubstring(substr, options)(event)).Should(Equal(expected))
+		},
+			Entry("all occurrences are found", "ab", processor.MatchOptions{}, "ab-ab-AB", []processor.Span{{Start: 0, End: 2}, {Start: 3, End: 5}}),
+			Entry("occurrences do not overlap", "aa", processor.MatchOptions{}, "aaa", []processor.Span{{Start: 0, End: 2}}),
+			Entry("substring is not present", "warn", processor.MatchOptions{}, "error", nil),
+			Entry("empty substring is not highlighted", "", processor.MatchOptions{}, "error", nil),
+			Entry("case is ignored", "ab", processor.MatchOptions{IgnoreCase: true}, "ab-AB", []processor.Span{{Start: 0, End: 2}, {Start: 3, End: 5}}),
+			Entry("offsets are in bytes", "été", processor.MatchOptions{}, "l'été", []processor.Span{{Start: 2, End: 7}}),
+			Entry("unicode case is ignored", "été", processor.MatchOptions{IgnoreCase: true}, "été ÉTÉ", []processor.Span{{Start: 0, End: 5}, {Start: 6, End: 11}}),
+			Entry("only whole words are found", "error", processor.MatchOptions{WholeWord: true}, "errors error log_error [error]", []processor.Span{{Start: 7, End: 12}, {Start: 24, End: 29}}),
+			Entry("adjacent whole words are found", "error", processor.MatchOptions{WholeWord: true}, "error error", []processor.Span{{Start: 0, End: 5}, {Start: 6, End: 11}}),
+			Entry("unicode whole words are found", "été", processor.MatchOptions{IgnoreCase: true, WholeWord: true}, "ÉTÉS été ÉTÉ", []processor.Span{{Start: 7, End: 12}, {Start: 13, End: 18}}),
+		)
+	})
+
+	Describe("HighlightRegexp", func() {
+		DescribeTable("should return the spans of the matches of the regular expression", func(pattern string, options processor.MatchOptions, event string, expected []processor.Span) {
+			re, err := processor.CompileRegexp(pattern, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(processor.HighlightRegexp(re, options)(event)).Should(Equal(expected))
+		},
+			Entry("all matches are found", `5\d\d`, processor.MatchOptions{}, "GET 503 504", []processor.Span{{Start: 4, End: 7}, {Start: 8, End: 11}}),
+			Entry("case is ignored", "get|head", processor.MatchOptions{IgnoreCase: true}, "GET HEAD", []processor.Span{{Start: 0, End: 3}, {Start: 4, End: 8}}),
+			Entry("empty matches are ignored", "x*", processor.MatchOptions{}, "axxb", []processor.Span{{Start: 1, End: 3}}),
+			Entry("anchor matches once", "^a", processor.MatchOptions{}, "aaa", []processor.Span{{Start: 0, End: 1}}),
+			Entry("whole words exclude the characters around them", `5\d\d`, processor.MatchOptions{WholeWord: true}, "GET 503 5030 504", []processor.Span{{Start: 4, End: 7}, {Start: 13, End: 16}}),
+			Entry("adjacent whole words are found", `5\d\d`, processor.MatchOptions{WholeWord: true}, "503 504", []processor.Span{{Start: 0, End: 3}, {Start: 4, End: 7}}),
+			Entry("words must not follow a word character", `\d+`, processor.MatchOptions{WholeWord: true}, "12 a1 34", []processor.Span{{Start: 0, End: 2}, {Start: 6, End: 8}}),
+		)
+	})
+
+	Describe("HighlightAll", func() {
+		It("should merge the spans of the highlighters", func() {
+			highlighter := processor.HighlightAll(
+				processor.HighlightSubstring("GET", processor.MatchOptions{}),
+				processor.HighlightSubstring("ET /", processor.MatchOptions{}),
+				processor.HighlightRegexp(regexp.MustCompile(`\d+`), processor.MatchOptions{}),
+			)
+			Expect(highlighter(`"GET /" 404`)).Should(Equal([]processor.Span{{Start: 1, End: 6}, {Start: 8, End: 11}}))
+		})
+	})
+
+	Describe("HighlightQuery", func() {
+		DescribeTable("should return the spans of the terms that are not negated", func(query string, event string, expected []processor.Span) {
+			highlighter, err := processor.HighlightQuery(query, processor.MatchOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(highlighter(event)).Should(Equal(expected))
+		},
+			Entry("terms are highlighted", "GET 404", `"GET /" 404`, []processor.Span{{Start: 1, End: 4}, {Start: 8, End: 11}}),
+			Entry("terms of alternatives are highlighted", "HEAD OR 404", `"GET /" 404`, []processor.Span{{Start: 8, End: 11}}),
+			Entry("negated terms are not highlighted", `GET AND NOT "/health"`, `"GET /" 404`, []processor.Span{{Start: 1, End: 4}}),
+			Entry("terms negated twice are highlighted", "NOT (NOT GET OR HEAD)", `"GET /" 404`, []processor.Span{{Start: 1, End: 4}}),
+			Entry("phrases are highlighted", `"GET /"`, `"GET /" 404`, []processor.Span{{Start: 1, End: 6}}),
+		)
+
+		When("query is invalid", func() {
+			It("should return a syntax error", func() {
+				_, err := processor.HighlightQuery("GET AND", processor.MatchOptions{})
+				var syntaxErr *processor.QuerySyntaxError
+				Expect(err).Should(BeAssignableToTypeOf(syntaxErr))
+			})
+		})
+	})
+})
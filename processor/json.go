@@ -0,0 +1,44 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONParser parses the events written as JSON objects, one per line. The numbers are kept as json.Number so that they
+// are returned as they were written.
+var JSONParser EventParser = EventParserFunc(parseJSON)
+
+func parseJSON(event string) (Fields, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(event)))
+	decoder.UseNumber()
+	fields := Fields{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON event %w", ParseErr)
+	}
+	// the event must contain a single object
+	if fields == nil || decoder.More() {
+		return nil, fmt.Errorf("failed to parse JSON event %w", ParseErr)
+	}
+	return fields, nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"encoding/json"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONParser", func() {
+
+	It("should decode the event", func() {
+		fields, err := processor.JSONParser.Parse(`{"level": "error", "http": {"status": 503}, "id": 12345678901234567890}`)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields).Should(Equal(processor.Fields{
+			"level": "error",
+			"http":  map[string]interface{}{"status": json.Number("503")},
+			"id":    json.Number("12345678901234567890"),
+		}))
+	})
+
+	DescribeTable("should return a parse error", func(event string) {
+		_, err := processor.JSONParser.Parse(event)
+		Expect(err).Should(MatchError(processor.ParseErr))
+	},
+		Entry("event is not JSON", "level=error msg=oops"),
+		Entry("event is not an object", "[1, 2]"),
+		Entry("event is null", "null"),
+		Entry("event is truncated", `{"level": "err`),
+		Entry("event contains several objects", `{"level": "error"} {"level": "info"}`),
+	)
+})
@@ -0,0 +1,114 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// LogfmtParser parses the events written in logfmt e.g. ts=2020-10-05T10:32:51Z level=info msg="request served". A
+// value is either bare or a double-quoted string whose escape sequences are the ones of Go. A key without value has an
+// empty value and the last value of a repeated key is kept. An event must contain at least one key=value pair.
+var LogfmtParser EventParser = EventParserFunc(parseLogfmt)
+
+func parseLogfmt(event string) (Fields, error) {
+	fields := Fields{}
+	pairs := 0
+	i := 0
+	for {
+		for i < len(event) && isLogfmtSpace(event[i]) {
+			i++
+		}
+		if i == len(event) {
+			break
+		}
+
+		start := i
+		for i < len(event) && isLogfmtKey(event[i]) {
+			i++
+		}
+		if i == start {
+			return nil, fmt.Errorf("failed to parse logfmt key at offset %d %w", i, ParseErr)
+		}
+		key := event[start:i]
+		if i == len(event) || event[i] != '=' {
+			if i < len(event) && !isLogfmtSpace(event[i]) {
+				return nil, fmt.Errorf("failed to parse logfmt key at offset %d %w", i, ParseErr)
+			}
+			fields[key] = ""
+			continue
+		}
+		i++
+
+		if i < len(event) && event[i] == '"' {
+			value, n, err := unquoteLogfmt(event[i:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse logfmt value at offset %d %w", i, err)
+			}
+			i += n
+			if i < len(event) && !isLogfmtSpace(event[i]) {
+				return nil, fmt.Errorf("failed to parse logfmt value at offset %d %w", i, ParseErr)
+			}
+			fields[key] = value
+		} else {
+			start = i
+			for i < len(event) && isLogfmtKey(event[i]) {
+				i++
+			}
+			if i < len(event) && !isLogfmtSpace(event[i]) {
+				return nil, fmt.Errorf("failed to parse logfmt value at offset %d %w", i, ParseErr)
+			}
+			fields[key] = event[start:i]
+		}
+		pairs++
+	}
+	if pairs == 0 {
+		return nil, fmt.Errorf("failed to find logfmt key=value pair %w", ParseErr)
+	}
+	return fields, nil
+}
+
+// unquoteLogfmt unquotes the double-quoted string at the beginning of s. It returns the number of bytes of the quoted
+// string.
+func unquoteLogfmt(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			value, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, ParseErr
+			}
+			return value, i + 1, nil
+		}
+	}
+	return "", 0, ParseErr
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isLogfmtKey returns whether the byte can be part of a key or of a bare value
+func isLogfmtKey(c byte) bool {
+	return c > ' ' && c != '=' && c != '"' && c != 0x7f
+}
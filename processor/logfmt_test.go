@@ -0,0 +1,133 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogfmtParser", func() {
+
+	DescribeTable("should parse the event", func(event string, expected processor.Fields) {
+		fields, err := processor.LogfmtParser.Parse(event)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(fields).Should(Equal(expected))
+	},
+		Entry("bare values", "ts=2020-10-05T10:32:51Z level=info status=200",
+			processor.Fields{"ts": "2020-10-05T10:32:51Z", "level": "info", "status": "200"}),
+		Entry("quoted values", `level=info msg="request served" path="/api/v1"`,
+			processor.Fields{"level": "info", "msg": "request served", "path": "/api/v1"}),
+		Entry("escaped quotes", `msg="she said \"hi\"" err="a\\b\tc"`,
+			processor.Fields{"msg": `she said "hi"`, "err": "a\\b\tc"}),
+		Entry("empty values", `user= msg="" level=info`,
+			processor.Fields{"user": "", "msg": "", "level": "info"}),
+		Entry("key without value", "level=debug verbose",
+			processor.Fields{"level": "debug", "verbose": ""}),
+		Entry("repeated key", "level=info level=error",
+			processor.Fields{"level": "error"}),
+		Entry("extra spaces", "  level=info \t msg=done  ",
+			processor.Fields{"level": "info", "msg": "done"}),
+		Entry("unicode", `msg="café ☕" user=zoë`,
+			processor.Fields{"msg": "café ☕", "user": "zoë"}),
+	)
+
+	DescribeTable("should return a parse error", func(event string) {
+		_, err := processor.LogfmtParser.Parse(event)
+		Expect(err).Should(MatchError(processor.ParseErr))
+	},
+		Entry("event is empty", ""),
+		Entry("event has no pair", "just some words"),
+		Entry("quote is not closed", `level=info msg="oops`),
+		Entry("escape is not valid", `msg="\q"`),
+		Entry("key is missing", `=value level=info`),
+		Entry("quoted value is followed by text", `msg="a"b level=info`),
+		Entry("bare value contains a quote", `msg=a"b"`),
+		Entry("event is JSON", `{"level": "info"}`),
+	)
+
+	It("should filter on the fields", func() {
+		conditions := []processor.Condition{
+			{Path: processor.FieldPath{"level"}, Operator: processor.Equal, Value: "error"},
+			{Path: processor.FieldPath{"status"}, Operator: processor.GreaterOrEqual, Value: "500"},
+		}
+		filter := processor.Where(processor.LogfmtParser, conditions)
+		Expect(filter(`level=error status=503 msg="upstream failed"`)).Should(BeTrue())
+		Expect(filter(`level=error status=404 msg="not found"`)).Should(BeFalse())
+		Expect(filter(`level=info status=503`)).Should(BeFalse())
+	})
+})
+
+// formatLogfmt writes the fields in logfmt with sorted keys and quoted values
+func formatLogfmt(fields processor.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + strconv.Quote(fields[key].(string))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func FuzzLogfmtParser(f *testing.F) {
+	for _, seed := range []string{
+		"ts=2020-10-05T10:32:51Z level=info msg=done",
+		`msg="she said \"hi\"" err="a\\b\tc"`,
+		`user= msg="" verbose`,
+		`msg="café ☕" level=info level=error`,
+		`level=info msg="oops`,
+		`=value`,
+		"",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, event string) {
+		fields, err := processor.LogfmtParser.Parse(event)
+		if err != nil {
+			return
+		}
+		if len(fields) == 0 {
+			t.Fatalf("no field parsed from %q", event)
+		}
+		formatted := formatLogfmt(fields)
+		again, err := processor.LogfmtParser.Parse(formatted)
+		if err != nil {
+			t.Fatalf("failed to parse %q formatted from %q: %v", formatted, event, err)
+		}
+		if len(again) != len(fields) {
+			t.Fatalf("parsed %v from %q formatted from %q, want %v", again, formatted, event, fields)
+		}
+		for key, value := range fields {
+			if again[key] != value {
+				t.Fatalf("parsed %v from %q formatted from %q, want %v", again, formatted, event, fields)
+			}
+		}
+	})
+}
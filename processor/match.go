@@ -0,0 +1,142 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// nonWordChar matches a character that cannot be part of a word, consistently with \b in regular expressions
+const nonWordChar = `[^0-9A-Za-z_]`
+
+// MatchOptions defines how a pattern is matched against an event
+type MatchOptions struct {
+	// IgnoreCase makes the match case-insensitive
+	IgnoreCase bool
+	// WholeWord requires the match to be preceded and followed by the boundaries of the event or by characters that are
+	// not word characters i.e. letters, digits or underscore
+	WholeWord bool
+}
+
+// ContainsWithOptions returns an EventFilter that verifies that the event contains the given substring according to
+// the given options. The case of an ASCII substring is ignored without allocating memory for each event.
+func ContainsWithOptions(substr string, options MatchOptions) EventFilter {
+	if !options.IgnoreCase && !options.WholeWord {
+		return Contains(substr)
+	}
+	if options.IgnoreCase && !isASCII(substr) {
+		// the unicode case folding is delegated to the regular expression engine
+		re, _ := CompileRegexp(regexp.QuoteMeta(substr), options)
+		return MatchRegexp(re)
+	}
+
+	index := strings.Index
+	if options.IgnoreCase {
+		substr = strings.ToLower(substr)
+		index = indexFoldASCII
+	}
+	if !options.WholeWord {
+		return func(s string) bool {
+			return index(s, substr) >= 0
+		}
+	}
+	return func(s string) bool {
+		for start := 0; start <= len(s); {
+			i := index(s[start:], substr)
+			if i < 0 {
+				return false
+			}
+			i += start
+			if isWholeWord(s, i, i+len(substr)) {
+				return true
+			}
+			start = i + 1
+		}
+		return false
+	}
+}
+
+// CompileRegexp compiles the given regular expression according to the given options
+func CompileRegexp(pattern string, options MatchOptions) (*regexp.Regexp, error) {
+	// the pattern is verified on its own so that it cannot alter the expression it is embedded in
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if !options.IgnoreCase && !options.WholeWord {
+		return re, nil
+	}
+	if options.WholeWord {
+		pattern = `(?:^|` + nonWordChar + `)(` + pattern + `)(?:` + nonWordChar + `|$)`
+	}
+	if options.IgnoreCase {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// indexFoldASCII returns the index of the first occurrence of the lower case ASCII substr in s, ignoring the case of the
+// ASCII letters of s, or -1 if substr is not present in s.
+func indexFoldASCII(s, substr string) int {
+	n := len(substr)
+	if n == 0 {
+		return 0
+	}
+	first := substr[0]
+	for i := 0; i+n <= len(s); i++ {
+		if toLowerASCII(s[i]) != first {
+			continue
+		}
+		j := 1
+		for ; j < n && toLowerASCII(s[i+j]) == substr[j]; j++ {
+		}
+		if j == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func toLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// isWholeWord reports whether s[start:end] is preceded and followed by the boundaries of s or by non word characters
+func isWholeWord(s string, start int, end int) bool {
+	return (start == 0 || !isWordChar(s[start-1])) && (end == len(s) || !isWordChar(s[end]))
+}
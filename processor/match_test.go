@@ -0,0 +1,127 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Match", func() {
+
+	Describe("ContainsWithOptions", func() {
+		DescribeTable("should verify that the event contains the substring", func(substr string, options processor.MatchOptions, event string, expected bool) {
+			Expect(processor.ContainsWithOptions(substr, options)(event)).Should(Equal(expected))
+		},
+			Entry("case is not ignored by default", "error", processor.MatchOptions{}, "ERROR: oops", false),
+			Entry("case is ignored", "error", processor.MatchOptions{IgnoreCase: true}, "ERROR: oops", true),
+			Entry("case of the substring is ignored", "ErRoR", processor.MatchOptions{IgnoreCase: true}, "error: oops", true),
+			Entry("unicode case is ignored", "été", processor.MatchOptions{IgnoreCase: true}, "ÉTÉ 2022", true),
+			Entry("substring is not present", "warn", processor.MatchOptions{IgnoreCase: true}, "ERROR: oops", false),
+			Entry("whole word is found", "error", processor.MatchOptions{WholeWord: true}, "[error] oops", true),
+			Entry("word at the boundaries of the event is found", "error", processor.MatchOptions{WholeWord: true}, "error", true),
+			Entry("substring of a word is not found", "error", processor.MatchOptions{WholeWord: true}, "errors: oops", false),
+			Entry("later whole word is found", "error", processor.MatchOptions{WholeWord: true}, "errors: error", true),
+			Entry("underscore is a word character", "error", processor.MatchOptions{WholeWord: true}, "log_error", false),
+			Entry("case is ignored for whole word", "error", processor.MatchOptions{IgnoreCase: true, WholeWord: true}, "ERRORS ERROR", true),
+			Entry("unicode case is ignored for whole word", "été", processor.MatchOptions{IgnoreCase: true, WholeWord: true}, "ÉTÉS", false),
+		)
+	})
+
+	Describe("CompileRegexp", func() {
+		DescribeTable("should compile the regular expression according to the options", func(pattern string, options processor.MatchOptions, event string, expected bool) {
+			re, err := processor.CompileRegexp(pattern, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(re.MatchString(event)).Should(Equal(expected))
+		},
+			Entry("case is not ignored by default", "err(or)?", processor.MatchOptions{}, "ERROR: oops", false),
+			Entry("case is ignored", "err(or)?", processor.MatchOptions{IgnoreCase: true}, "ERROR: oops", true),
+			Entry("whole word is found", "5\\d\\d", processor.MatchOptions{WholeWord: true}, "GET / 503 -", true),
+			Entry("substring of a word is not found", "5\\d\\d", processor.MatchOptions{WholeWord: true}, "GET / 5030 -", false),
+			Entry("alternation is grouped", "GET|HEAD", processor.MatchOptions{WholeWord: true}, "GETTER HEADER", false),
+		)
+
+		When("pattern is invalid", func() {
+			It("should return an error", func() {
+				_, err := processor.CompileRegexp("a)(b", processor.MatchOptions{WholeWord: true})
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+	})
+})
+
+func loadAccessLog(b *testing.B) []string {
+	f, err := os.Open("../log/access_combined.log")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	var events []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		events = append(events, scanner.Text())
+	}
+	return events
+}
+
+func benchmarkFilter(b *testing.B, filter processor.EventFilter) {
+	events := loadAccessLog(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range events {
+			filter(e)
+		}
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	benchmarkFilter(b, processor.Contains("Firefox"))
+}
+
+func BenchmarkContainsToLower(b *testing.B) {
+	benchmarkFilter(b, func(s string) bool {
+		return strings.Contains(strings.ToLower(s), "firefox")
+	})
+}
+
+func BenchmarkContainsIgnoreCase(b *testing.B) {
+	benchmarkFilter(b, processor.ContainsWithOptions("firefox", processor.MatchOptions{IgnoreCase: true}))
+}
+
+func BenchmarkContainsIgnoreCaseRegexp(b *testing.B) {
+	re, _ := processor.CompileRegexp("firefox", processor.MatchOptions{IgnoreCase: true})
+	benchmarkFilter(b, processor.MatchRegexp(re))
+}
+
+func BenchmarkContainsWholeWord(b *testing.B) {
+	benchmarkFilter(b, processor.ContainsWithOptions("GET", processor.MatchOptions{WholeWord: true}))
+}
+
+func BenchmarkContainsIgnoreCaseWholeWord(b *testing.B) {
+	benchmarkFilter(b, processor.ContainsWithOptions("get", processor.MatchOptions{IgnoreCase: true, WholeWord: true}))
+}
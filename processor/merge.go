@@ -0,0 +1,176 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"container/heap"
+	"io"
+	"time"
+)
+
+// MergeEventProcessor merges the events of several EventProcessors, which return their events from the most recent to
+// the oldest one, into a single stream ordered from the most recent to the oldest event according to their timestamp.
+type MergeEventProcessor struct {
+	processors []EventProcessor
+	parser     TimestampParser
+
+	heads mergeHeap
+	// queued contains the number of events of each processor in heads
+	queued []int
+	// timestamps contains the timestamp of the last timestamped event read from each processor
+	timestamps []time.Time
+	// done indicates the processors that returned EOF
+	done    []bool
+	started bool
+	// source is the index of the processor of the last event returned, -1 before the first event
+	source int
+	// sequence is the number of events pushed in heads
+	sequence int
+}
+
+// mergeHead is an event read from a processor and not returned yet
+type mergeHead struct {
+	event     string
+	timestamp time.Time
+	source    int
+	sequence  int
+}
+
+// mergeHeap orders the events from the most recent to the oldest one. An event with a zero timestamp, which belongs to
+// a file without any timestamp, comes first. Events with the same timestamp are ordered by processor, then in the order
+// they were read.
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	if !h[i].timestamp.Equal(h[j].timestamp) {
+		return h[i].timestamp.IsZero() || (!h[j].timestamp.IsZero() && h[i].timestamp.After(h[j].timestamp))
+	}
+	if h[i].source != h[j].source {
+		return h[i].source < h[j].source
+	}
+	return h[i].sequence < h[j].sequence
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(mergeHead))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	head := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return head
+}
+
+// NewMergeEventProcessor creates a MergeEventProcessor that merges the events of the given processors. The timestamp of
+// the events is extracted with the given parser. An event whose timestamp cannot be parsed, e.g. a line of a stack
+// trace, inherits the timestamp of the older event that precedes it in its file so that it stays with the event it
+// belongs to. The events at the beginning of a file, which have no older event, inherit the timestamp of the event
+// that follows them. Up to 1,000 consecutive events of a processor can wait for the timestamp of an older event,
+// PendingEventsErr is returned beyond this limit.
+func NewMergeEventProcessor(processors []EventProcessor, parser TimestampParser) *MergeEventProcessor {
+	return &MergeEventProcessor{
+		processors: processors,
+		parser:     parser,
+		queued:     make([]int, len(processors)),
+		timestamps: make([]time.Time, len(processors)),
+		done:       make([]bool, len(processors)),
+		source:     -1,
+	}
+}
+
+// Next implements EventProcessor contract
+func (m *MergeEventProcessor) Next() (string, error) {
+	if !m.started {
+		for i := range m.processors {
+			if err := m.advance(i); err != nil {
+				return "", err
+			}
+		}
+		m.started = true
+	} else if m.source >= 0 && m.queued[m.source] == 0 && !m.done[m.source] {
+		// the processor of the last event returned is read only now so that it is not read ahead
+		if err := m.advance(m.source); err != nil {
+			return "", err
+		}
+	}
+	if m.heads.Len() == 0 {
+		m.source = -1
+		return "", io.EOF
+	}
+	head := heap.Pop(&m.heads).(mergeHead)
+	m.source = head.source
+	m.queued[head.source]--
+	return head.event, nil
+}
+
+// Source returns the index of the processor of the last event returned by Next
+func (m *MergeEventProcessor) Source() int {
+	return m.source
+}
+
+// advance reads the events of the given processor up to the next event with a timestamp, which is inherited by the
+// events without timestamp read before it
+func (m *MergeEventProcessor) advance(source int) error {
+	var events []string
+	for {
+		event, err := m.processors[source].Next()
+		if err == io.EOF {
+			m.done[source] = true
+			m.push(source, events, m.timestamps[source])
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t, ok := m.parser.Parse(event)
+		if !ok {
+			if len(events) == maxPendingEvents {
+				return PendingEventsErr
+			}
+			events = append(events, event)
+			continue
+		}
+		m.timestamps[source] = t
+		m.push(source, append(events, event), t)
+		return nil
+	}
+}
+
+func (m *MergeEventProcessor) push(source int, events []string, timestamp time.Time) {
+	for _, event := range events {
+		heap.Push(&m.heads, mergeHead{
+			event:     event,
+			timestamp: timestamp,
+			source:    source,
+			sequence:  m.sequence,
+		})
+		m.sequence++
+	}
+	m.queued[source] += len(events)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"errors"
+	"io"
+
+	"github.com/dvergnes/log-collector/mocks"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergeEventProcessor", func() {
+
+	// the events are returned from the most recent to the oldest one
+	eventsOf := func(events ...string) *mocks.EventProcessor {
+		p := &mocks.EventProcessor{}
+		for _, event := range events {
+			p.On("Next").Return(event, nil).Once()
+		}
+		p.On("Next").Return("", io.EOF).Once()
+		return p
+	}
+
+	type sourcedEvent struct {
+		event  string
+		source int
+	}
+
+	readAll := func(m *processor.MergeEventProcessor) []sourcedEvent {
+		events := []sourcedEvent{}
+		for {
+			s, err := m.Next()
+			if err == io.EOF {
+				Expect(m.Source()).Should(Equal(-1))
+				return events
+			}
+			Expect(err).ShouldNot(HaveOccurred())
+			events = append(events, sourcedEvent{event: s, source: m.Source()})
+		}
+	}
+
+	It("should merge the events from the most recent to the oldest one", func() {
+		a := eventsOf("2020-10-05 10:00:05 a3", "2020-10-05 10:00:03 a2", "2020-10-05 10:00:01 a1")
+		b := eventsOf("2020-10-05 10:00:04 b2", "2020-10-05 10:00:02 b1")
+		m := processor.NewMergeEventProcessor([]processor.EventProcessor{a, b}, processor.DefaultTimestampFormats)
+
+		Expect(readAll(m)).Should(Equal([]sourcedEvent{
+			{event: "2020-10-05 10:00:05 a3", source: 0},
+			{event: "2020-10-05 10:00:04 b2", source: 1},
+			{event: "2020-10-05 10:00:03 a2", source: 0},
+			{event: "2020-10-05 10:00:02 b1", source: 1},
+			{event: "2020-10-05 10:00:01 a1", source: 0},
+		}))
+		a.AssertExpectations(GinkgoT())
+		b.AssertExpectations(GinkgoT())
+	})
+
+	It("should order the events with the same timestamp by processor", func() {
+		a := eventsOf("2020-10-05 10:00:01 a1")
+		b := eventsOf("2020-10-05 10:00:01 b1")
+		m := processor.NewMergeEventProcessor([]processor.EventProcessor{b, a}, processor.DefaultTimestampFormats)
+
+		Expect(readAll(m)).Should(Equal([]sourcedEvent{
+			{event: "2020-10-05 10:00:01 b1", source: 0},
+			{event: "2020-10-05 10:00:01 a1", source: 1},
+		}))
+	})
+
+	It("should keep the events without timestamp with the older event that precedes them in their file", func() {
+		a := eventsOf("at main.go:12", "2020-10-05 10:00:03 panic", "2020-10-05 10:00:01 a1")
+		b := eventsOf("2020-10-05 10:00:04 b2", "at api.go:7", "at server.go:40", "2020-10-05 10:00:02 b1")
+		m := processor.NewMergeEventProcessor([]processor.EventProcessor{a, b}, processor.DefaultTimestampFormats)
+
+		Expect(readAll(m)).Should(Equal([]sourcedEvent{
+			{event: "2020-10-05 10:00:04 b2", source: 1},
+			{event: "at main.go:12", source: 0},
+			{event: "2020-10-05 10:00:03 panic", source: 0},
+			{event: "at api.go:7", source: 1},
+			{event: "at server.go:40", source: 1},
+			{event: "2020-10-05 10:00:02 b1", source: 1},
+			{event: "2020-10-05 10:00:01 a1", source: 0},
+		}))
+	})
+
+	It("should give the events at the beginning of a file the timestamp of the event that follows them", func() {
+		a := eventsOf("2020-10-05 10:00:03 a1", "starting")
+		b := eventsOf("2020-10-05 10:00:04 b2", "2020-10-05 10:00:02 b1")
+		m := processor.NewMergeEventProcessor([]processor.EventProcessor{a, b}, processor.DefaultTimestampFormats)
+
+		Expect(readAll(m)).Should(Equal([]sourcedEvent{
+			{event: "2020-10-05 10:00:04 b2", source: 1},
+			{event: "2020-10-05 10:00:03 a1", source: 0},
+			{event: "starting", source: 0},
+			{event: "2020-10-05 10:00:02 b1", source: 1},
+		}))
+	})
+
+	When("too many consecutive events have no timestamp", func() {
+		It("should return an error", func() {
+			a := &mocks.EventProcessor{}
+			a.On("Next").Return("at main.go:12", nil)
+			m := processor.NewMergeEventProcessor([]processor.EventProcessor{a}, processor.DefaultTimestampFormats)
+
+			_, err := m.Next()
+			Expect(err).Should(Equal(processor.PendingEventsErr))
+		})
+	})
+
+	It("should not read a processor ahead of the events returned", func() {
+		a := &mocks.EventProcessor{}
+		a.On("Next").Return("2020-10-05 10:00:05 a2", nil).Once()
+		b := eventsOf("2020-10-05 10:00:04 b1")
+		m := processor.NewMergeEventProcessor([]processor.EventProcessor{a, b}, processor.DefaultTimestampFormats)
+
+		event, err := m.Next()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(event).Should(Equal("2020-10-05 10:00:05 a2"))
+		a.AssertExpectations(GinkgoT())
+	})
+
+	When("a processor returns an error", func() {
+		It("should propagate the error", func() {
+			criticalError := errors.New("oops")
+			a := eventsOf("2020-10-05 10:00:05 a1")
+			b := &mocks.EventProcessor{}
+			b.On("Next").Return("", criticalError).Once()
+			m := processor.NewMergeEventProcessor([]processor.EventProcessor{a, b}, processor.DefaultTimestampFormats)
+
+			_, err := m.Next()
+			Expect(err).Should(Equal(criticalError))
+		})
+	})
+
+	When("there is no processor", func() {
+		It("should return EOF", func() {
+			m := processor.NewMergeEventProcessor(nil, processor.DefaultTimestampFormats)
+			_, err := m.Next()
+			Expect(err).Should(Equal(io.EOF))
+		})
+	})
+})
@@ -0,0 +1,43 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import "errors"
+
+// ParseErr is returned when an event does not have the format expected by an EventParser
+var ParseErr = errors.New("event does not have the expected format")
+
+// Fields contains the fields extracted from an event, mapped by name
+type Fields map[string]interface{}
+
+// EventParser extracts the fields of an event
+type EventParser interface {
+	// Parse returns the fields of the event. It returns an error wrapping ParseErr if the event does not have the
+	// expected format.
+	Parse(event string) (Fields, error)
+}
+
+// EventParserFunc is a function that implements EventParser
+type EventParserFunc func(event string) (Fields, error)
+
+// Parse implements EventParser contract
+func (f EventParserFunc) Parse(event string) (Fields, error) {
+	return f(event)
+}
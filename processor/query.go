@@ -0,0 +1,268 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// QuerySyntaxError indicates that a query cannot be parsed
+type QuerySyntaxError struct {
+	// Column is the position, starting at 1, of the character where the error was detected
+	Column int
+	// Msg describes the error
+	Msg string
+}
+
+func (err *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("%s at column %d", err.Msg, err.Column)
+}
+
+type tokenKind int
+
+const (
+	termToken tokenKind = iota
+	phraseToken
+	andToken
+	orToken
+	notToken
+	openToken
+	closeToken
+	endToken
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// column is the position, starting at 1, of the first character of the token
+	column int
+}
+
+// ParseQuery compiles a query into an EventFilter. A query is made of terms, that the event must contain, combined with
+// the AND, OR and NOT operators, by order of precedence from the lowest to the highest. Terms separated by spaces are
+// combined with AND. A phrase is a term enclosed in double quotes that can contain spaces, operators and parentheses,
+// and where \" and \\ are escaped. Parentheses group the expressions e.g. (GET OR HEAD) AND NOT "/health" AND 404.
+// The terms are matched according to the given options. It returns a *QuerySyntaxError if the query is invalid.
+func ParseQuery(query string, options MatchOptions) (EventFilter, error) {
+	_, filter, err := parseQuery(query, options)
+	if err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// HighlightQuery returns a Highlighter of the terms of a query that are not negated, and returns a *QuerySyntaxError if
+// the query is invalid
+func HighlightQuery(query string, options MatchOptions) (Highlighter, error) {
+	p, _, err := parseQuery(query, options)
+	if err != nil {
+		return nil, err
+	}
+	highlighters := make([]Highlighter, 0, len(p.terms))
+	for _, term := range p.terms {
+		highlighters = append(highlighters, HighlightSubstring(term, options))
+	}
+	return HighlightAll(highlighters...), nil
+}
+
+func parseQuery(query string, options MatchOptions) (*queryParser, EventFilter, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &queryParser{tokens: tokens, options: options}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != endToken {
+		return nil, nil, &QuerySyntaxError{Column: t.column, Msg: fmt.Sprintf("unexpected %q", t.value)}
+	}
+	return p, filter, nil
+}
+
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		column := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: openToken, value: "(", column: column})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: closeToken, value: ")", column: column})
+			i++
+		case r == '"':
+			phrase := strings.Builder{}
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+					i++
+				}
+				phrase.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, &QuerySyntaxError{Column: column, Msg: "unterminated phrase"}
+			}
+			i++
+			if phrase.Len() == 0 {
+				return nil, &QuerySyntaxError{Column: column, Msg: "empty phrase"}
+			}
+			tokens = append(tokens, token{kind: phraseToken, value: phrase.String(), column: column})
+		default:
+			start := i
+			for ; i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"'; i++ {
+			}
+			tokens = append(tokens, newWordToken(string(runes[start:i]), column))
+		}
+	}
+	return append(tokens, token{kind: endToken, column: len(runes) + 1}), nil
+}
+
+func newWordToken(word string, column int) token {
+	switch word {
+	case "AND":
+		return token{kind: andToken, value: word, column: column}
+	case "OR":
+		return token{kind: orToken, value: word, column: column}
+	case "NOT":
+		return token{kind: notToken, value: word, column: column}
+	default:
+		return token{kind: termToken, value: word, column: column}
+	}
+}
+
+// queryParser is a recursive descent parser for the grammar:
+//
+//	or      = and { "OR" and }
+//	and     = not { [ "AND" ] not }
+//	not     = "NOT" not | primary
+//	primary = "(" or ")" | term | phrase
+type queryParser struct {
+	tokens  []token
+	pos     int
+	options MatchOptions
+	// negated is the number of NOT operators applied to the expression being parsed
+	negated int
+	// terms are the terms and phrases that are not negated
+	terms []string
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != endToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *queryParser) parseOr() (EventFilter, error) {
+	filter, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []EventFilter{filter}
+	for p.peek().kind == orToken {
+		p.next()
+		filter, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return Or(filters...), nil
+}
+
+func (p *queryParser) parseAnd() (EventFilter, error) {
+	filter, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	filters := []EventFilter{filter}
+	for {
+		switch p.peek().kind {
+		case andToken:
+			p.next()
+		case termToken, phraseToken, notToken, openToken:
+			// terms separated by spaces are combined with AND
+		default:
+			if len(filters) == 1 {
+				return filters[0], nil
+			}
+			return And(filters...), nil
+		}
+		filter, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+}
+
+func (p *queryParser) parseNot() (EventFilter, error) {
+	if p.peek().kind != notToken {
+		return p.parsePrimary()
+	}
+	p.next()
+	p.negated++
+	filter, err := p.parseNot()
+	p.negated--
+	if err != nil {
+		return nil, err
+	}
+	return Not(filter), nil
+}
+
+func (p *queryParser) parsePrimary() (EventFilter, error) {
+	t := p.next()
+	switch t.kind {
+	case termToken, phraseToken:
+		if p.negated%2 == 0 {
+			p.terms = append(p.terms, t.value)
+		}
+		return ContainsWithOptions(t.value, p.options), nil
+	case openToken:
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != closeToken {
+			return nil, &QuerySyntaxError{Column: closing.column, Msg: "missing closing parenthesis"}
+		}
+		return filter, nil
+	case endToken:
+		return nil, &QuerySyntaxError{Column: t.column, Msg: "unexpected end of query"}
+	default:
+		return nil, &QuerySyntaxError{Column: t.column, Msg: fmt.Sprintf("unexpected %q", t.value)}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Query", func() {
+
+	Describe("ParseQuery", func() {
+		When("query is valid", func() {
+			DescribeTable("should compile the query into a filter", func(query string, event string, expected bool) {
+				filter, err := processor.ParseQuery(query, processor.MatchOptions{})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(filter(event)).Should(Equal(expected))
+			},
+				Entry("term is contained", "GET", `"GET /index.html" 404`, true),
+				Entry("term is not contained", "HEAD", `"GET /index.html" 404`, false),
+				Entry("terms separated by spaces must all be contained", "GET 404", `"GET /index.html" 200`, false),
+				Entry("AND requires both terms", "GET AND 404", `"GET /index.html" 404`, true),
+				Entry("OR requires one term", "HEAD OR 404", `"GET /index.html" 404`, true),
+				Entry("NOT negates the term", "NOT GET", `"GET /index.html" 404`, false),
+				Entry("NOT can be repeated", "NOT NOT GET", `"GET /index.html" 404`, true),
+				Entry("AND has precedence over OR", "HEAD AND 200 OR 404", `"GET /index.html" 404`, true),
+				Entry("parentheses group expressions", "HEAD AND (200 OR 404)", `"GET /index.html" 404`, false),
+				Entry("phrase can contain spaces and operators", `"GET /index.html" AND "NOT (found)"`, `"GET /index.html" NOT (found)`, true),
+				Entry("phrase can contain escaped quotes", `"\"GET /"`, `"GET /index.html" 404`, true),
+				Entry("lowercase operators are terms", "get or head", `"GET /index.html" or 404`, false),
+				Entry("complex query matches", `(GET OR HEAD) AND NOT "/health" AND 404`, `"HEAD /index.html" 404`, true),
+				Entry("complex query does not match", `(GET OR HEAD) AND NOT "/health" AND 404`, `"GET /health" 404`, false),
+			)
+
+			It("should match the terms according to the options", func() {
+				filter, err := processor.ParseQuery(`get AND NOT "/health"`, processor.MatchOptions{IgnoreCase: true, WholeWord: true})
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(filter(`"GET /index.html" 404`)).Should(BeTrue())
+				Expect(filter(`"GETTER /index.html" 404`)).Should(BeFalse())
+				Expect(filter(`"GET /HEALTH" 404`)).Should(BeFalse())
+			})
+		})
+
+		When("query is invalid", func() {
+			DescribeTable("should return a syntax error", func(query string, column int, msg string) {
+				_, err := processor.ParseQuery(query, processor.MatchOptions{})
+				syntaxErr, ok := err.(*processor.QuerySyntaxError)
+				Expect(ok).Should(BeTrue())
+				Expect(syntaxErr.Column).Should(Equal(column))
+				Expect(syntaxErr.Msg).Should(Equal(msg))
+			},
+				Entry("query is empty", "  ", 3, "unexpected end of query"),
+				Entry("operand is missing", "GET AND", 8, "unexpected end of query"),
+				Entry("operator follows an operator", "GET OR AND 404", 8, `unexpected "AND"`),
+				Entry("closing parenthesis is missing", "(GET OR HEAD", 13, "missing closing parenthesis"),
+				Entry("closing parenthesis is unexpected", "GET) OR HEAD", 4, `unexpected ")"`),
+				Entry("phrase is unterminated", `GET "/health`, 5, "unterminated phrase"),
+				Entry("phrase is empty", `GET ""`, 5, "empty phrase"),
+				Entry("column counts characters", `"é" OR)`, 7, `unexpected ")"`),
+			)
+		})
+	})
+})
@@ -20,55 +20,135 @@
 package processor
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"time"
 
 	"github.com/spf13/afero"
 )
 
-// ConcurrentAccessErr indicates that a file has been modified while the tail reader was reading it
-var ConcurrentAccessErr = errors.New("file has been modified since the tail reader was opened")
+// ConcurrentAccessErr indicates that a file has been truncated or replaced while the tail reader was reading it
+var ConcurrentAccessErr = errors.New("file has been truncated or replaced since the tail reader was opened")
 
-// TailReader reads a file from the end to the start of the file. Once created if the file is modified, the next Read
-// call will return an error.
+// ReplacedFileErr indicates that the file found at a resolved path is a symbolic link or another file than the one
+// opened, i.e. the file has been replaced after its path was resolved
+var ReplacedFileErr = errors.New("file has been replaced while being opened")
+
+// pinLength is the number of bytes located before the pinned size that are compared to detect that a file has been
+// truncated then written again beyond the pinned size
+const pinLength = 64
+
+// TailReader reads a file from the end to the start of the file. The size of the file is pinned when the TailReader is
+// created so that the content appended afterwards is ignored. If the file is truncated, the next Read call will return
+// an error. If the file is replaced, the Read call that would return io.EOF will return an error instead.
 type TailReader interface {
 	io.Reader
 	io.Closer
+	// ReaderAt reads the content of the file at an arbitrary offset, the content appended after the TailReader is opened
+	// is ignored
+	io.ReaderAt
 	// SeekToEnd updates the offset of the TailReader towards the end of file. It basically rewinds the reader by the given
 	// offset.
-	SeekToEnd(offset uint32)
+	SeekToEnd(offset int64)
+	// SeekTo positions the TailReader at the given offset from the start of the file so that the next Read returns the
+	// content located before this offset
+	SeekTo(offset int64)
+	// Size returns the size of the file when the TailReader was opened
+	Size() int64
 }
 
 // tailReader implements TailReader interface
 type tailReader struct {
-	fs afero.Fs
+	fs   afero.Fs
+	name string
 
-	file    afero.File
-	modTime time.Time
+	file afero.File
+	stat os.FileInfo
+	size int64
+	// pin contains the last bytes of the file when the tailReader was opened
+	pin []byte
+	// checkedSize is the size of the file when its content was last checked
+	checkedSize int64
 
 	offsetFromEnd int64
 }
 
 // NewTailReader creates a tailReader for the given file in parameters
 func NewTailReader(fs afero.Fs, name string) (TailReader, error) {
-	file, err := fs.Open(name)
+	file, err := openResolved(fs, name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %w", err)
+		return nil, err
 	}
 	stat, err := readFileStat(file)
 	if err != nil {
 		return nil, err
 	}
+	pin, err := readPin(file, stat.Size())
+	if err != nil {
+		return nil, err
+	}
 	return &tailReader{
-		fs:      fs,
-		file:    file,
-		modTime: stat.ModTime(),
+		fs:   fs,
+		name: name,
+		file: file,
+		stat: stat,
+		size: stat.Size(),
+		pin:  pin,
+
+		checkedSize: stat.Size(),
 	}, nil
 }
 
+// openResolved opens a file whose path has been resolved by the caller, i.e. does not refer to a symbolic link. Since
+// the file may be replaced by a symbolic link between the resolution and the opening, ReplacedFileErr is returned if
+// the path is now a symbolic link or refers to another file than the one opened.
+func openResolved(fs afero.Fs, name string) (afero.File, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %w", err)
+	}
+	stat, err := readFileStat(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	pathStat, err := lstat(fs, name)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read file metadata %w", err)
+	}
+	if pathStat.Mode()&os.ModeSymlink != 0 || !isSameFile(stat, pathStat) {
+		file.Close()
+		return nil, fmt.Errorf("%w", ReplacedFileErr)
+	}
+	return file, nil
+}
+
+// lstat returns the FileInfo of the given file without following symbolic links when the file system supports it
+func lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+	return fs.Stat(name)
+}
+
+// readPin reads the pinLength bytes located before size, or less if the file is smaller
+func readPin(file afero.File, size int64) ([]byte, error) {
+	length := int64(pinLength)
+	if size < length {
+		length = size
+	}
+	pin := make([]byte, length)
+	n, err := file.ReadAt(pin, size-length)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file %w", err)
+	}
+	return pin[:n], nil
+}
+
 func readFileStat(file afero.File) (os.FileInfo, error) {
 	stat, err := file.Stat()
 	if err != nil {
@@ -77,23 +157,69 @@ func readFileStat(file afero.File) (os.FileInfo, error) {
 	return stat, nil
 }
 
-// Read reads a file from the end to the start.
-// Each call gets closer to the start. Once the entire file is read io.EOF is returned.
-// If the file is modified since the tailReader is created an error is returned on the next call to Read.
-// It implements io.Reader interface.
-func (tr *tailReader) Read(buf []byte) (int, error) {
+// isSameFile reports whether both FileInfo describe the same file. The file identity is only exposed by the file
+// systems backed by the OS, for the others the files are assumed to be the same.
+func isSameFile(fi1, fi2 os.FileInfo) bool {
+	if fi1.Sys() == nil || fi2.Sys() == nil {
+		return true
+	}
+	return os.SameFile(fi1, fi2)
+}
+
+// checkFile verifies that the file has not been truncated nor replaced since the tailReader is created. Since this
+// costs several system calls, the content and the path of the file are only checked when its size changed since the
+// last check, or when complete is true once the file is entirely read.
+func (tr *tailReader) checkFile(complete bool) error {
 	stat, err := readFileStat(tr.file)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	if stat.Size() < tr.size {
+		return fmt.Errorf("%w", ConcurrentAccessErr)
+	}
+	if stat.Size() == tr.checkedSize && !complete {
+		return nil
+	}
+	tr.checkedSize = stat.Size()
+	// the file may have been truncated then written beyond the pinned size since it was last checked
+	pin, err := readPin(tr.file, tr.size)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(pin, tr.pin) {
+		return fmt.Errorf("%w", ConcurrentAccessErr)
+	}
+	pathStat, err := tr.fs.Stat(tr.name)
+	if err != nil {
+		// the file has been moved away, the content that is read is still valid
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read file metadata %w", err)
 	}
-	if tr.modTime.UnixNano() != stat.ModTime().UnixNano() {
-		return 0, fmt.Errorf("%w", ConcurrentAccessErr)
+	if !isSameFile(tr.stat, pathStat) {
+		return fmt.Errorf("%w", ConcurrentAccessErr)
 	}
+	return nil
+}
 
-	size := stat.Size()
+// Read reads a file from the end to the start.
+// Each call gets closer to the start. Once the entire file is read io.EOF is returned.
+// The content appended since the tailReader is created is ignored. If the file is truncated, an error is returned on
+// the next call to Read. If the file is replaced or rewritten with the same size, an error is returned instead of
+// io.EOF.
+// It implements io.Reader interface.
+func (tr *tailReader) Read(buf []byte) (int, error) {
+	size := tr.size
 	if tr.offsetFromEnd >= size {
+		if err := tr.checkFile(true); err != nil {
+			return 0, err
+		}
 		return 0, io.EOF
 	}
+	if err := tr.checkFile(false); err != nil {
+		return 0, err
+	}
 
 	length := int64(len(buf))
 	offset := tr.offsetFromEnd + length
@@ -101,7 +227,7 @@ func (tr *tailReader) Read(buf []byte) (int, error) {
 		offset = size
 		length = size - tr.offsetFromEnd
 	}
-	_, err = tr.file.Seek(-offset, io.SeekEnd)
+	_, err := tr.file.Seek(size-offset, io.SeekStart)
 	if err != nil {
 		return 0, fmt.Errorf("failed to seek file %w", err)
 	}
@@ -112,12 +238,50 @@ func (tr *tailReader) Read(buf []byte) (int, error) {
 
 // SeekToEnd updates the offset of the TailReader towards the end of file. It basically rewinds the reader by the given
 // offset.
-func (tr *tailReader) SeekToEnd(offset uint32) {
-	if int64(offset) >= tr.offsetFromEnd {
+func (tr *tailReader) SeekToEnd(offset int64) {
+	if offset >= tr.offsetFromEnd {
 		tr.offsetFromEnd = 0
 		return
 	}
-	tr.offsetFromEnd -= int64(offset)
+	tr.offsetFromEnd -= offset
+}
+
+// ReadAt reads the file at the given offset from the start of the file. The content appended since the tailReader is
+// created is ignored. If the file is truncated or replaced, an error is returned.
+// It implements io.ReaderAt interface.
+func (tr *tailReader) ReadAt(buf []byte, offset int64) (int, error) {
+	if err := tr.checkFile(false); err != nil {
+		return 0, err
+	}
+	if offset >= tr.size {
+		return 0, io.EOF
+	}
+	if remaining := tr.size - offset; int64(len(buf)) > remaining {
+		n, err := tr.file.ReadAt(buf[:remaining], offset)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return tr.file.ReadAt(buf, offset)
+}
+
+// SeekTo positions the tailReader at the given offset from the start of the file so that the next Read returns the
+// content located before this offset.
+func (tr *tailReader) SeekTo(offset int64) {
+	switch {
+	case offset <= 0:
+		tr.offsetFromEnd = tr.size
+	case offset >= tr.size:
+		tr.offsetFromEnd = 0
+	default:
+		tr.offsetFromEnd = tr.size - offset
+	}
+}
+
+// Size returns the size of the file when the tailReader was opened
+func (tr *tailReader) Size() int64 {
+	return tr.size
 }
 
 // Close closes the reader and the file that it reads. Any subsequent call to Read will return an error.
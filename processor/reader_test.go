@@ -22,7 +22,8 @@ package processor_test
 import (
 	"errors"
 	"io"
-	"time"
+	"os"
+	"path/filepath"
 
 	"github.com/dvergnes/log-collector/processor"
 
@@ -58,6 +59,18 @@ var _ = Describe("Reader", func() {
 				Expect(err).Should(MatchError(ContainSubstring("failed to open file")))
 			})
 		})
+
+		When("file is a symbolic link", func() {
+			It("should return an error", func() {
+				folder := GinkgoT().TempDir()
+				fs = afero.NewOsFs()
+				Expect(afero.WriteFile(fs, filepath.Join(folder, "file.log"), []byte("abc"), 0644)).Should(Succeed())
+				Expect(os.Symlink(filepath.Join(folder, "file.log"), filepath.Join(folder, "link.log"))).Should(Succeed())
+
+				_, err := processor.NewTailReader(fs, filepath.Join(folder, "link.log"))
+				Expect(errors.Is(err, processor.ReplacedFileErr)).Should(BeTrue())
+			})
+		})
 	})
 
 	Describe("Read", func() {
@@ -123,15 +136,74 @@ abcdefghi
 			})
 		})
 
-		When("file is modified after TailReader is opened", func() {
+		When("content is appended after TailReader is opened", func() {
+			BeforeEach(func() {
+				f := setUp(`abc`)
+				_, err := f.WriteString("\ndef")
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should ignore the appended content", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf[:n]).Should(Equal([]byte("abc")))
+			})
+		})
+
+		When("file is truncated after TailReader is opened", func() {
+			BeforeEach(func() {
+				f := setUp(`abc`)
+				Expect(f.Truncate(1)).Should(Succeed())
+			})
+
+			It("should return an error", func() {
+				Expect(errors.Is(err, processor.ConcurrentAccessErr)).Should(BeTrue())
+			})
+		})
+
+		When("file is truncated then written beyond its size after TailReader is opened", func() {
 			BeforeEach(func() {
 				f := setUp(`abc`)
-				inTheFuture := time.Now().Add(time.Second)
-				Expect(fs.Chtimes(f.Name(), inTheFuture, inTheFuture)).Should(Succeed())
+				Expect(f.Truncate(0)).Should(Succeed())
+				_, err := f.WriteAt([]byte(`xyz123`), 0)
+				Expect(err).ShouldNot(HaveOccurred())
 			})
 
 			It("should return an error", func() {
-				Expect(errors.Is(err,processor.ConcurrentAccessErr)).Should(BeTrue())
+				Expect(errors.Is(err, processor.ConcurrentAccessErr)).Should(BeTrue())
+			})
+		})
+
+		When("file is replaced after TailReader is opened", func() {
+			BeforeEach(func() {
+				fs = afero.NewBasePathFs(afero.NewOsFs(), GinkgoT().TempDir())
+				Expect(fs.Mkdir("ut", 0755)).Should(Succeed())
+				f := setUp(`abc`)
+				Expect(fs.Remove(f.Name())).Should(Succeed())
+				Expect(afero.WriteFile(fs, f.Name(), []byte(`abcdef`), 0644)).Should(Succeed())
+			})
+
+			It("should return an error instead of EOF", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf[:n]).Should(Equal([]byte("abc")))
+
+				_, err = tailReader.Read(buf)
+				Expect(errors.Is(err, processor.ConcurrentAccessErr)).Should(BeTrue())
+			})
+		})
+
+		When("file is truncated then written with the same size after TailReader is opened", func() {
+			BeforeEach(func() {
+				f := setUp(`abc`)
+				Expect(f.Truncate(0)).Should(Succeed())
+				_, err := f.WriteAt([]byte(`xyz`), 0)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should return an error instead of EOF", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = tailReader.Read(buf)
+				Expect(errors.Is(err, processor.ConcurrentAccessErr)).Should(BeTrue())
 			})
 		})
 
@@ -140,7 +212,7 @@ abcdefghi
 	Describe("SeekToEnd", func() {
 		var (
 			buf = make([]byte, 13)
-			offset uint32
+			offset int64
 		)
 		BeforeEach(func() {
 			setUp(`
@@ -184,4 +256,60 @@ abcdefghi
 			})
 		})
 	})
+
+	Describe("ReadAt", func() {
+		BeforeEach(func() {
+			f := setUp("123456789\nabcdefghi\n")
+			_, err := f.WriteString("appended\n")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			Expect(tailReader.Close()).Should(Succeed())
+		})
+
+		It("should read the file at the given offset", func() {
+			buf := make([]byte, 4)
+			n, err := tailReader.ReadAt(buf, 10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(buf[:n]).Should(Equal([]byte("abcd")))
+		})
+
+		It("should ignore the appended content", func() {
+			buf := make([]byte, 10)
+			n, err := tailReader.ReadAt(buf, 15)
+			Expect(err).Should(Equal(io.EOF))
+			Expect(buf[:n]).Should(Equal([]byte("fghi\n")))
+
+			_, err = tailReader.ReadAt(buf, 20)
+			Expect(err).Should(Equal(io.EOF))
+		})
+	})
+
+	Describe("SeekTo", func() {
+		buf := make([]byte, 10)
+		BeforeEach(func() {
+			setUp("123456789\nabcdefghi\n")
+		})
+		AfterEach(func() {
+			Expect(tailReader.Close()).Should(Succeed())
+		})
+
+		DescribeTable("should read the content located before the offset", func(offset int64, expected string) {
+			tailReader.SeekTo(offset)
+			n, err := tailReader.Read(buf)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(string(buf[:n])).Should(Equal(expected))
+		},
+			Entry("offset is in the file", int64(14), "56789\nabcd"),
+			Entry("offset is beyond the end of file", int64(50), "abcdefghi\n"),
+		)
+
+		When("offset is the start of file", func() {
+			It("should reach EOF", func() {
+				tailReader.SeekTo(0)
+				_, err := tailReader.Read(buf)
+				Expect(err).Should(Equal(io.EOF))
+			})
+		})
+	})
 })
@@ -0,0 +1,343 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// fingerprintSize is the number of bytes, at the start of a file, that identify the file
+const fingerprintSize = 1024
+
+// StalePositionErr indicates that a file has been rotated or truncated since a Position was taken in this file
+var StalePositionErr = errors.New("file has been rotated or truncated since the position was taken")
+
+// Position locates the content that remains to be read by a RotatedTailReader
+type Position struct {
+	// Name is the name of the file being read
+	Name string
+	// Size is the size of the file when the position was taken
+	Size int64
+	// OffsetFromEnd is the number of bytes already read from the end of the file
+	OffsetFromEnd int64
+	// Fingerprint identifies the file by its first bytes, so that a file replaced by a rotation can be detected
+	Fingerprint string
+}
+
+// rotatedFilePattern matches the name of a file rotated by logrotate e.g. app.log.1 or app.log.2.gz
+var rotatedFilePattern = regexp.MustCompile(`^(.+)\.(\d+)(\.gz|\.zst|\.bz2)?$`)
+
+// RotatedTailReader reads a file then its rotated files from the most recent to the oldest one as if they were a single
+// file, so that an event can span over two files. It implements TailReader interface.
+type RotatedTailReader struct {
+	fs    afero.Fs
+	cache *DecompressionCache
+	names []string
+
+	readers []TailReader
+	// consumed contains the number of bytes read from each reader
+	consumed []int64
+	idx      int
+}
+
+// NewRotatedTailReader creates a RotatedTailReader for the given file. The rotated files are the files located in the
+// same folder whose name is the name of the file followed by a rotation number and optionally by a compression extension
+// e.g. app.log.1 or app.log.2.gz. The compressed files are decompressed transparently. If the given file is a rotated
+// file itself, only the older files are read. A rotated file is opened once all the more recent files have been read.
+// The compressed files are decompressed through the given cache if it is not nil.
+func NewRotatedTailReader(fs afero.Fs, name string, cache *DecompressionCache) (*RotatedTailReader, error) {
+	reader, err := OpenTailReader(fs, name, cache)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := rotatedFiles(fs, name)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &RotatedTailReader{
+		fs:       fs,
+		cache:    cache,
+		names:    append([]string{name}, rotated...),
+		readers:  []TailReader{reader},
+		consumed: []int64{0},
+	}, nil
+}
+
+// rotatedFiles lists the files that are older than the given file, sorted from the most recent to the oldest one
+func rotatedFiles(fs afero.Fs, name string) ([]string, error) {
+	dir, base := filepath.Split(name)
+	stem, first := base, 0
+	if matches := rotatedFilePattern.FindStringSubmatch(base); matches != nil {
+		stem = matches[1]
+		first, _ = strconv.Atoi(matches[2])
+	}
+
+	infos, err := afero.ReadDir(fs, filepath.Clean(dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rotated files %w", err)
+	}
+	type rotatedFile struct {
+		name string
+		nb   int
+	}
+	var files []rotatedFile
+	for _, info := range infos {
+		// the symbolic links are ignored since they may lead outside the folder
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		matches := rotatedFilePattern.FindStringSubmatch(info.Name())
+		if matches == nil || matches[1] != stem {
+			continue
+		}
+		nb, err := strconv.Atoi(matches[2])
+		if err != nil || nb <= first {
+			continue
+		}
+		files = append(files, rotatedFile{name: filepath.Join(dir, info.Name()), nb: nb})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].nb < files[j].nb
+	})
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	return names, nil
+}
+
+// Read reads the files from the end to the start, starting from the most recent file. The buffer may contain the
+// content of several files, in which case the content of the older file is placed before the content of the more recent
+// one. Once all the files are read io.EOF is returned.
+// It implements io.Reader interface.
+func (r *RotatedTailReader) Read(buf []byte) (int, error) {
+	// the content is read from the end of the buffer toward its start and is moved to the start of the buffer at the end
+	n := 0
+	for n < len(buf) {
+		reader, err := r.current()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		free := len(buf) - n
+		m, err := reader.Read(buf[:free])
+		if err == io.EOF {
+			r.idx++
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		copy(buf[free-m:free], buf[:m])
+		r.consumed[r.idx] += int64(m)
+		n += m
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	copy(buf, buf[len(buf)-n:])
+	return n, nil
+}
+
+// current returns the reader of the file being read. It opens the next rotated file if needed. It returns io.EOF if
+// all the files have been read.
+func (r *RotatedTailReader) current() (TailReader, error) {
+	if r.idx < len(r.readers) {
+		return r.readers[r.idx], nil
+	}
+	if r.idx >= len(r.names) {
+		return nil, io.EOF
+	}
+	reader, err := OpenTailReader(r.fs, r.names[r.idx], r.cache)
+	if err != nil {
+		return nil, err
+	}
+	r.readers = append(r.readers, reader)
+	r.consumed = append(r.consumed, 0)
+	return reader, nil
+}
+
+// SeekToEnd updates the offset of the RotatedTailReader towards the end of the most recent file. It basically rewinds
+// the reader by the given offset, which may move the reader back to a more recent file.
+func (r *RotatedTailReader) SeekToEnd(offset int64) {
+	remaining := offset
+	i := r.idx
+	if i >= len(r.readers) {
+		i = len(r.readers) - 1
+	}
+	for ; i >= 0; i-- {
+		r.idx = i
+		if remaining <= r.consumed[i] {
+			r.readers[i].SeekToEnd(remaining)
+			r.consumed[i] -= remaining
+			return
+		}
+		r.readers[i].SeekToEnd(r.consumed[i])
+		remaining -= r.consumed[i]
+		r.consumed[i] = 0
+	}
+}
+
+// ReadAt reads the most recent file at the given offset from the start of the file.
+// It implements io.ReaderAt interface.
+func (r *RotatedTailReader) ReadAt(buf []byte, offset int64) (int, error) {
+	return r.readers[0].ReadAt(buf, offset)
+}
+
+// SeekTo positions the RotatedTailReader at the given offset from the start of the most recent file so that the next
+// Read returns the content of this file located before this offset, then the content of the rotated files.
+func (r *RotatedTailReader) SeekTo(offset int64) {
+	for i := 1; i < len(r.readers); i++ {
+		r.readers[i].SeekTo(r.readers[i].Size())
+		r.consumed[i] = 0
+	}
+	r.readers[0].SeekTo(offset)
+	size := r.readers[0].Size()
+	switch {
+	case offset <= 0:
+		r.consumed[0] = size
+	case offset >= size:
+		r.consumed[0] = 0
+	default:
+		r.consumed[0] = size - offset
+	}
+	r.idx = 0
+}
+
+// SeekToTime positions the RotatedTailReader so that the next Read returns the content located before the first line
+// whose timestamp is after until. The files whose events are all after until are skipped, from the most recent to the
+// oldest one, then the first file that has an event at or before until is bisected, see FindTimestampOffset.
+func (r *RotatedTailReader) SeekToTime(parser TimestampParser, until time.Time, bufferSize int) error {
+	r.SeekTo(r.Size())
+	for {
+		reader, err := r.current()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset, err := FindTimestampOffset(reader, reader.Size(), parser, until, bufferSize)
+		if err != nil {
+			return err
+		}
+		reader.SeekTo(offset)
+		r.consumed[r.idx] = reader.Size() - offset
+		if offset > 0 {
+			return nil
+		}
+		r.idx++
+	}
+}
+
+// Size returns the size of the most recent file when the RotatedTailReader was opened
+func (r *RotatedTailReader) Size() int64 {
+	return r.readers[0].Size()
+}
+
+// Position returns the position of the RotatedTailReader i.e. the file being read and the number of bytes already read
+// from the end of this file. It returns false if all the files have been read.
+func (r *RotatedTailReader) Position() (Position, bool, error) {
+	for {
+		reader, err := r.current()
+		if err == io.EOF {
+			return Position{}, false, nil
+		}
+		if err != nil {
+			return Position{}, false, err
+		}
+		if r.consumed[r.idx] < reader.Size() {
+			fingerprint, err := fingerprint(reader, reader.Size())
+			if err != nil {
+				return Position{}, false, err
+			}
+			return Position{
+				Name:          r.names[r.idx],
+				Size:          reader.Size(),
+				OffsetFromEnd: r.consumed[r.idx],
+				Fingerprint:   fingerprint,
+			}, true, nil
+		}
+		r.idx++
+	}
+}
+
+// Resume positions the RotatedTailReader, which must have been created for the file of the given Position, at this
+// Position. The content appended since the Position was taken is ignored. It returns StalePositionErr if the file has
+// been truncated or replaced since the Position was taken.
+func (r *RotatedTailReader) Resume(position Position) error {
+	reader := r.readers[0]
+	if reader.Size() < position.Size {
+		return fmt.Errorf("%w", StalePositionErr)
+	}
+	fingerprint, err := fingerprint(reader, position.Size)
+	if err != nil {
+		return err
+	}
+	if fingerprint != position.Fingerprint {
+		return fmt.Errorf("%w", StalePositionErr)
+	}
+	r.SeekTo(position.Size - position.OffsetFromEnd)
+	return nil
+}
+
+// fingerprint hashes the first bytes of the file read by the TailReader. Only the content located before size is
+// considered so that the fingerprint does not change when content is appended.
+func fingerprint(reader TailReader, size int64) (string, error) {
+	if size > fingerprintSize {
+		size = fingerprintSize
+	}
+	buf := make([]byte, size)
+	if _, err := reader.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read file fingerprint %w", err)
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Files returns the files opened by the RotatedTailReader from the most recent to the oldest one
+func (r *RotatedTailReader) Files() []string {
+	return r.names[:len(r.readers)]
+}
+
+// Close closes the readers of all the opened files. Any subsequent call to Read will return an error.
+func (r *RotatedTailReader) Close() error {
+	var err error
+	for _, reader := range r.readers {
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
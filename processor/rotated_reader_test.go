@@ -0,0 +1,285 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("RotatedTailReader", func() {
+
+	var (
+		fs     afero.Fs
+		reader *processor.RotatedTailReader
+
+		writeGzip = func(name string, content string) {
+			b := bytes.Buffer{}
+			w := gzip.NewWriter(&b)
+			_, err := w.Write([]byte(content))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.Close()).Should(Succeed())
+			Expect(afero.WriteFile(fs, name, b.Bytes(), 0644)).Should(Succeed())
+		}
+
+		readAll = func(bufferSize int) []string {
+			eb := processor.NewEventBreaker(reader, processor.ReverseScanLines, bufferSize)
+			var events []string
+			for {
+				e, err := eb.Next()
+				if err == io.EOF {
+					return events
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, e)
+			}
+		}
+	)
+
+	BeforeEach(func() {
+		// the readers that are opened by a test are closed after it
+		reader = nil
+		fs = afero.NewMemMapFs()
+		Expect(fs.MkdirAll("/var/log", 0755)).Should(Succeed())
+		Expect(afero.WriteFile(fs, "/var/log/app.log", []byte("event_5\nevent_6\n"), 0644)).Should(Succeed())
+		Expect(afero.WriteFile(fs, "/var/log/app.log.1", []byte("event_3\nevent_4\n"), 0644)).Should(Succeed())
+		writeGzip("/var/log/app.log.2.gz", "event_1\nevent_2\n")
+		Expect(afero.WriteFile(fs, "/var/log/app.log.bak", []byte("ignored\n"), 0644)).Should(Succeed())
+		Expect(afero.WriteFile(fs, "/var/log/other.log.1", []byte("ignored\n"), 0644)).Should(Succeed())
+	})
+
+	AfterEach(func() {
+		if reader != nil {
+			Expect(reader.Close()).Should(Succeed())
+		}
+	})
+
+	Describe("New", func() {
+		When("file does not exist", func() {
+			It("should return an error", func() {
+				_, err := processor.NewRotatedTailReader(fs, "/var/log/I_dont_exist", nil)
+				Expect(err).Should(MatchError(ContainSubstring("failed to open file")))
+			})
+		})
+	})
+
+	Describe("Read", func() {
+		When("reading a file with rotated files", func() {
+			BeforeEach(func() {
+				var err error
+				reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should read the files from the most recent to the oldest", func() {
+				Expect(readAll(10)).Should(Equal([]string{"event_6", "event_5", "event_4", "event_3", "event_2", "event_1"}))
+				Expect(reader.Files()).Should(Equal([]string{"/var/log/app.log", "/var/log/app.log.1", "/var/log/app.log.2.gz"}))
+			})
+
+			It("should only open the rotated files when needed", func() {
+				buf := make([]byte, 8)
+				n, err := reader.Read(buf)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf[:n]).Should(Equal([]byte("event_6\n")))
+				Expect(reader.Files()).Should(Equal([]string{"/var/log/app.log"}))
+				Expect(reader.Size()).Should(BeEquivalentTo(16))
+			})
+
+			It("should fill the buffer with the content of several files", func() {
+				buf := make([]byte, 20)
+				n, err := reader.Read(buf)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(buf[:n]).Should(Equal([]byte("t_4\nevent_5\nevent_6\n")))
+			})
+		})
+
+		When("an event spans over two files", func() {
+			BeforeEach(func() {
+				Expect(afero.WriteFile(fs, "/var/log/app.log", []byte("_end\nevent_6\n"), 0644)).Should(Succeed())
+				Expect(afero.WriteFile(fs, "/var/log/app.log.1", []byte("event_3\nevent_4_start"), 0644)).Should(Succeed())
+				var err error
+				reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should return the event as a whole", func() {
+				Expect(readAll(20)).Should(Equal([]string{"event_6", "event_4_start_end", "event_3", "event_2", "event_1"}))
+			})
+		})
+
+		When("reading a rotated file", func() {
+			BeforeEach(func() {
+				var err error
+				reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log.1", nil)
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should only read the older files", func() {
+				Expect(readAll(10)).Should(Equal([]string{"event_4", "event_3", "event_2", "event_1"}))
+				Expect(reader.Files()).Should(Equal([]string{"/var/log/app.log.1", "/var/log/app.log.2.gz"}))
+			})
+		})
+	})
+
+	Describe("SeekTo", func() {
+		BeforeEach(func() {
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should read the most recent file from the offset then the rotated files", func() {
+			Expect(readAll(16)).Should(HaveLen(6))
+			reader.SeekTo(8)
+			Expect(readAll(16)).Should(Equal([]string{"event_5", "event_4", "event_3", "event_2", "event_1"}))
+		})
+	})
+
+	Describe("SeekToTime", func() {
+		until := time.Date(2020, time.October, 5, 10, 0, 2, 0, time.UTC)
+
+		BeforeEach(func() {
+			Expect(afero.WriteFile(fs, "/var/log/app.log", []byte("2020-10-05 10:00:05 e\n2020-10-05 10:00:06 f\n"), 0644)).Should(Succeed())
+			Expect(afero.WriteFile(fs, "/var/log/app.log.1", []byte("2020-10-05 10:00:03 c\n2020-10-05 10:00:04 d\n"), 0644)).Should(Succeed())
+			writeGzip("/var/log/app.log.2.gz", "2020-10-05 10:00:01 a\n2020-10-05 10:00:02 b\n2020-10-05 10:00:03 b\n")
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should skip the files whose events are after until", func() {
+			Expect(reader.SeekToTime(processor.DefaultTimestampFormats, until, 64)).Should(Succeed())
+			Expect(readAll(32)).Should(Equal([]string{"2020-10-05 10:00:02 b", "2020-10-05 10:00:01 a"}))
+		})
+
+		It("should return nothing when all the events are after until", func() {
+			Expect(reader.SeekToTime(processor.DefaultTimestampFormats, until.Add(-time.Hour), 64)).Should(Succeed())
+			Expect(readAll(32)).Should(BeEmpty())
+		})
+	})
+
+	Describe("Position", func() {
+		BeforeEach(func() {
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should locate the content that remains to be read", func() {
+			buf := make([]byte, 20)
+			_, err := reader.Read(buf)
+			Expect(err).ShouldNot(HaveOccurred())
+			position, ok, err := reader.Position()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).Should(BeTrue())
+			Expect(position.Name).Should(Equal("/var/log/app.log.1"))
+			Expect(position.Size).Should(BeEquivalentTo(16))
+			Expect(position.OffsetFromEnd).Should(BeEquivalentTo(4))
+			Expect(position.Fingerprint).ShouldNot(BeEmpty())
+		})
+
+		It("should return false once all the files have been read", func() {
+			Expect(readAll(64)).Should(HaveLen(6))
+			_, ok, err := reader.Position()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).Should(BeFalse())
+		})
+	})
+
+	Describe("Resume", func() {
+		var position processor.Position
+
+		BeforeEach(func() {
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log", nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			reader.SeekTo(8)
+			position, _, err = reader.Position()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reader.Close()).Should(Succeed())
+			reader = nil
+		})
+
+		It("should read the content located before the position", func() {
+			f, err := fs.OpenFile("/var/log/app.log", os.O_APPEND|os.O_WRONLY, 0644)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = f.WriteString("event_7\n")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(f.Close()).Should(Succeed())
+
+			reader, err = processor.NewRotatedTailReader(fs, position.Name, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reader.Resume(position)).Should(Succeed())
+			Expect(readAll(16)).Should(Equal([]string{"event_5", "event_4", "event_3", "event_2", "event_1"}))
+		})
+
+		It("should return an error when the file has been replaced", func() {
+			Expect(afero.WriteFile(fs, "/var/log/app.log", []byte("event_8\nevent_9\n"), 0644)).Should(Succeed())
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, position.Name, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reader.Resume(position)).Should(MatchError(processor.StalePositionErr))
+		})
+
+		It("should return an error when the file has been truncated", func() {
+			Expect(afero.WriteFile(fs, "/var/log/app.log", []byte("event_5\n"), 0644)).Should(Succeed())
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, position.Name, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(reader.Resume(position)).Should(MatchError(processor.StalePositionErr))
+		})
+	})
+
+	Describe("Close", func() {
+		var (
+			tempFs afero.Fs
+			cache  *processor.DecompressionCache
+		)
+
+		BeforeEach(func() {
+			tempFs = afero.NewMemMapFs()
+			Expect(tempFs.Mkdir("/cache", 0755)).Should(Succeed())
+			cache = processor.NewDecompressionCache(tempFs, "/cache", 1024, 1024)
+			var err error
+			reader, err = processor.NewRotatedTailReader(fs, "/var/log/app.log.2.gz", cache)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("should release the temporary files of the compressed files", func() {
+			Expect(readAll(10)).Should(Equal([]string{"event_2", "event_1"}))
+			Expect(reader.Close()).Should(Succeed())
+			reader = nil
+			// the files that are still read are only removed once they are released
+			Expect(cache.Close()).Should(Succeed())
+			tmp, err := afero.Glob(tempFs, "/cache/log-collector-*")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tmp).Should(BeEmpty())
+		})
+	})
+})
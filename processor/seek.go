@@ -0,0 +1,86 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FindTimestampOffset searches, in the first size bytes of the reader, the offset of the first line whose timestamp is
+// after until i.e. the offset from which a TailReader must read the file backward to return the events at or before
+// until first. Since the events are ordered by time, the search is a bisection which reads O(log n) times the reader.
+// At each step, the search re-synchronizes on the start of the next line that has a timestamp. The lines without
+// timestamp are considered as part of the previous event. It returns size if no event is after until.
+func FindTimestampOffset(reader io.ReaderAt, size int64, parser TimestampParser, until time.Time, bufferSize int) (int64, error) {
+	found := size
+	low, high := int64(0), size
+	for low < high {
+		mid := low + (high-low)/2
+		offset, t, ok, err := nextTimestamp(reader, size, mid, high, parser, bufferSize)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case !ok:
+			// no event starts between mid and high
+			high = mid
+		case t.After(until):
+			found = offset
+			high = mid
+		default:
+			low = offset + 1
+		}
+	}
+	return found, nil
+}
+
+// nextTimestamp returns the offset and the timestamp of the first line starting in [from, to) whose timestamp can be
+// parsed. The line itself may end after to. A line longer than bufferSize is cut, the remaining content is not
+// considered as the start of a line.
+func nextTimestamp(reader io.ReaderAt, size int64, from int64, to int64, parser TimestampParser, bufferSize int) (int64, time.Time, bool, error) {
+	pos := from
+	atLineStart := true
+	if from > 0 {
+		// the previous byte tells whether from is the start of a line
+		pos = from - 1
+		atLineStart = false
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(reader, pos, size-pos), bufferSize)
+	for pos < to {
+		line, err := r.ReadSlice('\n')
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return 0, time.Time{}, false, fmt.Errorf("failed to read file %w", err)
+		}
+		if atLineStart && len(line) > 0 {
+			if t, ok := parser.Parse(string(line)); ok {
+				return pos, t, true, nil
+			}
+		}
+		if err == io.EOF {
+			return 0, time.Time{}, false, nil
+		}
+		pos += int64(len(line))
+		atLineStart = err == nil
+	}
+	return 0, time.Time{}, false, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingReaderAt counts the number of reads
+type countingReaderAt struct {
+	*bytes.Reader
+	reads int
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return r.Reader.ReadAt(p, off)
+}
+
+var _ = Describe("FindTimestampOffset", func() {
+	var (
+		start   = time.Date(2020, time.October, 5, 0, 0, 0, 0, time.UTC)
+		content string
+	)
+
+	find := func(until time.Time, bufferSize int) int64 {
+		offset, err := processor.FindTimestampOffset(strings.NewReader(content), int64(len(content)),
+			processor.DefaultTimestampFormats, until, bufferSize)
+		Expect(err).ShouldNot(HaveOccurred())
+		return offset
+	}
+
+	When("lines have a timestamp", func() {
+		BeforeEach(func() {
+			content = "2020-10-05 00:00:00 a\n2020-10-05 00:00:01 b\n2020-10-05 00:00:01 c\n2020-10-05 00:00:02 d\n"
+		})
+
+		DescribeTable("should return the offset of the first line after until", func(until time.Time, expected string) {
+			Expect(content[find(until, 64):]).Should(Equal(expected))
+		},
+			Entry("until is before the first line", start.Add(-time.Second),
+				"2020-10-05 00:00:00 a\n2020-10-05 00:00:01 b\n2020-10-05 00:00:01 c\n2020-10-05 00:00:02 d\n"),
+			Entry("until matches a line", start, "2020-10-05 00:00:01 b\n2020-10-05 00:00:01 c\n2020-10-05 00:00:02 d\n"),
+			Entry("until matches several lines", start.Add(time.Second), "2020-10-05 00:00:02 d\n"),
+			Entry("until is between two lines", start.Add(1500*time.Millisecond), "2020-10-05 00:00:02 d\n"),
+			Entry("until is after the last line", start.Add(time.Hour), ""),
+		)
+	})
+
+	When("some lines have no timestamp", func() {
+		BeforeEach(func() {
+			content = "2020-10-05 00:00:00 panic\nat main.go:1\nat main.go:2\n2020-10-05 00:00:02 ok\nat main.go:3\n"
+		})
+
+		It("should keep them with the previous line", func() {
+			Expect(content[find(start.Add(time.Second), 64):]).Should(Equal("2020-10-05 00:00:02 ok\nat main.go:3\n"))
+		})
+	})
+
+	When("a line is longer than the buffer", func() {
+		BeforeEach(func() {
+			content = "2020-10-05 00:00:00 " + strings.Repeat("2020-10-05 00:00:09 ", 10) + "\n2020-10-05 00:00:02 ok\n"
+		})
+
+		It("should not consider the content after the buffer as a line", func() {
+			Expect(content[find(start.Add(time.Second), 32):]).Should(Equal("2020-10-05 00:00:02 ok\n"))
+		})
+	})
+
+	When("file is large", func() {
+		const lines = 100_000
+		var reader *countingReaderAt
+
+		BeforeEach(func() {
+			b := strings.Builder{}
+			for i := 0; i < lines; i++ {
+				b.WriteString(fmt.Sprintf("%s event_%d\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i))
+			}
+			content = b.String()
+			reader = &countingReaderAt{Reader: bytes.NewReader([]byte(content))}
+		})
+
+		It("should read the file a logarithmic number of times", func() {
+			offset, err := processor.FindTimestampOffset(reader, int64(len(content)), processor.DefaultTimestampFormats,
+				start.Add(lines/3*time.Second), 4096)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(content[offset : offset+64]).Should(HavePrefix(fmt.Sprintf("%s event_%d\n",
+				start.Add((lines/3+1)*time.Second).Format(time.RFC3339), lines/3+1)))
+			Expect(reader.reads).Should(BeNumerically("<", 50))
+		})
+	})
+})
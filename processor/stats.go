@@ -0,0 +1,133 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// ScanBudgetEventProcessor returns the events of an EventProcessor until a maximum number of events is read
+type ScanBudgetEventProcessor struct {
+	delegate EventProcessor
+
+	budget    uint
+	scanned   uint
+	exhausted bool
+}
+
+// WithScanBudget decorates an EventProcessor to read at most budget events. Unlike WithLimit, it applies to the events
+// read before they are filtered so that it bounds the work done to process a file.
+func WithScanBudget(processor EventProcessor, budget uint) *ScanBudgetEventProcessor {
+	return &ScanBudgetEventProcessor{
+		delegate: processor,
+		budget:   budget,
+	}
+}
+
+// Next implements EventProcessor contract
+func (s *ScanBudgetEventProcessor) Next() (string, error) {
+	if s.scanned >= s.budget {
+		// the event that follows the budget is read to know whether the budget prevented reading all the events
+		if !s.exhausted {
+			if _, err := s.delegate.Next(); err == nil {
+				s.exhausted = true
+			}
+		}
+		return "", io.EOF
+	}
+	next, err := s.delegate.Next()
+	if err != nil {
+		return next, err
+	}
+	s.scanned++
+	return next, nil
+}
+
+// Scanned returns the number of events read
+func (s *ScanBudgetEventProcessor) Scanned() uint {
+	return s.scanned
+}
+
+// Exhausted returns true if the budget was reached before all the events were read
+func (s *ScanBudgetEventProcessor) Exhausted() bool {
+	return s.exhausted
+}
+
+// Bucket counts the events whose timestamp is in the period of time that starts at Start
+type Bucket struct {
+	Start time.Time
+	Count int
+}
+
+// Histogram counts the events by period of time according to their timestamp
+type Histogram struct {
+	parser   TimestampParser
+	interval time.Duration
+	counts   map[time.Time]int
+
+	total         int
+	untimestamped int
+}
+
+// NewHistogram creates a Histogram whose buckets last interval. The buckets are aligned on multiples of interval since
+// the zero time in UTC, e.g. on the minutes for a 1 minute interval.
+func NewHistogram(parser TimestampParser, interval time.Duration) *Histogram {
+	return &Histogram{
+		parser:   parser,
+		interval: interval,
+		counts:   make(map[time.Time]int),
+	}
+}
+
+// Add counts an event in the bucket of its timestamp. An event whose timestamp cannot be parsed is only counted in the
+// total.
+func (h *Histogram) Add(event string) {
+	h.total++
+	t, ok := h.parser.Parse(event)
+	if !ok {
+		h.untimestamped++
+		return
+	}
+	h.counts[t.UTC().Truncate(h.interval)]++
+}
+
+// Total returns the number of events added
+func (h *Histogram) Total() int {
+	return h.total
+}
+
+// Untimestamped returns the number of events added whose timestamp cannot be parsed
+func (h *Histogram) Untimestamped() int {
+	return h.untimestamped
+}
+
+// Buckets returns the buckets that contain at least one event in chronological order
+func (h *Histogram) Buckets() []Bucket {
+	buckets := make([]Bucket, 0, len(h.counts))
+	for start, count := range h.counts {
+		buckets = append(buckets, Bucket{Start: start, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/dvergnes/log-collector/mocks"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stats", func() {
+
+	Describe("WithScanBudget", func() {
+		var (
+			delegate *mocks.EventProcessor
+			sb       *processor.ScanBudgetEventProcessor
+		)
+
+		BeforeEach(func() {
+			delegate = &mocks.EventProcessor{}
+			sb = processor.WithScanBudget(delegate, 2)
+		})
+
+		AfterEach(func() {
+			delegate.AssertExpectations(GinkgoT())
+		})
+
+		When("budget is reached", func() {
+			BeforeEach(func() {
+				delegate.On("Next").Return("event", nil).Times(3)
+			})
+
+			It("should stop reading and report that the budget is exhausted", func() {
+				for i := 0; i < 2; i++ {
+					s, err := sb.Next()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(s).Should(Equal("event"))
+				}
+				_, err := sb.Next()
+				Expect(err).Should(Equal(io.EOF))
+				_, err = sb.Next()
+				Expect(err).Should(Equal(io.EOF))
+				Expect(sb.Scanned()).Should(Equal(uint(2)))
+				Expect(sb.Exhausted()).Should(BeTrue())
+			})
+		})
+
+		When("all events are read within the budget", func() {
+			BeforeEach(func() {
+				delegate.On("Next").Return("event", nil).Times(2)
+				delegate.On("Next").Return("", io.EOF).Once()
+			})
+
+			It("should not report that the budget is exhausted", func() {
+				for i := 0; i < 2; i++ {
+					_, err := sb.Next()
+					Expect(err).ShouldNot(HaveOccurred())
+				}
+				_, err := sb.Next()
+				Expect(err).Should(Equal(io.EOF))
+				Expect(sb.Scanned()).Should(Equal(uint(2)))
+				Expect(sb.Exhausted()).Should(BeFalse())
+			})
+		})
+
+		When("decorated processor returns an error", func() {
+			criticalError := errors.New("oops")
+			BeforeEach(func() {
+				delegate.On("Next").Return("", criticalError).Once()
+			})
+
+			It("should propagate the error", func() {
+				_, err := sb.Next()
+				Expect(err).Should(Equal(criticalError))
+				Expect(sb.Scanned()).Should(BeZero())
+			})
+		})
+	})
+
+	Describe("Histogram", func() {
+		It("should count the events by period of time", func() {
+			h := processor.NewHistogram(processor.DefaultTimestampFormats, time.Minute)
+			for _, event := range []string{
+				"2020-10-05 10:01:59 ERROR c",
+				"2020-10-05T10:01:00+02:00 ERROR b",
+				"2020-10-05 10:00:30 ERROR a",
+				"panic: oops",
+				"2020-10-05 08:01:30 ERROR d",
+			} {
+				h.Add(event)
+			}
+			Expect(h.Total()).Should(Equal(5))
+			Expect(h.Untimestamped()).Should(Equal(1))
+			Expect(h.Buckets()).Should(Equal([]processor.Bucket{
+				{Start: time.Date(2020, 10, 5, 8, 1, 0, 0, time.UTC), Count: 2},
+				{Start: time.Date(2020, 10, 5, 10, 0, 0, 0, time.UTC), Count: 1},
+				{Start: time.Date(2020, 10, 5, 10, 1, 0, 0, time.UTC), Count: 1},
+			}))
+		})
+
+		It("should align the buckets on the interval", func() {
+			h := processor.NewHistogram(processor.DefaultTimestampFormats, time.Hour)
+			h.Add("2020-10-05 10:59:59 INFO")
+			h.Add("2020-10-05 10:00:00 INFO")
+			Expect(h.Buckets()).Should(Equal([]processor.Bucket{
+				{Start: time.Date(2020, 10, 5, 10, 0, 0, 0, time.UTC), Count: 2},
+			}))
+		})
+
+		When("no event is added", func() {
+			It("should return no bucket", func() {
+				h := processor.NewHistogram(processor.DefaultTimestampFormats, time.Minute)
+				Expect(h.Buckets()).Should(BeEmpty())
+				Expect(h.Total()).Should(BeZero())
+			})
+		})
+	})
+})
@@ -0,0 +1,264 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// facilities lists the names of the syslog facilities by code
+var facilities = []string{"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv",
+	"ftp", "ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5",
+	"local6", "local7"}
+
+// severities lists the names of the syslog severities by code
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// severityAliases maps the other names of the severities to their code
+var severityAliases = map[string]Severity{"emergency": 0, "panic": 0, "critical": 2, "error": 3, "warn": 4}
+
+// Severity is the severity of a syslog event, from 0 for emerg to 7 for debug. It is written as its name in JSON and it
+// is compared by code with a name or a code e.g. severity<=warning keeps the events whose severity is warning or worse.
+type Severity int
+
+// String implements Stringer contract
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severities) {
+		return strconv.Itoa(int(s))
+	}
+	return severities[s]
+}
+
+// MarshalJSON implements json.Marshaler contract
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Compare implements Comparable contract
+func (s Severity) Compare(value string) (int, bool) {
+	other, ok := parseSeverity(value)
+	if !ok {
+		return 0, false
+	}
+	return int(s) - int(other), true
+}
+
+func parseSeverity(value string) (Severity, bool) {
+	value = strings.ToLower(value)
+	for i, name := range severities {
+		if name == value {
+			return Severity(i), true
+		}
+	}
+	if s, ok := severityAliases[value]; ok {
+		return s, true
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 0 || code >= len(severities) {
+		return 0, false
+	}
+	return Severity(code), true
+}
+
+// rfc5424Pattern matches the header of a RFC 5424 event: priority, version, timestamp, hostname, app-name, procid and
+// msgid
+var rfc5424Pattern = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) `)
+
+// rfc3164Pattern matches a RFC 3164 event whose priority is optional since the files written by syslog daemons usually
+// omit it. The timestamp is either the legacy one or a RFC 3339 timestamp.
+var rfc3164Pattern = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\S+) (\S+) (.*)$`)
+
+// rfc3164TagPattern matches the tag of a RFC 3164 message i.e. the app-name and the optional procid
+var rfc3164TagPattern = regexp.MustCompile(`^([^\s\[\]:]+)(?:\[([^\]]*)\])?: ?`)
+
+// RFC5424Parser parses the syslog events defined by RFC 5424. The fields are facility, severity, timestamp, hostname,
+// app, procid, msgid, structured_data and message. The fields whose value is - are omitted and the structured data is
+// an object that maps the SD-IDs to their parameters.
+var RFC5424Parser EventParser = EventParserFunc(parseRFC5424)
+
+func parseRFC5424(event string) (Fields, error) {
+	m := rfc5424Pattern.FindStringSubmatch(event)
+	if m == nil {
+		return nil, fmt.Errorf("failed to parse RFC 5424 header %w", ParseErr)
+	}
+	fields := Fields{}
+	if err := setPriority(fields, m[1]); err != nil {
+		return nil, err
+	}
+	if m[3] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, m[3])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RFC 5424 timestamp %w", ParseErr)
+		}
+		fields["timestamp"] = timestamp
+	}
+	for i, name := range []string{"hostname", "app", "procid", "msgid"} {
+		if m[4+i] != "-" {
+			fields[name] = m[4+i]
+		}
+	}
+
+	rest := event[len(m[0]):]
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		sd, n, err := parseStructuredData(rest)
+		if err != nil {
+			return nil, err
+		}
+		fields["structured_data"] = sd
+		rest = rest[n:]
+	}
+	if len(rest) > 0 {
+		if rest[0] != ' ' {
+			return nil, fmt.Errorf("failed to parse RFC 5424 message %w", ParseErr)
+		}
+		// the message may start with a byte order mark to indicate that it is encoded in UTF-8
+		fields["message"] = strings.TrimPrefix(rest[1:], "\ufeff")
+	}
+	return fields, nil
+}
+
+// parseStructuredData parses the structured data elements at the beginning of the given string e.g.
+// [exampleSDID@32473 iut="3" eventSource="Application"]. It returns the number of bytes parsed.
+func parseStructuredData(s string) (map[string]interface{}, int, error) {
+	invalidErr := fmt.Errorf("failed to parse RFC 5424 structured data %w", ParseErr)
+	sd := map[string]interface{}{}
+	i := 0
+	for i < len(s) && s[i] == '[' {
+		i++
+		end := strings.IndexAny(s[i:], " ]")
+		if end <= 0 {
+			return nil, 0, invalidErr
+		}
+		id := s[i : i+end]
+		i += end
+		params := map[string]interface{}{}
+		for i < len(s) && s[i] == ' ' {
+			i++
+			eq := strings.Index(s[i:], `="`)
+			if eq <= 0 {
+				return nil, 0, invalidErr
+			}
+			name := s[i : i+eq]
+			i += eq + 2
+			var value strings.Builder
+			for ; i < len(s) && s[i] != '"'; i++ {
+				// the characters ", \ and ] are escaped by a backslash
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, 0, invalidErr
+			}
+			i++
+			params[name] = value.String()
+		}
+		if i == len(s) || s[i] != ']' {
+			return nil, 0, invalidErr
+		}
+		i++
+		sd[id] = params
+	}
+	if i == 0 {
+		return nil, 0, invalidErr
+	}
+	return sd, i, nil
+}
+
+// NewRFC3164Parser creates a parser of the legacy BSD syslog events defined by RFC 3164. The fields are facility and
+// severity when the event has a priority, timestamp, hostname, app and procid when the message has a tag, and message.
+// Since the legacy timestamp omits the year and the time zone, the timestamp is considered in the given location and
+// in the year of now, or in the previous year if it would be more than a day after now e.g. an event of December read
+// in January.
+func NewRFC3164Parser(now time.Time, location *time.Location) EventParser {
+	return EventParserFunc(func(event string) (Fields, error) {
+		m := rfc3164Pattern.FindStringSubmatch(event)
+		if m == nil {
+			return nil, fmt.Errorf("failed to parse RFC 3164 event %w", ParseErr)
+		}
+		fields := Fields{}
+		if len(m[1]) > 0 {
+			if err := setPriority(fields, m[1]); err != nil {
+				return nil, err
+			}
+		}
+		timestamp, err := parseRFC3164Timestamp(m[2], now, location)
+		if err != nil {
+			return nil, err
+		}
+		fields["timestamp"] = timestamp
+		fields["hostname"] = m[3]
+		message := m[4]
+		if tag := rfc3164TagPattern.FindStringSubmatch(message); tag != nil {
+			fields["app"] = tag[1]
+			if len(tag[2]) > 0 {
+				fields["procid"] = tag[2]
+			}
+			message = message[len(tag[0]):]
+		}
+		fields["message"] = message
+		return fields, nil
+	})
+}
+
+func parseRFC3164Timestamp(value string, now time.Time, location *time.Location) (time.Time, error) {
+	if value[0] >= '0' && value[0] <= '9' {
+		timestamp, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse RFC 3164 timestamp %w", ParseErr)
+		}
+		return timestamp, nil
+	}
+	timestamp, err := time.ParseInLocation(time.Stamp, value, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse RFC 3164 timestamp %w", ParseErr)
+	}
+	return inferYear(timestamp, now, location), nil
+}
+
+// NewSyslogParser creates a parser of the syslog events that detects whether an event follows RFC 5424 or RFC 3164
+func NewSyslogParser(now time.Time, location *time.Location) EventParser {
+	rfc3164 := NewRFC3164Parser(now, location)
+	return EventParserFunc(func(event string) (Fields, error) {
+		if rfc5424Pattern.MatchString(event) {
+			return RFC5424Parser.Parse(event)
+		}
+		return rfc3164.Parse(event)
+	})
+}
+
+// setPriority sets the facility and the severity encoded in the given priority
+func setPriority(fields Fields, priority string) error {
+	code, err := strconv.Atoi(priority)
+	if err != nil || code > 191 {
+		return fmt.Errorf("failed to parse syslog priority %w", ParseErr)
+	}
+	fields["facility"] = facilities[code/8]
+	fields["severity"] = Severity(code % 8)
+	return nil
+}
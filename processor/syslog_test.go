@@ -0,0 +1,157 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Syslog", func() {
+	now := time.Date(2021, time.January, 2, 8, 0, 0, 0, time.UTC)
+
+	Describe("RFC5424Parser", func() {
+		It("should extract the fields of the event", func() {
+			fields, err := processor.RFC5424Parser.Parse(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"][meta seq="a\"b\]"] An application event`)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields).Should(Equal(processor.Fields{
+				"facility":  "local4",
+				"severity":  processor.Severity(5),
+				"timestamp": time.Date(2003, time.October, 11, 22, 14, 15, 3_000_000, time.UTC),
+				"hostname":  "mymachine.example.com",
+				"app":       "evntslog",
+				"procid":    "1234",
+				"msgid":     "ID47",
+				"structured_data": map[string]interface{}{
+					"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": "Application"},
+					"meta":              map[string]interface{}{"seq": `a"b]`},
+				},
+				"message": "An application event",
+			}))
+		})
+
+		It("should omit the nil values", func() {
+			fields, err := processor.RFC5424Parser.Parse("<34>1 - - su - - - \ufeff'su root' failed")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields).Should(Equal(processor.Fields{
+				"facility": "auth",
+				"severity": processor.Severity(2),
+				"app":      "su",
+				"message":  "'su root' failed",
+			}))
+		})
+
+		DescribeTable("should return a parse error", func(event string) {
+			_, err := processor.RFC5424Parser.Parse(event)
+			Expect(err).Should(MatchError(processor.ParseErr))
+		},
+			Entry("event has no version", "<34>2003-10-11T22:14:15.003Z host su - - - message"),
+			Entry("priority is too high", "<192>1 - host su - - -"),
+			Entry("timestamp is invalid", "<34>1 2003-10-11 host su - - -"),
+			Entry("structured data is not closed", `<34>1 - host su - - [meta seq="1" message`),
+			Entry("structured data is not followed by a space", `<34>1 - host su - - [meta]message`),
+		)
+	})
+
+	Describe("RFC3164Parser", func() {
+		parser := processor.NewRFC3164Parser(now, time.UTC)
+
+		It("should extract the fields of the event", func() {
+			fields, err := parser.Parse("<34>Jan  1 22:14:15 mymachine su[42]: 'su root' failed")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields).Should(Equal(processor.Fields{
+				"facility":  "auth",
+				"severity":  processor.Severity(2),
+				"timestamp": time.Date(2021, time.January, 1, 22, 14, 15, 0, time.UTC),
+				"hostname":  "mymachine",
+				"app":       "su",
+				"procid":    "42",
+				"message":   "'su root' failed",
+			}))
+		})
+
+		DescribeTable("should handle the variations of the format", func(event string, field string, expected interface{}) {
+			fields, err := parser.Parse(event)
+			Expect(err).ShouldNot(HaveOccurred())
+			if expected == nil {
+				Expect(fields).ShouldNot(HaveKey(field))
+				return
+			}
+			Expect(fields[field]).Should(Equal(expected))
+		},
+			Entry("event has no priority", "Jan  2 07:00:00 host sshd[1]: Accepted publickey", "severity", nil),
+			Entry("message has no tag", "Jan  2 07:00:00 host Accepted publickey", "message", "Accepted publickey"),
+			Entry("tag has no procid", "Jan  2 07:00:00 host kernel: oops", "app", "kernel"),
+			Entry("event is from the previous year", "Dec 31 23:00:00 host cron: run", "timestamp",
+				time.Date(2020, time.December, 31, 23, 0, 0, 0, time.UTC)),
+			Entry("event is slightly in the future", "Jan  2 20:00:00 host cron: run", "timestamp",
+				time.Date(2021, time.January, 2, 20, 0, 0, 0, time.UTC)),
+			Entry("event is on February 29", "Feb 29 10:00:00 host cron: run", "timestamp",
+				time.Date(2020, time.February, 29, 10, 0, 0, 0, time.UTC)),
+			Entry("timestamp is a RFC 3339 timestamp", "2020-10-05T10:32:51.5+02:00 host cron: run", "timestamp",
+				time.Date(2020, time.October, 5, 10, 32, 51, 500_000_000, time.FixedZone("", 2*3600))),
+		)
+
+		DescribeTable("should return a parse error", func(event string) {
+			_, err := parser.Parse(event)
+			Expect(err).Should(MatchError(processor.ParseErr))
+		},
+			Entry("event has no timestamp", "host sshd[1]: Accepted publickey"),
+			Entry("date is invalid", "Foo 31 23:00:00 host cron: run"),
+			Entry("priority is too high", "<999>Jan  2 07:00:00 host cron: run"),
+		)
+	})
+
+	Describe("SyslogParser", func() {
+		parser := processor.NewSyslogParser(now, time.UTC)
+
+		DescribeTable("should detect the format of the event", func(event string, app string) {
+			fields, err := parser.Parse(event)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(fields["app"]).Should(Equal(app))
+		},
+			Entry("event follows RFC 5424", "<34>1 - host su - - - failed", "su"),
+			Entry("event follows RFC 3164", "<34>Jan  2 07:00:00 host sshd: failed", "sshd"),
+		)
+	})
+
+	Describe("Severity", func() {
+		It("should be written as its name", func() {
+			Expect(json.Marshal(processor.Severity(4))).Should(MatchJSON(`"warning"`))
+		})
+
+		DescribeTable("should be compared by code", func(condition processor.Condition, expected bool) {
+			condition.Path = processor.FieldPath{"severity"}
+			Expect(condition.Match(processor.Fields{"severity": processor.Severity(3)})).Should(Equal(expected))
+		},
+			Entry("severity is worse than warning", processor.Condition{Operator: processor.LessOrEqual, Value: "warning"}, true),
+			Entry("severity is not worse than crit", processor.Condition{Operator: processor.LessOrEqual, Value: "CRIT"}, false),
+			Entry("severity is equal to an alias", processor.Condition{Operator: processor.Equal, Value: "error"}, true),
+			Entry("severity is equal to a code", processor.Condition{Operator: processor.Equal, Value: "3"}, true),
+			Entry("severity is compared with an unknown name", processor.Condition{Operator: processor.Less, Value: "fatal"}, false),
+			Entry("severity is different from an unknown name", processor.Condition{Operator: processor.NotEqual, Value: "fatal"}, true),
+		)
+	})
+})
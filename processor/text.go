@@ -0,0 +1,74 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+)
+
+// textSampleSize is the number of bytes inspected to decide whether a file is a text file
+const textSampleSize = 512
+
+// IsTextFile reports whether the given file, once decompressed, is a text file i.e. its first bytes are valid UTF-8 and
+// do not contain any NUL byte. A compressed file that cannot be decompressed is not a text file.
+func IsTextFile(fs afero.Fs, name string, compression Compression) (bool, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return false, fmt.Errorf("failed to open file %w", err)
+	}
+	defer file.Close()
+
+	r := io.Reader(file)
+	if compression != NoCompression {
+		decompressed, err := newDecompressor(file, compression)
+		if err != nil {
+			return false, nil
+		}
+		defer decompressed.Close()
+		r = decompressed
+	}
+
+	sample := make([]byte, textSampleSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		if compression != NoCompression {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read file %w", err)
+	}
+	sample = sample[:n]
+	if n == textSampleSize {
+		// the sample may end in the middle of a multi bytes character
+		for i := 1; i < utf8.UTFMax && i <= n; i++ {
+			if utf8.RuneStart(sample[n-i]) {
+				if !utf8.FullRune(sample[n-i:]) {
+					sample = sample[:n-i]
+				}
+				break
+			}
+		}
+	}
+	return bytes.IndexByte(sample, 0) < 0 && utf8.Valid(sample), nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("IsTextFile", func() {
+	var fs afero.Fs
+
+	BeforeEach(func() {
+		fs = afero.NewMemMapFs()
+	})
+
+	DescribeTable("should detect text files", func(content []byte, expected bool) {
+		Expect(afero.WriteFile(fs, "/var/log/file.log", content, 0644)).Should(Succeed())
+		text, err := processor.IsTextFile(fs, "/var/log/file.log", processor.NoCompression)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(text).Should(Equal(expected))
+	},
+		Entry("file is empty", []byte{}, true),
+		Entry("file contains text", []byte("event_1\nevent_2\n"), true),
+		Entry("file contains multi bytes characters cut by the sample", []byte(strings.Repeat("a", 511)+"é"), true),
+		Entry("file contains NUL bytes", []byte("event\x00"), false),
+		Entry("file contains invalid UTF-8", []byte{0xff, 0xfe, 'a'}, false),
+	)
+
+	When("file is compressed", func() {
+		It("should inspect the decompressed content", func() {
+			b := bytes.Buffer{}
+			w := gzip.NewWriter(&b)
+			_, err := w.Write([]byte("event_1\n"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(w.Close()).Should(Succeed())
+			Expect(afero.WriteFile(fs, "/var/log/file.log.gz", b.Bytes(), 0644)).Should(Succeed())
+
+			text, err := processor.IsTextFile(fs, "/var/log/file.log.gz", processor.Gzip)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(text).Should(BeTrue())
+		})
+
+		It("should not be a text file if it cannot be decompressed", func() {
+			Expect(afero.WriteFile(fs, "/var/log/file.log.gz", []byte{0x1f, 0x8b, 0x00}, 0644)).Should(Succeed())
+			text, err := processor.IsTextFile(fs, "/var/log/file.log.gz", processor.Gzip)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(text).Should(BeFalse())
+		})
+	})
+
+	When("file does not exist", func() {
+		It("should return an error", func() {
+			_, err := processor.IsTextFile(fs, "/var/log/missing.log", processor.NoCompression)
+			Expect(err).Should(MatchError(ContainSubstring("failed to open file")))
+		})
+	})
+})
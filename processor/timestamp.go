@@ -0,0 +1,212 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"time"
+)
+
+// maxPendingEvents is the maximum number of events without timestamp that wait for the timestamp of an older event
+const maxPendingEvents = 1000
+
+// PendingEventsErr indicates that too many consecutive events have no timestamp to inherit the timestamp of an older
+// event
+var PendingEventsErr = errors.New("more than 1000 consecutive events have no timestamp")
+
+// TimestampFormat defines how to extract the timestamp of an event
+type TimestampFormat struct {
+	// Pattern locates the timestamp in the event. The timestamp is the first capturing group if the pattern has one,
+	// the entire match otherwise.
+	Pattern *regexp.Regexp
+	// Layout defines the layout of the timestamp as defined by time.Parse. A timestamp whose layout has no year, e.g.
+	// time.Stamp, is given the year that makes it the most recent date that is not after tomorrow.
+	Layout string
+}
+
+// DefaultTimestampFormats contains the formats of the most common timestamps, starting with the one of the Apache
+// access logs e.g. [05/Oct/2020:10:32:51 -0800]
+var DefaultTimestampFormats = []TimestampFormat{
+	{
+		Pattern: regexp.MustCompile(`\[(\d{2}/[A-Za-z]{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`),
+		Layout:  "02/Jan/2006:15:04:05 -0700",
+	},
+	{
+		Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`),
+		Layout:  time.RFC3339Nano,
+	},
+	{
+		Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`),
+		Layout:  "2006-01-02 15:04:05",
+	},
+	{
+		Pattern: regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}`),
+		Layout:  time.Stamp,
+	},
+}
+
+// TimestampParser extracts the timestamp of an event by trying its formats in order
+type TimestampParser []TimestampFormat
+
+// Parse returns the timestamp of the event. It returns false if no format matches the event. A timestamp without time
+// zone is considered as UTC.
+func (p TimestampParser) Parse(event string) (time.Time, bool) {
+	for _, format := range p {
+		match := format.Pattern.FindStringSubmatch(event)
+		if match == nil {
+			continue
+		}
+		s := match[0]
+		if len(match) > 1 {
+			s = match[1]
+		}
+		if t, err := time.Parse(format.Layout, s); err == nil {
+			if t.Year() == 0 {
+				t = inferYear(t, time.Now(), time.UTC)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// inferYear gives a timestamp parsed without year, e.g. an RFC 3164 timestamp, the year that makes it the most recent
+// date in the given location that is not after tomorrow. A date that does not exist in a year, i.e. February 29, is
+// normalized by time.Date to another day, in which case the previous year is tried.
+func inferYear(timestamp time.Time, now time.Time, location *time.Location) time.Time {
+	now = now.In(location)
+	tomorrow := now.AddDate(0, 0, 1)
+	for year := now.Year(); ; year-- {
+		t := time.Date(year, timestamp.Month(), timestamp.Day(), timestamp.Hour(), timestamp.Minute(),
+			timestamp.Second(), timestamp.Nanosecond(), location)
+		if t.Day() == timestamp.Day() && !t.After(tomorrow) {
+			return t
+		}
+	}
+}
+
+// UnparseableTimestampPolicy defines how a time range applies to the events whose timestamp cannot be parsed
+type UnparseableTimestampPolicy string
+
+const (
+	// IncludeUnparseable returns the events without timestamp
+	IncludeUnparseable UnparseableTimestampPolicy = "include"
+	// ExcludeUnparseable drops the events without timestamp
+	ExcludeUnparseable UnparseableTimestampPolicy = "exclude"
+	// InheritTimestamp considers that an event without timestamp has the timestamp of the previous event in the file,
+	// e.g. the lines of a stack trace have the timestamp of the line that precedes them.
+	InheritTimestamp UnparseableTimestampPolicy = "inherit"
+)
+
+type timeRangeEventProcessor struct {
+	delegate EventProcessor
+
+	parser TimestampParser
+	since  time.Time
+	until  time.Time
+	policy UnparseableTimestampPolicy
+
+	// pending contains the events, from the most recent to the oldest one, that wait for the timestamp of an older event
+	pending []offsetEvent
+	// ready contains the events, from the most recent to the oldest one, that can be returned
+	ready  []offsetEvent
+	done   bool
+	offset int64
+}
+
+// offsetEvent is an event read ahead along with its offset, see OffsetEventProcessor
+type offsetEvent struct {
+	event  string
+	offset int64
+}
+
+// WithTimeRange decorates an EventProcessor to return only the events whose timestamp is between since and until
+// inclusive. A zero since or until leaves the range open. Since the events are read from the most recent to the oldest
+// one, the processor stops once it reads an event older than since. The policy defines how the events whose timestamp
+// cannot be parsed are processed. With InheritTimestamp, up to 1,000 consecutive events can wait for the timestamp of
+// an older event, PendingEventsErr is returned beyond this limit.
+func WithTimeRange(processor EventProcessor, parser TimestampParser, since time.Time, until time.Time,
+	policy UnparseableTimestampPolicy) OffsetEventProcessor {
+	return &timeRangeEventProcessor{
+		delegate: processor,
+		parser:   parser,
+		since:    since,
+		until:    until,
+		policy:   policy,
+	}
+}
+
+// Offset implements OffsetEventProcessor contract
+func (tr *timeRangeEventProcessor) Offset() int64 {
+	return tr.offset
+}
+
+// Next implements EventProcessor contract
+func (tr *timeRangeEventProcessor) Next() (string, error) {
+	for len(tr.ready) == 0 {
+		if tr.done {
+			return "", io.EOF
+		}
+		next, err := tr.delegate.Next()
+		if err == io.EOF {
+			// the pending events have no older event to inherit from
+			tr.done = true
+			continue
+		}
+		if err != nil {
+			return next, err
+		}
+
+		e := offsetEvent{event: next, offset: offsetOf(tr.delegate)}
+		t, ok := tr.parser.Parse(next)
+		if !ok {
+			switch tr.policy {
+			case IncludeUnparseable:
+				tr.offset = e.offset
+				return next, nil
+			case InheritTimestamp:
+				if len(tr.pending) == maxPendingEvents {
+					return "", PendingEventsErr
+				}
+				tr.pending = append(tr.pending, e)
+			}
+			continue
+		}
+
+		if !tr.since.IsZero() && t.Before(tr.since) {
+			tr.done = true
+			tr.pending = nil
+			continue
+		}
+		if !tr.until.IsZero() && t.After(tr.until) {
+			tr.pending = tr.pending[:0]
+			continue
+		}
+		tr.ready = append(tr.ready, tr.pending...)
+		tr.ready = append(tr.ready, e)
+		tr.pending = tr.pending[:0]
+	}
+	next := tr.ready[0]
+	tr.ready = tr.ready[1:]
+	tr.offset = next.offset
+	return next.event, nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2022 Denis Vergnes
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of
+// this software and associated documentation files (the "Software"), to deal in
+// the Software without restriction, including without limitation the rights to
+// use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of
+// the Software, and to permit persons to whom the Software is furnished to do so,
+// subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS
+// FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER
+// IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
+// CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package processor_test
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/dvergnes/log-collector/mocks"
+	"github.com/dvergnes/log-collector/processor"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+var _ = Describe("Timestamp", func() {
+
+	Describe("TimestampParser", func() {
+		DescribeTable("should parse the timestamp of the event", func(event string, expected time.Time) {
+			t, ok := processor.TimestampParser(processor.DefaultTimestampFormats).Parse(event)
+			Expect(ok).Should(BeTrue())
+			Expect(t).Should(BeTemporally("==", expected))
+		},
+			Entry("apache timestamp", `127.0.0.1 - - [05/Oct/2020:10:32:51 -0800] "GET / HTTP/1.1" 200 -`,
+				time.Date(2020, time.October, 5, 18, 32, 51, 0, time.UTC)),
+			Entry("RFC 3339 timestamp", `level=info time=2020-10-05T10:32:51.25+02:00 msg=started`,
+				time.Date(2020, time.October, 5, 8, 32, 51, 250_000_000, time.UTC)),
+			Entry("timestamp without time zone", `2020-10-05 10:32:51 ERROR oops`,
+				time.Date(2020, time.October, 5, 10, 32, 51, 0, time.UTC)),
+			// January 1 is never after tomorrow so it is in the current year
+			Entry("RFC 3164 timestamp", `<34>Jan  1 00:00:01 mymachine su: 'su root' failed`,
+				time.Date(time.Now().UTC().Year(), time.January, 1, 0, 0, 1, 0, time.UTC)),
+		)
+
+		When("no format matches the event", func() {
+			It("should return false", func() {
+				_, ok := processor.TimestampParser(processor.DefaultTimestampFormats).Parse("at main.go:12")
+				Expect(ok).Should(BeFalse())
+			})
+		})
+
+		When("format has no capturing group", func() {
+			It("should parse the entire match", func() {
+				parser := processor.TimestampParser{{Pattern: regexp.MustCompile(`\d{10}`), Layout: "0601021504"}}
+				t, ok := parser.Parse("event 2010051032 done")
+				Expect(ok).Should(BeTrue())
+				Expect(t).Should(BeTemporally("==", time.Date(2020, time.October, 5, 10, 32, 0, 0, time.UTC)))
+			})
+		})
+	})
+
+	Describe("WithTimeRange", func() {
+		var (
+			delegate *mocks.EventProcessor
+			since    = time.Date(2020, time.October, 5, 10, 0, 0, 0, time.UTC)
+			until    = time.Date(2020, time.October, 5, 12, 0, 0, 0, time.UTC)
+		)
+
+		BeforeEach(func() {
+			delegate = &mocks.EventProcessor{}
+		})
+
+		AfterEach(func() {
+			delegate.AssertExpectations(GinkgoT())
+		})
+
+		// the events are returned by the delegate from the most recent to the oldest one
+		givenEvents := func(events ...string) {
+			for _, event := range events {
+				delegate.On("Next").Return(event, nil).Once()
+			}
+		}
+
+		readAll := func(ep processor.EventProcessor) []string {
+			events := []string{}
+			for {
+				s, err := ep.Next()
+				if err == io.EOF {
+					return events
+				}
+				Expect(err).ShouldNot(HaveOccurred())
+				events = append(events, s)
+			}
+		}
+
+		When("decorated processor returns an error", func() {
+			criticalError := errors.New("oops")
+			BeforeEach(func() {
+				delegate.On("Next").Return("", criticalError).Once()
+			})
+			It("should propagate the error", func() {
+				_, err := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, since, until,
+					processor.IncludeUnparseable).Next()
+				Expect(err).Should(Equal(criticalError))
+			})
+		})
+
+		When("an event is older than since", func() {
+			BeforeEach(func() {
+				givenEvents(
+					"2020-10-05 13:00:00 after",
+					"2020-10-05 12:00:00 until",
+					"2020-10-05 11:00:00 within",
+					"2020-10-05 10:00:00 since",
+					"2020-10-05 09:59:59 before",
+				)
+			})
+			It("should return the events in the range and stop reading", func() {
+				ep := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, since, until,
+					processor.IncludeUnparseable)
+				Expect(readAll(ep)).Should(Equal([]string{
+					"2020-10-05 12:00:00 until",
+					"2020-10-05 11:00:00 within",
+					"2020-10-05 10:00:00 since",
+				}))
+			})
+		})
+
+		When("range is open", func() {
+			BeforeEach(func() {
+				givenEvents("2020-10-05 13:00:00 after", "2020-10-05 09:00:00 before")
+				delegate.On("Next").Return("", io.EOF).Once()
+			})
+			It("should return all the events", func() {
+				ep := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, time.Time{}, time.Time{},
+					processor.IncludeUnparseable)
+				Expect(readAll(ep)).Should(Equal([]string{"2020-10-05 13:00:00 after", "2020-10-05 09:00:00 before"}))
+			})
+		})
+
+		When("some timestamps cannot be parsed", func() {
+			BeforeEach(func() {
+				givenEvents(
+					"at main.go:3",
+					"2020-10-05 13:00:00 after",
+					"at main.go:2",
+					"2020-10-05 11:00:00 within",
+					"at main.go:1",
+					"2020-10-05 09:00:00 before",
+				)
+			})
+			DescribeTable("should apply the policy", func(policy processor.UnparseableTimestampPolicy, expected []string) {
+				ep := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, since, until, policy)
+				Expect(readAll(ep)).Should(Equal(expected))
+			},
+				Entry("include", processor.IncludeUnparseable, []string{
+					"at main.go:3", "at main.go:2", "2020-10-05 11:00:00 within", "at main.go:1",
+				}),
+				Entry("exclude", processor.ExcludeUnparseable, []string{"2020-10-05 11:00:00 within"}),
+				Entry("inherit", processor.InheritTimestamp, []string{"at main.go:2", "2020-10-05 11:00:00 within"}),
+			)
+		})
+
+		When("no older event has a timestamp", func() {
+			BeforeEach(func() {
+				givenEvents("at main.go:2", "at main.go:1")
+				delegate.On("Next").Return("", io.EOF).Once()
+			})
+			It("should drop the events that inherit the timestamp", func() {
+				ep := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, since, until,
+					processor.InheritTimestamp)
+				Expect(readAll(ep)).Should(BeEmpty())
+			})
+		})
+
+		When("events are read ahead", func() {
+			It("should locate the last event returned", func() {
+				fs := afero.NewMemMapFs()
+				Expect(afero.WriteFile(fs, "/var/log/file.log", []byte(
+					"2020-10-05 11:00:00 b\nat b\n2020-10-05 13:00:00 after\n2020-10-05 11:30:00 c\n"), 0644)).Should(Succeed())
+				reader, err := processor.NewTailReader(fs, "/var/log/file.log")
+				Expect(err).ShouldNot(HaveOccurred())
+				DeferCleanup(reader.Close)
+				breaker := processor.NewEventBreaker(reader, processor.ReverseScanLines, 64)
+
+				ep := processor.WithTimeRange(breaker, processor.DefaultTimestampFormats, since, until,
+					processor.InheritTimestamp)
+				Expect(ep.Next()).Should(Equal("2020-10-05 11:30:00 c"))
+				Expect(ep.Next()).Should(Equal("at b"))
+				Expect(ep.Offset()).Should(BeNumerically("<", breaker.Offset()))
+				Expect(breaker.Rewind(ep.Offset())).Should(Succeed())
+				Expect(breaker.Next()).Should(Equal("2020-10-05 11:00:00 b"))
+			})
+		})
+
+		When("too many consecutive events have no timestamp", func() {
+			BeforeEach(func() {
+				delegate.On("Next").Return("at main.go:1", nil).Times(1001)
+			})
+			It("should return an error", func() {
+				ep := processor.WithTimeRange(delegate, processor.DefaultTimestampFormats, since, until,
+					processor.InheritTimestamp)
+				_, err := ep.Next()
+				Expect(err).Should(MatchError(processor.PendingEventsErr))
+			})
+		})
+	})
+})
@@ -20,6 +20,7 @@
 package functional_test
 
 import (
+	"net"
 	"testing"
 	"time"
 
@@ -51,8 +52,17 @@ var _ = BeforeSuite(func() {
 		ShutdownTimeout: time.Second,
 		MaxEvents:       100,
 		LogFolder: "/var/log/",
+		FollowPollInterval: 10 * time.Millisecond,
+		MaxPatternLength: 1024,
 	}, fs, zap.NewNop())
 	go server.Start()
+	Eventually(func() error {
+		conn, err := net.Dial("tcp", "localhost:9999")
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}).Should(Succeed())
 })
 
 var _ = AfterSuite(func() {
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"os"
 
 	"github.com/dvergnes/log-collector/api"
 
@@ -60,6 +61,32 @@ jdoe approves MR 890
 		})
 	})
 
+	When("file is followed", func() {
+		BeforeEach(func() {
+			Expect(afero.WriteFile(fs, "/var/log/follow.log", []byte("dvergnes submits MR 432\nalice submits MR 123\n"), 0755)).Should(Succeed())
+		})
+		It("should stream the events appended to the file", func() {
+			resp, err := http.Get("http://localhost:9999/log?file=follow.log&filter=dvergnes&follow=true")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).Should(Equal(http.StatusOK))
+			decoder := json.NewDecoder(resp.Body)
+
+			event := api.LogEvent{}
+			Expect(decoder.Decode(&event)).Should(Succeed())
+			Expect(event.Event).Should(Equal("dvergnes submits MR 432"))
+
+			f, err := fs.OpenFile("/var/log/follow.log", os.O_APPEND|os.O_WRONLY, 0755)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = f.WriteString("alice approves MR 432\ndvergnes closes MR 432\n")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(f.Close()).Should(Succeed())
+
+			Expect(decoder.Decode(&event)).Should(Succeed())
+			Expect(event.Event).Should(Equal("dvergnes closes MR 432"))
+		})
+	})
+
 	When("request is invalid", func() {
 		It("should return a response with an error", func() {
 			resp, err:=http.Get("http://localhost:9999/log?file=not_found")